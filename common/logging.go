@@ -7,11 +7,14 @@ package common
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 )
 
+// Logger is the interface used for logging in the library. Implement it to route the log
+// messages to the logging package of the application and install it with SetLogger.
 type Logger interface {
 	Error(format string, args ...interface{})
 	Warning(format string, args ...interface{})
@@ -49,9 +52,10 @@ func (DummyLogger) Debug(format string, args ...interface{}) {
 func (DummyLogger) Trace(format string, args ...interface{}) {
 }
 
-// IsLogLevel returns true from dummy logger.
+// IsLogLevel returns false from dummy logger as it does not log anything, so that callers can
+// skip preparing expensive log messages.
 func (DummyLogger) IsLogLevel(level LogLevel) bool {
-	return true
+	return false
 }
 
 // LogLevel is the verbosity level for logging.
@@ -124,15 +128,110 @@ func (l ConsoleLogger) Trace(format string, args ...interface{}) {
 	}
 }
 
+// WriterLogger is a logger that writes its messages to an io.Writer, e.g. a file or a buffer
+// used for capturing the log output in tests.
+type WriterLogger struct {
+	LogLevel LogLevel
+	Output   io.Writer
+}
+
+// NewWriterLogger returns a new WriterLogger with the specified `logLevel` writing to `writer`.
+func NewWriterLogger(logLevel LogLevel, writer io.Writer) *WriterLogger {
+	logger := WriterLogger{}
+	logger.LogLevel = logLevel
+	logger.Output = writer
+	return &logger
+}
+
+// IsLogLevel returns true if log level is greater or equal than `level`.
+// Can be used to avoid resource intensive calls to loggers.
+func (l WriterLogger) IsLogLevel(level LogLevel) bool {
+	return l.LogLevel >= level
+}
+
+// Error logs error message.
+func (l WriterLogger) Error(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelError {
+		l.output(l.Output, "[ERROR] ", format, args...)
+	}
+}
+
+// Warning logs warning message.
+func (l WriterLogger) Warning(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelWarning {
+		l.output(l.Output, "[WARNING] ", format, args...)
+	}
+}
+
+// Notice logs notice message.
+func (l WriterLogger) Notice(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelNotice {
+		l.output(l.Output, "[NOTICE] ", format, args...)
+	}
+}
+
+// Info logs info message.
+func (l WriterLogger) Info(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelInfo {
+		l.output(l.Output, "[INFO] ", format, args...)
+	}
+}
+
+// Debug logs debug message.
+func (l WriterLogger) Debug(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelDebug {
+		l.output(l.Output, "[DEBUG] ", format, args...)
+	}
+}
+
+// Trace logs trace message.
+func (l WriterLogger) Trace(format string, args ...interface{}) {
+	if l.LogLevel >= LogLevelTrace {
+		l.output(l.Output, "[TRACE] ", format, args...)
+	}
+}
+
+// output writes `format`, `args` log message prefixed by the source file name, line and `prefix`
+func (l WriterLogger) output(w io.Writer, prefix string, format string, args ...interface{}) {
+	logToWriter(w, prefix, format, args...)
+}
+
+// Log is the logger used by all the packages of the library. It discards all messages by default.
 var Log Logger = DummyLogger{}
 
+// SetLogger sets `logger` as the logger used by the library, e.g. a ConsoleLogger, a WriterLogger,
+// or an adapter for the logging package of the application.
 func SetLogger(logger Logger) {
 	Log = logger
 }
 
+// SetLogLevel sets the log level of the current logger. Messages below `level` are discarded
+// before being formatted. Has no effect if the current logger is not a ConsoleLogger or a
+// WriterLogger, as custom loggers are expected to manage their own levels.
+func SetLogLevel(level LogLevel) {
+	switch l := Log.(type) {
+	case *ConsoleLogger:
+		l.LogLevel = level
+	case ConsoleLogger:
+		l.LogLevel = level
+		Log = l
+	case *WriterLogger:
+		l.LogLevel = level
+	case WriterLogger:
+		l.LogLevel = level
+		Log = l
+	}
+}
+
 // output writes `format`, `args` log message prefixed by the source file name, line and `prefix`
-func (l ConsoleLogger) output(f *os.File, prefix string, format string, args ...interface{}) {
-	_, file, line, ok := runtime.Caller(2)
+func (l ConsoleLogger) output(f io.Writer, prefix string, format string, args ...interface{}) {
+	logToWriter(f, prefix, format, args...)
+}
+
+// logToWriter writes the log message to `f`. The caller depth is fixed: it is expected to be
+// called from the output method of a logger, which in turn is called by the logging method.
+func logToWriter(f io.Writer, prefix string, format string, args ...interface{}) {
+	_, file, line, ok := runtime.Caller(3)
 	if !ok {
 		file = "???"
 		line = 0
@@ -0,0 +1,64 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewWriterLogger(LogLevelInfo, &buf)
+
+	logger.Error("error %d", 1)
+	logger.Info("info %s", "two")
+	logger.Debug("debug")
+	logger.Trace("trace")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Regexp(t, `^\[ERROR\]  logging_test\.go:\d+ error 1$`, lines[0])
+		assert.Regexp(t, `^\[INFO\]  logging_test\.go:\d+ info two$`, lines[1])
+	}
+	assert.True(t, logger.IsLogLevel(LogLevelNotice))
+	assert.False(t, logger.IsLogLevel(LogLevelDebug))
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(Log)
+
+	var buf bytes.Buffer
+	SetLogger(NewWriterLogger(LogLevelWarning, &buf))
+	Log.Warning("captured")
+	Log.Info("dropped")
+	assert.Contains(t, buf.String(), "captured")
+	assert.NotContains(t, buf.String(), "dropped")
+}
+
+func TestSetLogLevel(t *testing.T) {
+	defer SetLogger(Log)
+
+	var buf bytes.Buffer
+	SetLogger(NewWriterLogger(LogLevelTrace, &buf))
+	SetLogLevel(LogLevelWarning)
+	Log.Debug("dropped")
+	Log.Warning("kept")
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Contains(t, buf.String(), "kept")
+	assert.False(t, Log.IsLogLevel(LogLevelInfo))
+
+	SetLogger(ConsoleLogger{LogLevel: LogLevelTrace})
+	SetLogLevel(LogLevelError)
+	assert.False(t, Log.IsLogLevel(LogLevelWarning))
+
+	SetLogger(DummyLogger{})
+	SetLogLevel(LogLevelTrace)
+	assert.False(t, Log.IsLogLevel(LogLevelError))
+}
@@ -0,0 +1,153 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer represents a parsed semantic version such as "3.4.0" or "2.0.0-alpha.4".
+// Build metadata (anything following a '+') is not kept as it does not affect precedence.
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+}
+
+// ParseVersion parses a semantic version string of the form MAJOR.MINOR.PATCH with an optional
+// "-PRERELEASE" suffix and optional "+BUILD" metadata. A leading 'v' is accepted.
+func ParseVersion(s string) (SemVer, error) {
+	var v SemVer
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.PreRelease = str[i+1:]
+		str = str[:i]
+		if v.PreRelease == "" {
+			return SemVer{}, fmt.Errorf("invalid version %q: empty pre-release", s)
+		}
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid version %q: expecting MAJOR.MINOR.PATCH", s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SemVer{}, fmt.Errorf("invalid version %q: bad number %q", s, part)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	if v.PreRelease != "" {
+		for _, id := range strings.Split(v.PreRelease, ".") {
+			if id == "" {
+				return SemVer{}, errors.New("invalid version: empty pre-release identifier")
+			}
+		}
+	}
+	return v, nil
+}
+
+// Compare compares `v` to `other`. Returns -1 if `v` has lower precedence, 1 if higher and 0
+// if the versions are equal. Pre-release versions have lower precedence than the associated
+// release, and pre-release identifiers are compared according to the semver rules, so that
+// 3.0.0-alpha < 3.0.0-alpha.1 < 3.0.0-beta < 3.0.0-rc.1 < 3.0.0.
+func (v SemVer) Compare(other SemVer) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	}
+
+	ids := strings.Split(v.PreRelease, ".")
+	otherIds := strings.Split(other.PreRelease, ".")
+	for i := 0; i < len(ids) && i < len(otherIds); i++ {
+		if c := comparePreReleaseID(ids[i], otherIds[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(ids), len(otherIds))
+}
+
+// Compare compares the versions `a` and `b`, returning -1, 0 or 1 as a.Compare(b) does. This
+// allows gating features on the library version, e.g.
+//
+//	if common.Compare(common.Current(), min) >= 0 { ... }
+func Compare(a, b SemVer) int {
+	return a.Compare(b)
+}
+
+// Current returns the parsed version of the library (Version).
+func Current() SemVer {
+	v, err := ParseVersion(Version)
+	if err != nil {
+		Log.Error("Invalid library version %q: %v", Version, err)
+	}
+	return v
+}
+
+// AtLeast returns true if `v` has the same or higher precedence than `min`.
+func (v SemVer) AtLeast(min SemVer) bool {
+	return v.Compare(min) >= 0
+}
+
+// String returns the version in the MAJOR.MINOR.PATCH[-PRERELEASE] form.
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// comparePreReleaseID compares two dot separated pre-release identifiers. Numeric identifiers
+// are compared numerically and always have lower precedence than alphanumeric ones.
+func comparePreReleaseID(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInt(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
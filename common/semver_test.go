@@ -0,0 +1,78 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	testcases := []struct {
+		Input    string
+		Expected SemVer
+	}{
+		{"3.4.0", SemVer{Major: 3, Minor: 4}},
+		{"v2.0.0-alpha.4", SemVer{Major: 2, PreRelease: "alpha.4"}},
+		{"1.2.3-rc.1+build.5", SemVer{Major: 1, Minor: 2, Patch: 3, PreRelease: "rc.1"}},
+	}
+	for _, tcase := range testcases {
+		v, err := ParseVersion(tcase.Input)
+		require.NoError(t, err, tcase.Input)
+		assert.Equal(t, tcase.Expected, v)
+	}
+
+	for _, invalid := range []string{"", "3", "3.4", "3.4.x", "3.4.0-", "3.4.0-alpha..1", "-1.0.0"} {
+		_, err := ParseVersion(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSemVerCompare(t *testing.T) {
+	// Ordered by increasing precedence, as in the semver specification.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := ParseVersion(ordered[i])
+			require.NoError(t, err)
+			b, err := ParseVersion(ordered[j])
+			require.NoError(t, err)
+
+			expected := compareInt(i, j)
+			assert.Equal(t, expected, a.Compare(b), "%s vs %s", a, b)
+			assert.Equal(t, expected >= 0, a.AtLeast(b), "%s vs %s", a, b)
+		}
+	}
+
+	a, _ := ParseVersion("1.0.0+build.1")
+	b, _ := ParseVersion("1.0.0+build.2")
+	assert.Equal(t, 0, a.Compare(b))
+}
+
+func TestCompareCurrent(t *testing.T) {
+	current := Current()
+	assert.Equal(t, Version, current.String())
+
+	older, err := ParseVersion("2.0.0-alpha.4")
+	require.NoError(t, err)
+	assert.Equal(t, 1, Compare(current, older))
+	assert.Equal(t, -1, Compare(older, current))
+	assert.Equal(t, 0, Compare(current, current))
+}
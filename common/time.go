@@ -14,3 +14,22 @@ const timeFormat = "2 January 2006 at 15:04"
 func UtcTimeFormat(t time.Time) string {
 	return t.Format(timeFormat) + " UTC"
 }
+
+// timeNow is the clock used by the library for the dates and identifiers of written documents.
+var timeNow = time.Now
+
+// Now returns the current time according to the clock used for the dates and identifiers of
+// written documents.
+func Now() time.Time {
+	return timeNow()
+}
+
+// SetNowFunc overrides the clock used for the dates and identifiers of written documents, e.g.
+// to pin the time for deterministic output in tests. License validation always uses the system
+// clock. Passing nil restores the system clock.
+func SetNowFunc(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	timeNow = now
+}
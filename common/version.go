@@ -7,6 +7,7 @@
 package common
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -20,3 +21,56 @@ const releaseMin = 50
 const Version = "3.4.0"
 
 var ReleasedAt = time.Date(releaseYear, releaseMonth, releaseDay, releaseHour, releaseMin, 0, 0, time.UTC)
+
+// Build metadata which can be injected at build time, e.g.
+//
+//	go build -ldflags "-X github.com/unidoc/unipdf/v3/common.BuildCommit=$(git rev-parse HEAD)"
+//
+// Both are empty unless set by the build.
+var (
+	// BuildCommit is the source control revision the library was built from.
+	BuildCommit = ""
+	// BuildTime is the time the library was built at.
+	BuildTime = ""
+)
+
+// VersionInfo holds the version information of the library as returned by BuildInfo.
+type VersionInfo struct {
+	Version    string
+	ReleasedAt time.Time
+	Commit     string
+	BuildTime  string
+}
+
+// BuildInfo returns the version information of the library including any build metadata
+// injected at build time.
+func BuildInfo() VersionInfo {
+	return VersionInfo{
+		Version:    Version,
+		ReleasedAt: ReleasedAt,
+		Commit:     BuildCommit,
+		BuildTime:  BuildTime,
+	}
+}
+
+// String returns a one line summary of the version information, suitable for logs.
+func (info VersionInfo) String() string {
+	s := fmt.Sprintf("unipdf %s (released %s)", info.Version, UtcTimeFormat(info.ReleasedAt))
+	if info.Commit != "" {
+		s += " commit " + info.Commit
+	}
+	if info.BuildTime != "" {
+		s += " built " + info.BuildTime
+	}
+	return s
+}
+
+// Age returns the time elapsed since the release of the library (ReleasedAt).
+func Age() time.Duration {
+	return Now().Sub(ReleasedAt)
+}
+
+// IsOlderThan returns true if the library was released more than `d` ago.
+func IsOlderThan(d time.Duration) bool {
+	return Age() > d
+}
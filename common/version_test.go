@@ -0,0 +1,25 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAge(t *testing.T) {
+	defer SetNowFunc(nil)
+
+	SetNowFunc(func() time.Time {
+		return ReleasedAt.Add(90 * 24 * time.Hour)
+	})
+	assert.Equal(t, 90*24*time.Hour, Age())
+	assert.True(t, IsOlderThan(30*24*time.Hour))
+	assert.False(t, IsOlderThan(90*24*time.Hour))
+	assert.False(t, IsOlderThan(365*24*time.Hour))
+}
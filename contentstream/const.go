@@ -11,4 +11,13 @@ var (
 	// ErrInvalidOperand specifies that invalid operands have been encountered
 	// while parsing the content stream.
 	ErrInvalidOperand = errors.New("invalid operand")
+
+	// ErrNestingTooDeep specifies that arrays and dictionaries are nested deeper than
+	// maxNestingDepth in the content stream.
+	ErrNestingTooDeep = errors.New("arrays and dictionaries nested too deep")
 )
+
+// maxNestingDepth is the maximum nesting depth of arrays and dictionaries in content stream
+// operands. Valid content streams do not come close, and the limit prevents stack overflows
+// on malicious input.
+const maxNestingDepth = 100
@@ -8,8 +8,16 @@ package contentstream
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 
+	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/cmap"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
 )
 
 // ContentStreamOperation represents an operation in PDF contentstream which consists of
@@ -76,36 +84,144 @@ func (ops *ContentStreamOperations) WrapIfNeeded() *ContentStreamOperations {
 	return ops
 }
 
+// Clone returns a deep copy of `ops`. The operations and their parameters are copied, so that
+// changes to the copy do not affect `ops`.
+func (ops ContentStreamOperations) Clone() ContentStreamOperations {
+	if ops == nil {
+		return nil
+	}
+	cloned := make(ContentStreamOperations, 0, len(ops))
+	for _, op := range ops {
+		if op == nil {
+			cloned = append(cloned, nil)
+			continue
+		}
+		clonedOp := &ContentStreamOperation{Operand: op.Operand}
+		if op.Params != nil {
+			clonedOp.Params = make([]core.PdfObject, 0, len(op.Params))
+			for _, param := range op.Params {
+				clonedOp.Params = append(clonedOp.Params, cloneParam(param))
+			}
+		}
+		cloned = append(cloned, clonedOp)
+	}
+	return cloned
+}
+
+// cloneParam returns a deep copy of the operation parameter `param`.
+func cloneParam(param core.PdfObject) core.PdfObject {
+	if img, ok := param.(*ContentStreamInlineImage); ok {
+		return img.clone()
+	}
+	return core.CloneObject(param)
+}
+
 // Bytes converts a set of content stream operations to a content stream byte presentation,
 // i.e. the kind that can be stored as a PDF stream or string format.
 func (ops *ContentStreamOperations) Bytes() []byte {
 	var buf bytes.Buffer
+	ops.WriteTo(&buf)
+	return buf.Bytes()
+}
 
+// WriteTo writes the content stream byte presentation of `ops` to `w`. The operations are
+// serialized into a reused buffer, without building intermediate strings for numbers and names.
+// Implements the io.WriterTo interface.
+func (ops *ContentStreamOperations) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	var b []byte
 	for _, op := range *ops {
 		if op == nil {
 			continue
 		}
 
-		if op.Operand == "BI" {
-			// Inline image requires special handling.
-			buf.WriteString(op.Operand + "\n")
-			buf.WriteString(op.Params[0].WriteString())
+		b = appendOperation(b[:0], op)
+		n, err := w.Write(b)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
 
-		} else {
-			// Default handler.
-			for _, param := range op.Params {
-				buf.WriteString(param.WriteString())
-				buf.WriteString(" ")
+// BytesSplit serializes `ops` like Bytes, split into chunks of at most `maxSize` bytes that can
+// be written as separate content streams of a page. Operations are never split, so an operation
+// longer than `maxSize` makes up a chunk of its own. All operations are returned in a single
+// chunk if `maxSize` is not positive.
+func (ops ContentStreamOperations) BytesSplit(maxSize int) [][]byte {
+	var chunks [][]byte
+	var chunk, b []byte
+	for _, op := range ops {
+		if op == nil {
+			continue
+		}
+		b = appendOperation(b[:0], op)
+		if maxSize > 0 && len(chunk) > 0 && len(chunk)+len(b) > maxSize {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+		chunk = append(chunk, b...)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
 
-			}
+// appendOperation appends the serialized form of `op`, terminated by a newline, to `b`.
+func appendOperation(b []byte, op *ContentStreamOperation) []byte {
+	if op.Operand == "BI" {
+		// Inline image requires special handling.
+		b = append(b, op.Operand...)
+		b = append(b, '\n')
+		return append(b, op.Params[0].WriteString()...)
+	}
 
-			buf.WriteString(op.Operand + "\n")
-		}
+	// Default handler.
+	for _, param := range op.Params {
+		b = appendObject(b, param)
+		b = append(b, ' ')
 	}
+	b = append(b, op.Operand...)
+	return append(b, '\n')
+}
 
-	return buf.Bytes()
+// appendObject appends the serialized form of `obj` to `b`. Numbers and names, which make up
+// most operands, are appended directly. Other objects fall back to their WriteString method.
+func appendObject(b []byte, obj core.PdfObject) []byte {
+	switch t := obj.(type) {
+	case *core.PdfObjectFloat:
+		return strconv.AppendFloat(b, float64(*t), 'f', -1, 64)
+	case *core.PdfObjectInteger:
+		return strconv.AppendInt(b, int64(*t), 10)
+	case *core.PdfObjectName:
+		b = append(b, '/')
+		for i := 0; i < len(*t); i++ {
+			c := (*t)[i]
+			if !core.IsPrintable(c) || c == '#' || core.IsDelimiter(c) {
+				b = append(b, '#', hexDigits[c>>4], hexDigits[c&0x0f])
+			} else {
+				b = append(b, c)
+			}
+		}
+		return b
+	case *core.PdfObjectArray:
+		b = append(b, '[')
+		for i, o := range t.Elements() {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = appendObject(b, o)
+		}
+		return append(b, ']')
+	}
+	return append(b, obj.WriteString()...)
 }
 
+// hexDigits are the lower case hexadecimal digits used for escaping names.
+const hexDigits = "0123456789abcdef"
+
 // String returns `ops.Bytes()` as a string.
 func (ops *ContentStreamOperations) String() string {
 	return string(ops.Bytes())
@@ -116,6 +232,19 @@ func (ops *ContentStreamOperations) String() string {
 //
 // Deprecated: More advanced text extraction is offered in package extractor with character encoding support.
 func (csp *ContentStreamParser) ExtractText() (string, error) {
+	return csp.extractText(nil)
+}
+
+// ExtractTextFromResources parses and extracts all text data in content streams like ExtractText,
+// mapping the character codes to Unicode with the ToUnicode CMaps of the fonts in `resources`.
+// The codes of fonts without a ToUnicode CMap, and strings that do not fit the codespaces of the
+// CMap, are output as is. Codes of the codespaces without a mapping are output as U+FFFD.
+func (csp *ContentStreamParser) ExtractTextFromResources(resources *model.PdfPageResources) (string, error) {
+	return csp.extractText(resources)
+}
+
+// extractText implements ExtractText and ExtractTextFromResources. `resources` may be nil.
+func (csp *ContentStreamParser) extractText(resources *model.PdfPageResources) (string, error) {
 	operations, err := csp.Parse()
 	if err != nil {
 		return "", err
@@ -123,12 +252,51 @@ func (csp *ContentStreamParser) ExtractText() (string, error) {
 	inText := false
 	xPos, yPos := float64(-1), float64(-1)
 	txt := ""
+	state := newTextSpacing()
+	var stateStack []textSpacing
+	fonts := toUnicodeFonts{resources: resources}
 	for _, op := range *operations {
 		if op.Operand == "BT" {
 			inText = true
 		} else if op.Operand == "ET" {
 			inText = false
 		}
+		switch op.Operand {
+		case "q":
+			stateStack = append(stateStack, state)
+		case "Q":
+			if n := len(stateStack); n > 0 {
+				state = stateStack[n-1]
+				stateStack = stateStack[:n-1]
+			}
+		case "Tf":
+			if len(op.Params) == 2 {
+				if size, err := core.GetNumberAsFloat(op.Params[1]); err == nil {
+					state.fontSize = math.Abs(size)
+				}
+				if name, ok := core.GetName(op.Params[0]); ok {
+					state.font = *name
+				}
+			}
+		case "Tc":
+			if len(op.Params) == 1 {
+				if tc, err := core.GetNumberAsFloat(op.Params[0]); err == nil {
+					state.charSpacing = tc
+				}
+			}
+		case "Tw":
+			if len(op.Params) == 1 {
+				if tw, err := core.GetNumberAsFloat(op.Params[0]); err == nil {
+					state.wordSpacing = tw
+				}
+			}
+		case "Tz":
+			if len(op.Params) == 1 {
+				if tz, err := core.GetNumberAsFloat(op.Params[0]); err == nil {
+					state.horizScaling = tz / 100
+				}
+			}
+		}
 		if op.Operand == "Td" || op.Operand == "TD" || op.Operand == "T*" {
 			// Move to next line...
 			txt += "\n"
@@ -179,13 +347,10 @@ func (csp *ContentStreamParser) ExtractText() (string, error) {
 			for _, obj := range paramList.Elements() {
 				switch v := obj.(type) {
 				case *core.PdfObjectString:
-					txt += v.Str()
-				case *core.PdfObjectFloat:
-					if *v < -100 {
-						txt += " "
-					}
-				case *core.PdfObjectInteger:
-					if *v < -100 {
+					txt += fonts.decode(state.font, v)
+				case *core.PdfObjectFloat, *core.PdfObjectInteger:
+					adj, _ := core.GetNumberAsFloat(v)
+					if state.isWordBreak(adj, csp.wordSpacingCutoff) && !strings.HasSuffix(txt, " ") {
 						txt += " "
 					}
 				}
@@ -198,9 +363,331 @@ func (csp *ContentStreamParser) ExtractText() (string, error) {
 			if !ok {
 				return "", fmt.Errorf("invalid parameter type, not string (%T)", op.Params[0])
 			}
-			txt += param.Str()
+			txt += fonts.decode(state.font, param)
+		} else if inText && (op.Operand == "'" || op.Operand == `"`) {
+			// Move to next line and show text, " also setting the word and character spacing.
+			numParams := 1
+			if op.Operand == `"` {
+				numParams = 3
+			}
+			if len(op.Params) != numParams {
+				continue
+			}
+			param, ok := op.Params[numParams-1].(*core.PdfObjectString)
+			if !ok {
+				return "", fmt.Errorf("invalid parameter type, not string (%T)", op.Params[numParams-1])
+			}
+			if numParams == 3 {
+				if f, err := core.GetNumbersAsFloat(op.Params[:2]); err == nil {
+					state.wordSpacing, state.charSpacing = f[0], f[1]
+				}
+			}
+			txt += "\n" + fonts.decode(state.font, param)
 		}
 	}
 
 	return txt, nil
 }
+
+// toUnicodeFonts maps the character codes of the fonts in `resources` to Unicode.
+type toUnicodeFonts struct {
+	resources *model.PdfPageResources
+	cmaps     map[core.PdfObjectName]*cmap.CMap // Loaded ToUnicode CMaps, nil for fonts without.
+}
+
+// decode returns the text of `str` shown with the font named `font`. The character codes are
+// returned as is if the font has no ToUnicode CMap or they don't fit its codespaces, and the
+// codes without a mapping are replaced by cmap.MissingCodeRune (U+FFFD).
+func (fonts *toUnicodeFonts) decode(font core.PdfObjectName, str *core.PdfObjectString) string {
+	cm := fonts.toUnicode(font)
+	if cm == nil {
+		return str.Str()
+	}
+	codes, ok := cm.BytesToCharcodes(str.Bytes())
+	if !ok {
+		return str.Str()
+	}
+	runes := make([]rune, len(codes))
+	for i, code := range codes {
+		runes[i], _ = cm.CharcodeToUnicode(code)
+	}
+	return string(runes)
+}
+
+// toUnicode returns the ToUnicode CMap of the font named `font`, or nil if there is none.
+func (fonts *toUnicodeFonts) toUnicode(font core.PdfObjectName) *cmap.CMap {
+	if fonts.resources == nil || font == "" {
+		return nil
+	}
+	if cm, ok := fonts.cmaps[font]; ok {
+		return cm
+	}
+	if fonts.cmaps == nil {
+		fonts.cmaps = make(map[core.PdfObjectName]*cmap.CMap)
+	}
+	fonts.cmaps[font] = nil
+
+	obj, ok := fonts.resources.GetFontByName(font)
+	if !ok {
+		common.Log.Debug("Font %s not found in resources", font)
+		return nil
+	}
+	fontDict, ok := core.GetDict(obj)
+	if !ok {
+		return nil
+	}
+	stream, ok := core.GetStream(fontDict.Get("ToUnicode"))
+	if !ok {
+		return nil
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		common.Log.Debug("ERROR: Unable to decode ToUnicode stream of font %s: %v", font, err)
+		return nil
+	}
+	cm, err := cmap.LoadCmapFromData(data, false)
+	if err != nil {
+		common.Log.Debug("ERROR: Unable to load ToUnicode CMap of font %s: %v", font, err)
+		return nil
+	}
+	fonts.cmaps[font] = cm
+	return cm
+}
+
+// defaultWordSpacingCutoff is the default minimum gap between words as a fraction of the font
+// size. It corresponds to a TJ adjustment of -100 at zero character spacing.
+const defaultWordSpacingCutoff = 0.1
+
+// textSpacing is the part of the text state that ExtractText uses for decoding text and
+// detecting word breaks.
+type textSpacing struct {
+	font         core.PdfObjectName // Tf
+	fontSize     float64            // Tf
+	charSpacing  float64            // Tc
+	wordSpacing  float64            // Tw
+	horizScaling float64            // Tz, as a fraction.
+}
+
+// newTextSpacing returns the initial text spacing state, with no horizontal scaling.
+func newTextSpacing() textSpacing {
+	return textSpacing{horizScaling: 1}
+}
+
+// isWordBreak returns true if the TJ array adjustment `adj`, in thousandths of text space units,
+// leaves a gap between glyphs wide enough to be a space between words. The gap has to exceed the
+// normal character spacing by `cutoff` times the font size or, if the content uses word spacing,
+// by half of the word spacing when that is smaller.
+// The gap and the threshold are both measured after horizontal scaling (Tz), so that the
+// threshold scales with the glyphs of condensed or expanded text.
+func (ts textSpacing) isWordBreak(adj, cutoff float64) bool {
+	fontSize := ts.fontSize
+	if fontSize == 0 {
+		// The font size is unknown, measure in units of the font size.
+		fontSize = 1
+	}
+	th := ts.horizScaling
+	if th <= 0 {
+		th = 1
+	}
+	gap := (-adj/1000*fontSize + ts.charSpacing) * th
+	threshold := cutoff * fontSize * th
+	if ts.wordSpacing > 0 {
+		threshold = math.Min(threshold, ts.wordSpacing/2*th)
+	}
+	return gap > threshold+math.Max(ts.charSpacing, 0)*th
+}
+
+// TextChunk is a string shown by a text showing operator together with its position on the page.
+type TextChunk struct {
+	Text string
+
+	// X and Y are the page coordinates of the baseline origin of the first glyph.
+	X, Y float64
+
+	// Width is the advance of the text along the baseline. Glyph widths are approximated as
+	// half of the font size as the font metrics are not available.
+	Width float64
+
+	// Height is the font size in page coordinates, approximating the line height.
+	Height float64
+
+	// FontSize is the font size set by the Tf operator, in text space units.
+	FontSize float64
+}
+
+// approxGlyphWidth is the glyph width, as a fraction of the font size, assumed by
+// ExtractTextWithPositions in the absence of font metrics.
+const approxGlyphWidth = 0.5
+
+// positionState is the part of the graphics state that ExtractTextWithPositions uses for
+// placing text on the page.
+type positionState struct {
+	textSpacing
+	ctm     transform.Matrix
+	leading float64 // TL
+	rise    float64 // Ts
+}
+
+// ExtractTextWithPositions parses the content stream and returns the text shown by each Tj,
+// TJ, ' and " operator along with its position in page coordinates, taking into account the text
+// and current transformation matrices. The character codes are not decoded, as with ExtractText.
+func (csp *ContentStreamParser) ExtractTextWithPositions() ([]TextChunk, error) {
+	operations, err := csp.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	state := positionState{textSpacing: newTextSpacing(), ctm: transform.IdentityMatrix()}
+	var stateStack []positionState
+	var tm, tlm transform.Matrix
+	inText := false
+	var chunks []TextChunk
+
+	moveText := func(tx, ty float64) {
+		tlm.Concat(transform.TranslationMatrix(tx, ty))
+		tm = tlm
+	}
+	// showText returns the chunk for the TJ array `elements` and advances the text matrix.
+	showText := func(elements []core.PdfObject) TextChunk {
+		fontSize := state.fontSize
+		trm := func() transform.Matrix {
+			params := transform.NewMatrix(fontSize*state.horizScaling, 0, 0, fontSize, 0, state.rise)
+			return state.ctm.Mult(tm).Mult(params)
+		}
+		start := trm()
+		chunk := TextChunk{FontSize: fontSize, Height: start.ScalingFactorY()}
+		chunk.X, chunk.Y = start.Translation()
+
+		var text strings.Builder
+		for _, obj := range elements {
+			switch v := obj.(type) {
+			case *core.PdfObjectString:
+				var tx float64
+				for _, c := range v.Bytes() {
+					tx += approxGlyphWidth*fontSize + state.charSpacing
+					if c == ' ' {
+						tx += state.wordSpacing
+					}
+				}
+				tm.Concat(transform.TranslationMatrix(tx*state.horizScaling, 0))
+				text.WriteString(v.Str())
+			case *core.PdfObjectFloat, *core.PdfObjectInteger:
+				adj, _ := core.GetNumberAsFloat(v)
+				tm.Concat(transform.TranslationMatrix(-adj/1000*fontSize*state.horizScaling, 0))
+				if state.isWordBreak(adj, csp.wordSpacingCutoff) && !strings.HasSuffix(text.String(), " ") {
+					text.WriteString(" ")
+				}
+			}
+		}
+		chunk.Text = text.String()
+
+		end := trm()
+		endX, endY := end.Translation()
+		chunk.Width = math.Hypot(endX-chunk.X, endY-chunk.Y)
+		return chunk
+	}
+
+	for _, op := range *operations {
+		switch op.Operand {
+		case "q":
+			stateStack = append(stateStack, state)
+		case "Q":
+			if n := len(stateStack); n > 0 {
+				state = stateStack[n-1]
+				stateStack = stateStack[:n-1]
+			}
+		case "cm":
+			if f, err := core.GetNumbersAsFloat(op.Params); err == nil && len(f) == 6 {
+				state.ctm.Concat(transform.NewMatrix(f[0], f[1], f[2], f[3], f[4], f[5]))
+			}
+		case "BT":
+			inText = true
+			tm = transform.IdentityMatrix()
+			tlm = tm
+		case "ET":
+			inText = false
+		case "Tf":
+			if len(op.Params) == 2 {
+				if size, err := core.GetNumberAsFloat(op.Params[1]); err == nil {
+					state.fontSize = math.Abs(size)
+				}
+			}
+		case "Tc", "Tw", "Tz", "TL", "Ts":
+			if len(op.Params) != 1 {
+				continue
+			}
+			v, err := core.GetNumberAsFloat(op.Params[0])
+			if err != nil {
+				continue
+			}
+			switch op.Operand {
+			case "Tc":
+				state.charSpacing = v
+			case "Tw":
+				state.wordSpacing = v
+			case "Tz":
+				state.horizScaling = v / 100
+			case "TL":
+				state.leading = v
+			case "Ts":
+				state.rise = v
+			}
+		case "Td", "TD":
+			f, err := core.GetNumbersAsFloat(op.Params)
+			if !inText || err != nil || len(f) != 2 {
+				continue
+			}
+			if op.Operand == "TD" {
+				state.leading = -f[1]
+			}
+			moveText(f[0], f[1])
+		case "T*":
+			if inText {
+				moveText(0, -state.leading)
+			}
+		case "Tm":
+			f, err := core.GetNumbersAsFloat(op.Params)
+			if !inText || err != nil || len(f) != 6 {
+				continue
+			}
+			tm = transform.NewMatrix(f[0], f[1], f[2], f[3], f[4], f[5])
+			tlm = tm
+		case "Tj":
+			if !inText || len(op.Params) < 1 {
+				continue
+			}
+			if _, ok := op.Params[0].(*core.PdfObjectString); !ok {
+				return nil, fmt.Errorf("invalid parameter type, not string (%T)", op.Params[0])
+			}
+			chunks = append(chunks, showText(op.Params[:1]))
+		case "'", `"`:
+			numParams := 1
+			if op.Operand == `"` {
+				numParams = 3
+			}
+			if !inText || len(op.Params) != numParams {
+				continue
+			}
+			if _, ok := op.Params[numParams-1].(*core.PdfObjectString); !ok {
+				return nil, fmt.Errorf("invalid parameter type, not string (%T)", op.Params[numParams-1])
+			}
+			if numParams == 3 {
+				if f, err := core.GetNumbersAsFloat(op.Params[:2]); err == nil {
+					state.wordSpacing, state.charSpacing = f[0], f[1]
+				}
+			}
+			moveText(0, -state.leading)
+			chunks = append(chunks, showText(op.Params[numParams-1:]))
+		case "TJ":
+			if !inText || len(op.Params) < 1 {
+				continue
+			}
+			paramList, ok := op.Params[0].(*core.PdfObjectArray)
+			if !ok {
+				return nil, fmt.Errorf("invalid parameter type, no array (%T)", op.Params[0])
+			}
+			chunks = append(chunks, showText(paramList.Elements()))
+		}
+	}
+	return chunks, nil
+}
@@ -1,7 +1,15 @@
 package contentstream
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
 )
 
 func TestOperandTJSpacing(t *testing.T) {
@@ -23,3 +31,351 @@ func TestOperandTJSpacing(t *testing.T) {
 	}
 
 }
+
+// TestExtractTextTJWordBreaks tests that TJ adjustments are turned into spaces relative to the
+// font size and the character and word spacing.
+func TestExtractTextTJWordBreaks(t *testing.T) {
+	testcases := []struct {
+		content  string
+		cutoff   float64
+		expected string
+	}{
+		{`BT /F1 10 Tf [(Hello)-250(World)5(!)]TJ ET`, 0, "Hello World!"},
+		// Kerning does not separate words.
+		{`BT /F1 10 Tf [(W)60(or)-20(ld)]TJ ET`, 0, "World"},
+		// Wide letter spacing makes the adjustment relatively smaller.
+		{`BT /F1 10 Tf 3 Tc [(Total)-150(Due)]TJ ET`, 0, "Total Due"},
+		{`BT /F1 10 Tf 3 Tc [(To)-50(tal)]TJ ET`, 0, "Total"},
+		// Tight letter spacing needs a larger adjustment.
+		{`BT /F1 10 Tf -1 Tc [(Total)-150(Due)]TJ ET`, 0, "TotalDue"},
+		// Word spacing in use lowers the threshold.
+		{`BT /F1 10 Tf 1 Tw [(Total)-80(Due)]TJ ET`, 0, "Total Due"},
+		// The text state is restored by Q.
+		{`q BT /F1 10 Tf 1 Tw ET Q BT /F1 10 Tf [(Total)-80(Due)]TJ ET`, 0, "TotalDue"},
+		// Configured cutoff.
+		{`BT /F1 10 Tf [(Total)-250(Due)]TJ ET`, 0.3, "TotalDue"},
+		// No double spaces.
+		{`BT /F1 10 Tf [(Total )-250(Due)]TJ ET`, 0, "Total Due"},
+		// Horizontally scaled text keeps the same threshold relative to its glyphs.
+		{`BT /F1 10 Tf 80 Tz [(Con)-50(densed)-20(Heading)]TJ ET`, 0, "CondensedHeading"},
+		{`BT /F1 10 Tf 80 Tz [(Condensed)-150(Heading)]TJ ET`, 0, "Condensed Heading"},
+		{`BT /F1 10 Tf 80 Tz 1 Tw [(Condensed)-80(Heading)]TJ ET`, 0, "Condensed Heading"},
+		{`q BT /F1 10 Tf 50 Tz ET Q BT /F1 10 Tf [(Total)-80(Due)]TJ ET`, 0, "TotalDue"},
+	}
+
+	for _, tcase := range testcases {
+		csp := NewContentStreamParser(tcase.content)
+		if tcase.cutoff > 0 {
+			csp.SetWordSpacingCutoff(tcase.cutoff)
+		}
+		text, err := csp.ExtractText()
+		require.NoError(t, err)
+		require.Equal(t, tcase.expected, text, tcase.content)
+	}
+}
+
+// TestExtractTextWithPositions tests the baseline positions of text chunks, following the text
+// positioning operators and the current transformation matrix.
+func TestExtractTextWithPositions(t *testing.T) {
+	content := `q 2 0 0 2 10 20 cm
+BT /F1 10 Tf 1 0 0 1 50 700 Tm (Name) Tj
+100 0 Td [(Unit)-250(Price)]TJ
+-100 -14 TD (Widget) Tj
+T* (Gadget) Tj
+ET Q
+BT /F1 12 Tf 72 72 Td (Total) Tj ET`
+
+	chunks, err := NewContentStreamParser(content).ExtractTextWithPositions()
+	require.NoError(t, err)
+
+	type position struct {
+		text          string
+		x, y          float64
+		width, height float64
+	}
+	expected := []position{
+		{"Name", 110, 1420, 40, 20},
+		{"Unit Price", 310, 1420, 2 * (45 + 2.5), 20},
+		{"Widget", 110, 1392, 60, 20},
+		{"Gadget", 110, 1364, 60, 20},
+		{"Total", 72, 72, 30, 12},
+	}
+	require.Len(t, chunks, len(expected))
+	for i, exp := range expected {
+		chunk := chunks[i]
+		require.Equal(t, exp.text, chunk.Text)
+		require.InDelta(t, exp.x, chunk.X, 1e-6, exp.text)
+		require.InDelta(t, exp.y, chunk.Y, 1e-6, exp.text)
+		require.InDelta(t, exp.width, chunk.Width, 1e-6, exp.text)
+		require.InDelta(t, exp.height, chunk.Height, 1e-6, exp.text)
+	}
+}
+
+// TestExtractTextWithPositionsScaling tests that horizontal scaling (Tz) and text rise (Ts) are
+// applied to the positions and widths of text chunks.
+func TestExtractTextWithPositionsScaling(t *testing.T) {
+	content := `BT /F1 10 Tf 80 Tz 3 Ts 1 0 0 1 100 700 Tm [(Con)-50(densed)]TJ
+0 Ts 0 -20 Td (Body) Tj ET`
+
+	chunks, err := NewContentStreamParser(content).ExtractTextWithPositions()
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+
+	require.Equal(t, "Condensed", chunks[0].Text)
+	require.InDelta(t, 100, chunks[0].X, 1e-6)
+	require.InDelta(t, 703, chunks[0].Y, 1e-6)
+	require.InDelta(t, (9*5+0.5)*0.8, chunks[0].Width, 1e-6)
+
+	require.Equal(t, "Body", chunks[1].Text)
+	require.InDelta(t, 100, chunks[1].X, 1e-6)
+	require.InDelta(t, 680, chunks[1].Y, 1e-6)
+	require.InDelta(t, 4*5*0.8, chunks[1].Width, 1e-6)
+}
+
+// TestExtractTextFromResources tests mapping character codes to Unicode with the ToUnicode CMaps
+// of the fonts in the resources, with 1 and 2 byte codespaces.
+func TestExtractTextFromResources(t *testing.T) {
+	makeFont := func(toUnicode string) *core.PdfObjectDictionary {
+		font := core.MakeDict()
+		font.Set("Type", core.MakeName("Font"))
+		if toUnicode != "" {
+			stream, err := core.MakeStream([]byte(toUnicode), nil)
+			require.NoError(t, err)
+			font.Set("ToUnicode", stream)
+		}
+		return font
+	}
+	const simpleCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<00> <FF>
+endcodespacerange
+2 beginbfchar
+<01> <0048>
+<02> <0069>
+endbfchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+	const cidCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+1 beginbfrange
+<0010> <0012> <4E16>
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+
+	resources := model.NewPdfPageResources()
+	require.NoError(t, resources.SetFontByName("F1", makeFont(simpleCMap)))
+	require.NoError(t, resources.SetFontByName("F2", makeFont(cidCMap)))
+	require.NoError(t, resources.SetFontByName("F3", makeFont("")))
+
+	content := `BT /F1 12 Tf (\001\002) Tj /F2 12 Tf [<00100011>-300<0012>] TJ /F3 12 Tf (raw) Tj /F4 12 Tf (none) Tj ET`
+	text, err := NewContentStreamParser(content).ExtractTextFromResources(resources)
+	require.NoError(t, err)
+	require.Equal(t, "Hi\u4e16\u4e17 \u4e18rawnone", text)
+
+	// Without resources the codes are output as is.
+	text, err = NewContentStreamParser(content).ExtractText()
+	require.NoError(t, err)
+	require.Equal(t, "\x01\x02\x00\x10\x00\x11 \x00\x12rawnone", text)
+
+	// Codes of the codespaces without a mapping are output as U+FFFD.
+	content = `BT /F1 12 Tf (\001\003\002) Tj /F2 12 Tf <00100013> Tj ET`
+	text, err = NewContentStreamParser(content).ExtractTextFromResources(resources)
+	require.NoError(t, err)
+	require.Equal(t, "H\ufffdi\u4e16\ufffd", text)
+}
+
+// TestExtractTextQuoteOperators tests the ' and " operators, which move to the next line before
+// showing text.
+func TestExtractTextQuoteOperators(t *testing.T) {
+	content := `BT /F1 10 Tf 14 TL 72 700 Td (First) Tj (Second) ' 2 0 (Third) " ET (Outside) '`
+
+	text, err := NewContentStreamParser(content).ExtractText()
+	require.NoError(t, err)
+	require.Equal(t, "\nFirst\nSecond\nThird", text)
+
+	chunks, err := NewContentStreamParser(content).ExtractTextWithPositions()
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	for i, chunk := range chunks {
+		require.InDelta(t, 72, chunk.X, 1e-6)
+		require.InDelta(t, 700-14*float64(i), chunk.Y, 1e-6)
+	}
+	// The word spacing of " applies to the spaces shown.
+	chunks, err = NewContentStreamParser(`BT /F1 10 Tf 3 0 (a b) " ET`).ExtractTextWithPositions()
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	require.InDelta(t, 18, chunks[0].Width, 1e-6)
+}
+
+// TestParsePageContentStreams tests parsing the concatenated content streams of a page, where
+// the operators at the stream boundaries must not be merged.
+func TestParsePageContentStreams(t *testing.T) {
+	page := model.NewPdfPage()
+	err := page.SetContentStreams([]string{
+		"q BT /F1 12 Tf (a) Tj ET",
+		"BT (b) Tj ET % trailing comment",
+		"BT (c) Tj ET Q",
+	}, nil)
+	require.NoError(t, err)
+
+	content, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+
+	var operands []string
+	for _, op := range *ops {
+		operands = append(operands, op.Operand)
+	}
+	require.Equal(t, []string{
+		"q", "BT", "Tf", "Tj", "ET",
+		"BT", "Tj", "ET",
+		"BT", "Tj", "ET", "Q",
+	}, operands)
+}
+
+// TestOperationsWriteTo tests that WriteTo and Bytes serialize operations the same way as the
+// WriteString method of the operands.
+func TestOperationsWriteTo(t *testing.T) {
+	content := `q 1 0 0 1 -12.5 72 cm /GS#200 gs BT /F1 12 Tf [(Hello) -250 (world)] TJ ET
+/Sh1 sh 0.25 0.5 0.75 rg [3 1] 0 d << /MCID 3 >> BDC EMC Q`
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+
+	var expected bytes.Buffer
+	for _, op := range *ops {
+		for _, param := range op.Params {
+			expected.WriteString(param.WriteString())
+			expected.WriteString(" ")
+		}
+		expected.WriteString(op.Operand + "\n")
+	}
+
+	var buf bytes.Buffer
+	n, err := ops.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	require.Equal(t, expected.String(), buf.String())
+	require.Equal(t, expected.Bytes(), ops.Bytes())
+}
+
+// TestOperationsClone tests that changing the parameters of cloned operations does not affect
+// the original operations.
+func TestOperationsClone(t *testing.T) {
+	content := `q 1 0 0 1 10 20 cm BT /F1 12 Tf [(Page) -250 (1)] TJ ET
+<< /MCID 3 /Props [/A 1] >> BDC EMC BI /W 2 /H 1 /CS /G ID ab EI Q`
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	original := string(ops.Bytes())
+
+	cloned := ops.Clone()
+	require.Equal(t, original, string(cloned.Bytes()))
+
+	for _, op := range cloned {
+		switch op.Operand {
+		case "cm":
+			op.Params[4] = core.MakeFloat(30)
+		case "Tf":
+			*op.Params[0].(*core.PdfObjectName) = "F2"
+		case "TJ":
+			arr := op.Params[0].(*core.PdfObjectArray)
+			require.NoError(t, arr.Set(2, core.MakeString("2")))
+			require.NoError(t, arr.Set(1, core.MakeInteger(-100)))
+		case "BDC":
+			dict := op.Params[0].(*core.PdfObjectDictionary)
+			dict.Set("MCID", core.MakeInteger(4))
+			dict.Get("Props").(*core.PdfObjectArray).Append(core.MakeName("B"))
+		case "BI":
+			img := op.Params[0].(*ContentStreamInlineImage)
+			*img.Width.(*core.PdfObjectInteger) = 1
+			img.stream[0] = 'x'
+		}
+	}
+	cloned = append(cloned, &ContentStreamOperation{Operand: "n"})
+
+	require.Equal(t, original, string(ops.Bytes()))
+	require.NotEqual(t, original, string(cloned.Bytes()))
+	require.Contains(t, string(cloned.Bytes()), "/F2 12 Tf")
+	require.Contains(t, string(cloned.Bytes()), "[(Page) -100 (2)] TJ")
+}
+
+func TestParseConcatenated(t *testing.T) {
+	// The operands of Tf and the TJ array are split across streams, and the last stream does
+	// not separate its first token from the end of the previous stream.
+	streams := [][]byte{
+		[]byte("q BT /F1"),
+		[]byte("12 Tf [(Hello) -250"),
+		[]byte("(World)] TJ ET"),
+		[]byte("Q"),
+	}
+	ops, err := ParseConcatenated(streams)
+	require.NoError(t, err)
+	require.Equal(t, "q\nBT\n/F1 12 Tf\n[(Hello) -250 (World)] TJ\nET\nQ\n", string(ops.Bytes()))
+
+	ops, err = ParseConcatenated(nil)
+	require.NoError(t, err)
+	require.Empty(t, ops)
+}
+
+func TestOperationsBytesSplit(t *testing.T) {
+	content := `q 1 0 0 1 10 20 cm BT /F1 12 Tf [(Page) -250 (1)] TJ ET
+BI /W 2 /H 1 /CS /G ID ab EI Q`
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	data := ops.Bytes()
+
+	require.Equal(t, [][]byte{data}, ops.BytesSplit(0))
+	require.Equal(t, [][]byte{data}, ops.BytesSplit(len(data)))
+
+	for _, maxSize := range []int{1, 10, 20, 40} {
+		chunks := ops.BytesSplit(maxSize)
+		require.True(t, len(chunks) > 1)
+		require.Equal(t, data, bytes.Join(chunks, nil))
+
+		// Each chunk holds whole operations and exceeds the limit only for a single operation.
+		var n int
+		for _, chunk := range chunks {
+			chunkOps, err := NewContentStreamParser(string(chunk)).Parse()
+			require.NoError(t, err)
+			require.Equal(t, string(chunk), string(chunkOps.Bytes()))
+			if len(chunk) > maxSize {
+				require.Len(t, *chunkOps, 1)
+			}
+			n += len(*chunkOps)
+		}
+		require.Len(t, *ops, n)
+
+		joined, err := ParseConcatenated(chunks)
+		require.NoError(t, err)
+		require.Equal(t, string(data), string(joined.Bytes()))
+	}
+
+	require.Empty(t, ContentStreamOperations{}.BytesSplit(10))
+}
+
+func BenchmarkOperationsBytes(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "q %d 0 0 %d 10.5 %d.25 cm /Im%d Do Q\n", i, i, i, i)
+	}
+	ops, err := NewContentStreamParser(sb.String()).Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ops.Bytes()
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// TestContentCreatorBytes tests that the operations added with ContentCreator are written the
+// same as hand-built operations.
+func TestContentCreatorBytes(t *testing.T) {
+	cc := NewContentCreator().
+		Add_q().
+		Add_cm(1, 0, 0, 1, 72, 72.5).
+		Add_rg(1, 0, 0).
+		Add_re(0, 0, 100, 50).
+		Add_f().
+		Add_BT().
+		Add_Tf("F1", 12).
+		Add_Td(10, 20).
+		Add_Tj(*core.MakeString("Hello")).
+		Add_ET().
+		Add_Q()
+
+	floats := func(vals ...float64) []core.PdfObject {
+		var params []core.PdfObject
+		for _, val := range vals {
+			params = append(params, core.MakeFloat(val))
+		}
+		return params
+	}
+	ops := ContentStreamOperations{
+		{Operand: "q"},
+		{Operand: "cm", Params: floats(1, 0, 0, 1, 72, 72.5)},
+		{Operand: "rg", Params: floats(1, 0, 0)},
+		{Operand: "re", Params: floats(0, 0, 100, 50)},
+		{Operand: "f"},
+		{Operand: "BT"},
+		{Operand: "Tf", Params: append([]core.PdfObject{core.MakeName("F1")}, floats(12)...)},
+		{Operand: "Td", Params: floats(10, 20)},
+		{Operand: "Tj", Params: []core.PdfObject{core.MakeString("Hello")}},
+		{Operand: "ET"},
+		{Operand: "Q"},
+	}
+
+	require.Equal(t, ops.Bytes(), cc.Bytes())
+	require.Equal(t, "q\n1 0 0 1 72 72.5 cm\n1 0 0 rg\n0 0 100 50 re\nf\nBT\n/F1 12 Tf\n10 20 Td\n(Hello) Tj\nET\nQ\n",
+		cc.String())
+	require.Len(t, *cc.Operations(), len(ops))
+}
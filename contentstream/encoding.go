@@ -57,20 +57,20 @@ func newEncoderFromInlineImage(inlineImage *ContentStreamInlineImage) (core.Stre
 	// From Table 94 p. 224 (PDF32000_2008):
 	// Additional Abbreviations in an Inline Image Object:
 
-	switch *filterName {
-	case "AHx", "ASCIIHexDecode":
+	switch expandInlineFilterName(*filterName) {
+	case core.StreamEncodingFilterNameASCIIHex:
 		return core.NewASCIIHexEncoder(), nil
-	case "A85", "ASCII85Decode":
+	case core.StreamEncodingFilterNameASCII85:
 		return core.NewASCII85Encoder(), nil
-	case "DCT", "DCTDecode":
+	case core.StreamEncodingFilterNameDCT:
 		return newDCTEncoderFromInlineImage(inlineImage)
-	case "Fl", "FlateDecode":
+	case core.StreamEncodingFilterNameFlate:
 		return newFlateEncoderFromInlineImage(inlineImage, nil)
-	case "LZW", "LZWDecode":
+	case core.StreamEncodingFilterNameLZW:
 		return newLZWEncoderFromInlineImage(inlineImage, nil)
-	case "CCF", "CCITTFaxDecode":
-		return core.NewCCITTFaxEncoder(), nil
-	case "RL", "RunLengthDecode":
+	case core.StreamEncodingFilterNameCCITTFax:
+		return newCCITTFaxEncoderFromInlineImage(inlineImage, nil)
+	case core.StreamEncodingFilterNameRunLength:
 		return core.NewRunLengthEncoder(), nil
 	default:
 		common.Log.Debug("Unsupported inline image encoding filter name : %s", *filterName)
@@ -253,6 +253,38 @@ func newLZWEncoderFromInlineImage(inlineImage *ContentStreamInlineImage, decodeP
 	return encoder, nil
 }
 
+// Create a new CCITTFax decoder from an inline image object, getting the encoding parameters (K,
+// Columns, Rows, BlackIs1, EncodedByteAlign, ...) from the DecodeParms that can be provided
+// optionally, usually only when a multi filter is used. Rows defaults to the image height.
+func newCCITTFaxEncoderFromInlineImage(inlineImage *ContentStreamInlineImage, decodeParams *core.PdfObjectDictionary) (*core.CCITTFaxEncoder, error) {
+	// Start with default settings.
+	encoder := core.NewCCITTFaxEncoder()
+	if height, ok := core.GetIntVal(inlineImage.Height); ok {
+		encoder.Rows = height
+	}
+
+	// If decodeParams not provided, see if we can get from the inline image directly.
+	if decodeParams == nil {
+		if inlineImage.DecodeParms != nil {
+			dp, isDict := core.GetDict(inlineImage.DecodeParms)
+			if !isDict {
+				common.Log.Debug("Error: DecodeParms not a dictionary (%T)", inlineImage.DecodeParms)
+				return nil, fmt.Errorf("invalid DecodeParms")
+			}
+			decodeParams = dp
+		}
+	}
+
+	if decodeParams != nil {
+		encoder.UpdateParams(decodeParams)
+		common.Log.Trace("decode params: %s", decodeParams.String())
+	}
+	if encoder.Columns <= 0 {
+		return nil, fmt.Errorf("invalid Columns")
+	}
+	return encoder, nil
+}
+
 // Create a new DCT encoder/decoder based on an inline image, getting all the encoding parameters
 // from the stream object dictionary entry and the image data itself.
 func newDCTEncoderFromInlineImage(inlineImage *ContentStreamInlineImage) (*core.DCTEncoder, error) {
@@ -365,26 +397,33 @@ func newMultiEncoderFromInlineImage(inlineImage *ContentStreamInlineImage) (*cor
 			dParams = dict
 		}
 
-		if *name == core.StreamEncodingFilterNameFlate || *name == "Fl" {
+		switch expandInlineFilterName(*name) {
+		case core.StreamEncodingFilterNameFlate:
 			// TODO: need to separate out the DecodeParms..
 			encoder, err := newFlateEncoderFromInlineImage(inlineImage, dParams)
 			if err != nil {
 				return nil, err
 			}
 			mencoder.AddEncoder(encoder)
-		} else if *name == core.StreamEncodingFilterNameLZW {
+		case core.StreamEncodingFilterNameLZW:
 			encoder, err := newLZWEncoderFromInlineImage(inlineImage, dParams)
 			if err != nil {
 				return nil, err
 			}
 			mencoder.AddEncoder(encoder)
-		} else if *name == core.StreamEncodingFilterNameASCIIHex {
-			encoder := core.NewASCIIHexEncoder()
-			mencoder.AddEncoder(encoder)
-		} else if *name == core.StreamEncodingFilterNameASCII85 || *name == "A85" {
-			encoder := core.NewASCII85Encoder()
+		case core.StreamEncodingFilterNameASCIIHex:
+			mencoder.AddEncoder(core.NewASCIIHexEncoder())
+		case core.StreamEncodingFilterNameASCII85:
+			mencoder.AddEncoder(core.NewASCII85Encoder())
+		case core.StreamEncodingFilterNameRunLength:
+			mencoder.AddEncoder(core.NewRunLengthEncoder())
+		case core.StreamEncodingFilterNameCCITTFax:
+			encoder, err := newCCITTFaxEncoderFromInlineImage(inlineImage, dParams)
+			if err != nil {
+				return nil, err
+			}
 			mencoder.AddEncoder(encoder)
-		} else {
+		default:
 			common.Log.Error("Unsupported filter %s", *name)
 			return nil, fmt.Errorf("invalid filter in multi filter array")
 		}
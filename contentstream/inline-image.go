@@ -6,10 +6,15 @@
 package contentstream
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
+	goimage "image"
+	gocolor "image/color"
+	"image/jpeg"
 	"io"
+	"math"
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
@@ -29,6 +34,7 @@ type ContentStreamInlineImage struct {
 	Intent           core.PdfObject
 	Interpolate      core.PdfObject
 	Width            core.PdfObject
+	length           core.PdfObject // Length of the image data (L), if specified.
 	stream           []byte
 }
 
@@ -151,6 +157,24 @@ func (img *ContentStreamInlineImage) WriteString() string {
 	return output.String()
 }
 
+// clone returns a deep copy of the inline image.
+func (img *ContentStreamInlineImage) clone() *ContentStreamInlineImage {
+	return &ContentStreamInlineImage{
+		BitsPerComponent: core.CloneObject(img.BitsPerComponent),
+		ColorSpace:       core.CloneObject(img.ColorSpace),
+		Decode:           core.CloneObject(img.Decode),
+		DecodeParms:      core.CloneObject(img.DecodeParms),
+		Filter:           core.CloneObject(img.Filter),
+		Height:           core.CloneObject(img.Height),
+		ImageMask:        core.CloneObject(img.ImageMask),
+		Intent:           core.CloneObject(img.Intent),
+		Interpolate:      core.CloneObject(img.Interpolate),
+		Width:            core.CloneObject(img.Width),
+		length:           core.CloneObject(img.length),
+		stream:           append([]byte(nil), img.stream...),
+	}
+}
+
 // GetColorSpace returns the colorspace of the inline image.
 func (img *ContentStreamInlineImage) GetColorSpace(resources *model.PdfPageResources) (model.PdfColorspace, error) {
 	if img.ColorSpace == nil {
@@ -170,16 +194,17 @@ func (img *ContentStreamInlineImage) GetColorSpace(resources *model.PdfPageResou
 		return nil, errors.New("type check error")
 	}
 
-	if *name == "G" || *name == "DeviceGray" {
+	switch expandInlineColorspaceName(*name) {
+	case "DeviceGray":
 		return model.NewPdfColorspaceDeviceGray(), nil
-	} else if *name == "RGB" || *name == "DeviceRGB" {
+	case "DeviceRGB":
 		return model.NewPdfColorspaceDeviceRGB(), nil
-	} else if *name == "CMYK" || *name == "DeviceCMYK" {
+	case "DeviceCMYK":
 		return model.NewPdfColorspaceDeviceCMYK(), nil
-	} else if *name == "I" || *name == "Indexed" {
+	case "Indexed":
 		return nil, errors.New("unsupported Index colorspace")
-	} else {
-		if resources.ColorSpace == nil {
+	default:
+		if resources == nil || resources.ColorSpace == nil {
 			// Can also refer to a name in the PDF page resources...
 			common.Log.Debug("Error, unsupported inline image colorspace: %s", *name)
 			return nil, errors.New("unknown colorspace")
@@ -194,7 +219,6 @@ func (img *ContentStreamInlineImage) GetColorSpace(resources *model.PdfPageResou
 
 		return cs, nil
 	}
-
 }
 
 // GetEncoder returns the encoder of the inline image.
@@ -222,6 +246,15 @@ func (img *ContentStreamInlineImage) IsMask() (bool, error) {
 // ToImage exports the inline image to Image which can be transformed or exported easily.
 // Page resources are needed to look up colorspace information.
 func (img *ContentStreamInlineImage) ToImage(resources *model.PdfPageResources) (*model.Image, error) {
+	// DCT encoded images carry a full JPEG which determines the image dimensions and components.
+	jpegData, isDCT, err := img.getDCTData()
+	if err != nil {
+		return nil, err
+	}
+	if isDCT {
+		return img.dctToImage(jpegData)
+	}
+
 	// Decode the imaging data if encoded.
 	encoder, err := newEncoderFromInlineImage(img)
 	if err != nil {
@@ -263,6 +296,8 @@ func (img *ContentStreamInlineImage) ToImage(resources *model.PdfPageResources)
 		return nil, err
 	}
 
+	// Default decode ranges of the color components.
+	ranges := []float64{0, 1}
 	if isMask {
 		// Masks are grayscale 1bpc.
 		image.BitsPerComponent = 1
@@ -288,6 +323,10 @@ func (img *ContentStreamInlineImage) ToImage(resources *model.PdfPageResources)
 				return nil, err
 			}
 			image.ColorComponents = cs.GetNumComponents()
+			ranges = cs.DecodeArray()
+			if _, isIndexed := cs.(*model.PdfColorspaceSpecialIndexed); isIndexed {
+				ranges = []float64{0, float64(int(1)<<uint(image.BitsPerComponent) - 1)}
+			}
 		} else {
 			// Default gray if not specified.
 			common.Log.Debug("Inline Image colorspace not specified - assuming 1 color component")
@@ -295,11 +334,494 @@ func (img *ContentStreamInlineImage) ToImage(resources *model.PdfPageResources)
 		}
 	}
 
+	if img.Decode != nil {
+		decode, err := getInlineImageDecode(img.Decode, image.ColorComponents)
+		if err != nil {
+			common.Log.Debug("Ignoring invalid inline image decode array: %v", err)
+		} else if len(ranges) == len(decode) {
+			decoded = applyDecodeArray(decoded, image, decode, ranges)
+		}
+	}
 	image.Data = decoded
 
 	return image, nil
 }
 
+// getDCTData returns the JPEG data of the inline image if its last filter is DCTDecode.
+// Any filters preceding DCTDecode, e.g. [/AHx /DCT], are decoded first.
+func (img *ContentStreamInlineImage) getDCTData() ([]byte, bool, error) {
+	var filters []core.PdfObject
+	switch t := img.Filter.(type) {
+	case *core.PdfObjectName:
+		filters = []core.PdfObject{t}
+	case *core.PdfObjectArray:
+		filters = t.Elements()
+	}
+	if len(filters) == 0 {
+		return nil, false, nil
+	}
+	name, ok := core.GetName(filters[len(filters)-1])
+	if !ok || expandInlineFilterName(*name) != core.StreamEncodingFilterNameDCT {
+		return nil, false, nil
+	}
+	if len(filters) == 1 {
+		return img.stream, true, nil
+	}
+
+	// Decode the filters preceding DCTDecode.
+	preceding := *img
+	preceding.Filter = core.MakeArray(filters[:len(filters)-1]...)
+	if dp, ok := core.GetArray(img.DecodeParms); ok && dp.Len() == len(filters) {
+		preceding.DecodeParms = core.MakeArray(dp.Elements()[:len(filters)-1]...)
+	}
+	encoder, err := newEncoderFromInlineImage(&preceding)
+	if err != nil {
+		return nil, true, err
+	}
+	data, err := encoder.DecodeBytes(img.stream)
+	if err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// dctToImage returns the image encoded by the JPEG `data` of a DCT encoded inline image.
+// The dimensions and number of color components are taken from the JPEG rather than from the
+// inline image parameters.
+func (img *ContentStreamInlineImage) dctToImage(data []byte) (*model.Image, error) {
+	goimg, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		common.Log.Debug("Error decoding inline JPEG: %v", err)
+		return nil, err
+	}
+	bounds := goimg.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	image := &model.Image{
+		Width:            int64(width),
+		Height:           int64(height),
+		BitsPerComponent: 8,
+	}
+
+	switch t := goimg.(type) {
+	case *goimage.Gray:
+		image.ColorComponents = 1
+		image.Data = make([]byte, 0, width*height)
+		for y := 0; y < height; y++ {
+			i := y * t.Stride
+			image.Data = append(image.Data, t.Pix[i:i+width]...)
+		}
+	case *goimage.CMYK:
+		image.ColorComponents = 4
+		image.Data = make([]byte, 0, 4*width*height)
+		for y := 0; y < height; y++ {
+			i := y * t.Stride
+			image.Data = append(image.Data, t.Pix[i:i+4*width]...)
+		}
+		// The jpeg package undoes the inversion of Adobe CMYK JPEGs. PDF producers compensate
+		// for that inversion with an inverted Decode array, so restore the stored samples.
+		if _, isAdobe := getJPEGAdobeTransform(data); isAdobe {
+			for i := range image.Data {
+				image.Data[i] = 255 - image.Data[i]
+			}
+		}
+	default:
+		image.ColorComponents = 3
+		image.Data = make([]byte, 0, 3*width*height)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := gocolor.RGBAModel.Convert(goimg.At(x, y)).(gocolor.RGBA)
+				image.Data = append(image.Data, c.R, c.G, c.B)
+			}
+		}
+	}
+
+	if img.Decode != nil {
+		decode, err := getInlineImageDecode(img.Decode, image.ColorComponents)
+		if err != nil {
+			common.Log.Debug("Ignoring invalid inline image decode array: %v", err)
+		} else {
+			ranges := make([]float64, 0, len(decode))
+			for i := 0; i < image.ColorComponents; i++ {
+				ranges = append(ranges, 0, 1)
+			}
+			image.Data = applyDecodeArray(image.Data, image, decode, ranges)
+		}
+	}
+
+	return image, nil
+}
+
+// getJPEGAdobeTransform returns the color transform of the Adobe APP14 marker segment of the
+// JPEG `data`. The returned bool is false if the JPEG has no Adobe marker.
+func getJPEGAdobeTransform(data []byte) (byte, bool) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0, false
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 0, false
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill byte.
+			i++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan or end of image: no more marker segments before the image data.
+			return 0, false
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		segment := data[i+4:]
+		if length-2 < len(segment) {
+			segment = segment[:length-2]
+		}
+		// APP14: "Adobe", version (2), flags0 (2), flags1 (2), transform (1).
+		if marker == 0xee && len(segment) >= 12 && string(segment[:5]) == "Adobe" {
+			return segment[11], true
+		}
+		i += 2 + length
+	}
+	return 0, false
+}
+
+// getInlineImageDecode returns the numbers of the Decode array `obj` of an inline image with
+// `components` color components.
+func getInlineImageDecode(obj core.PdfObject, components int) ([]float64, error) {
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return nil, fmt.Errorf("decode not an array (%T)", obj)
+	}
+	decode, err := core.GetNumbersAsFloat(arr.Elements())
+	if err != nil {
+		return nil, err
+	}
+	if len(decode) != 2*components {
+		return nil, fmt.Errorf("decode array length %d != %d", len(decode), 2*components)
+	}
+	return decode, nil
+}
+
+// applyDecodeArray returns the samples of `data`, with the dimensions of `img`, remapped so that
+// they map to the same values with the default decode `ranges` as they do with `decode`.
+// The data is returned unchanged if `decode` is the default.
+func applyDecodeArray(data []byte, img *model.Image, decode, ranges []float64) []byte {
+	isDefault := true
+	for i := range decode {
+		if decode[i] != ranges[i] {
+			isDefault = false
+			break
+		}
+	}
+	if isDefault {
+		return data
+	}
+
+	bpc := int(img.BitsPerComponent)
+	components := img.ColorComponents
+	maxVal := float64(int(1)<<uint(bpc) - 1)
+	rowBytes := (int(img.Width)*components*bpc + 7) / 8
+
+	remapped := make([]byte, len(data))
+	copy(remapped, data)
+	for row := 0; row < int(img.Height); row++ {
+		for i := 0; i < int(img.Width)*components; i++ {
+			bitPos := row*rowBytes*8 + i*bpc
+			if (bitPos+bpc+7)/8 > len(remapped) {
+				return remapped
+			}
+			c := 2 * (i % components)
+			dMin, dMax := decode[c], decode[c+1]
+			rMin, rMax := ranges[c], ranges[c+1]
+
+			val := float64(getSample(remapped, bitPos, bpc))
+			val = dMin + val*(dMax-dMin)/maxVal
+			val = math.Round((val - rMin) / (rMax - rMin) * maxVal)
+			val = math.Max(0, math.Min(maxVal, val))
+			setSample(remapped, bitPos, bpc, uint32(val))
+		}
+	}
+	return remapped
+}
+
+// getSample returns the `bpc` bits sample at bit position `bitPos` of `data`.
+func getSample(data []byte, bitPos, bpc int) uint32 {
+	switch bpc {
+	case 8:
+		return uint32(data[bitPos/8])
+	case 16:
+		return uint32(data[bitPos/8])<<8 | uint32(data[bitPos/8+1])
+	}
+	shift := uint(8 - bitPos%8 - bpc)
+	return uint32(data[bitPos/8]>>shift) & (1<<uint(bpc) - 1)
+}
+
+// setSample sets the `bpc` bits sample at bit position `bitPos` of `data` to `val`.
+func setSample(data []byte, bitPos, bpc int, val uint32) {
+	switch bpc {
+	case 8:
+		data[bitPos/8] = byte(val)
+		return
+	case 16:
+		data[bitPos/8] = byte(val >> 8)
+		data[bitPos/8+1] = byte(val)
+		return
+	}
+	shift := uint(8 - bitPos%8 - bpc)
+	mask := byte(1<<uint(bpc)-1) << shift
+	data[bitPos/8] = data[bitPos/8]&^mask | byte(val)<<shift&mask
+}
+
+// dataLength returns the length in bytes of the image data of `img`, or -1 if it cannot be
+// determined. The length is given by the L entry or, for unfiltered data, by decodedLength.
+func (img *ContentStreamInlineImage) dataLength() int {
+	if img.length != nil {
+		if n, ok := core.GetIntVal(img.length); ok && n >= 0 {
+			return n
+		}
+		common.Log.Debug("Ignoring invalid inline image length: %v", img.length)
+	}
+	if img.Filter != nil {
+		if arr, ok := core.GetArray(img.Filter); !ok || arr.Len() > 0 {
+			return -1
+		}
+	}
+	return img.decodedLength()
+}
+
+// decodedLength returns the length in bytes of the decoded image data of `img`, computed from the
+// dimensions, colorspace and bits per component of the image, or -1 if it cannot be determined.
+func (img *ContentStreamInlineImage) decodedLength() int {
+	width, ok := core.GetIntVal(img.Width)
+	if !ok || width <= 0 {
+		return -1
+	}
+	height, ok := core.GetIntVal(img.Height)
+	if !ok || height <= 0 {
+		return -1
+	}
+	isMask, err := img.IsMask()
+	if err != nil {
+		return -1
+	}
+
+	bpc, components := 1, 1
+	if !isMask {
+		bpc = 8
+		if img.BitsPerComponent != nil {
+			if bpc, ok = core.GetIntVal(img.BitsPerComponent); !ok || bpc <= 0 {
+				return -1
+			}
+		}
+		if components = inlineImageComponents(img.ColorSpace); components <= 0 {
+			return -1
+		}
+	}
+	return (width*components*bpc + 7) / 8 * height
+}
+
+// inlineImageComponents returns the number of color components of the inline image colorspace
+// `cs`, or 0 if it refers to a colorspace in the page resources.
+func inlineImageComponents(cs core.PdfObject) int {
+	if cs == nil {
+		return 1
+	}
+	if _, isArr := cs.(*core.PdfObjectArray); isArr {
+		// Indexed colorspace.
+		return 1
+	}
+	name, ok := core.GetName(cs)
+	if !ok {
+		return 0
+	}
+	switch expandInlineColorspaceName(*name) {
+	case "DeviceGray":
+		return 1
+	case "DeviceRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	}
+	return 0
+}
+
+// readInlineImageData reads `n` bytes of image data of `img` and returns true if they are followed
+// by the EI operator, which is consumed. Otherwise, the data is left unread and false is returned.
+func (csp *ContentStreamParser) readInlineImageData(img *ContentStreamInlineImage, n int) (bool, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, csp.reader, int64(n)); err != nil {
+		if err == io.EOF {
+			csp.unread(buf.Bytes())
+			return false, nil
+		}
+		return false, err
+	}
+	data := buf.Bytes()
+
+	var skipped int
+	for {
+		b, err := csp.reader.Peek(skipped + 3)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if len(b) > skipped && core.IsWhiteSpace(b[skipped]) {
+			skipped++
+			continue
+		}
+		b = b[skipped:]
+		if len(b) >= 2 && b[0] == 'E' && b[1] == 'I' && (len(b) == 2 || core.IsWhiteSpace(b[2]) || core.IsDelimiter(b[2])) {
+			csp.reader.Discard(skipped + 2)
+			img.stream = data
+			return true, nil
+		}
+		break
+	}
+
+	csp.unread(data)
+	return false, nil
+}
+
+// unread pushes `data` back in front of the remaining content stream data.
+func (csp *ContentStreamParser) unread(data []byte) {
+	csp.reader = bufio.NewReader(io.MultiReader(bytes.NewReader(data), csp.reader))
+}
+
+// scanInlineImageData reads the image data of `img` up to the EI operator when the length of the
+// data is not known.
+func (csp *ContentStreamParser) scanInlineImageData(img *ContentStreamInlineImage) error {
+	// There is no good way to know where the data ends, so read until "<ws>EI<ws>" (where <ws> is
+	// whitespace) followed by valid operands, although that could be part of the data (even if
+	// unlikely). When the data is filtered, the end is only accepted if the data decodes cleanly
+	// to the expected length. Otherwise, the first plausible end is used.
+	var encoder core.StreamEncoder
+	if img.Filter != nil {
+		if enc, err := newEncoderFromInlineImage(img); err == nil {
+			encoder = enc
+		}
+	}
+	decodedLength := img.decodedLength()
+	fallback := -1 // Length of the data at the first plausible end, if it does not decode.
+	var fallbackSkip int
+
+	img.stream = []byte{}
+	state := 0
+	var skipBytes []byte
+	for {
+		c, err := csp.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF && fallback >= 0 {
+				common.Log.Debug("Inline image data does not decode - using first end of image EI")
+				rest := append(img.stream[fallback+fallbackSkip:], skipBytes...)
+				csp.unread(append([]byte{}, rest...))
+				img.stream = img.stream[:fallback]
+				return nil
+			}
+			common.Log.Debug("Unable to find end of image EI in inline image data")
+			return err
+		}
+
+		if state == 0 {
+			if core.IsWhiteSpace(c) {
+				skipBytes = []byte{}
+				skipBytes = append(skipBytes, c)
+				state = 1
+			} else if c == 'E' {
+				// Allow cases where EI is not preceded by whitespace.
+				// The extra parsing after EI<ws> should be sufficient
+				// in order to decide if the image stream ended.
+				skipBytes = append(skipBytes, c)
+				state = 2
+			} else {
+				img.stream = append(img.stream, c)
+			}
+		} else if state == 1 {
+			skipBytes = append(skipBytes, c)
+			if c == 'E' {
+				state = 2
+			} else {
+				img.stream = append(img.stream, skipBytes...)
+				skipBytes = []byte{} // Clear.
+				// Need an extra check to decide if we fall back to state 0 or 1.
+				if core.IsWhiteSpace(c) {
+					state = 1
+				} else {
+					state = 0
+				}
+			}
+		} else if state == 2 {
+			skipBytes = append(skipBytes, c)
+			if c == 'I' {
+				state = 3
+			} else {
+				img.stream = append(img.stream, skipBytes...)
+				skipBytes = []byte{} // Clear.
+				state = 0
+			}
+		} else if state == 3 {
+			skipBytes = append(skipBytes, c)
+			if core.IsWhiteSpace(c) {
+				// Whitspace after EI.
+				// To ensure that is not a part of encoded image data: Peek up to 20 bytes ahead
+				// and check that the following data is valid objects/operands.
+				peekbytes, err := csp.reader.Peek(20)
+				if err != nil && err != io.EOF {
+					return err
+				}
+				dummyParser := NewContentStreamParser(string(peekbytes))
+
+				// Assume is done, check that the following 3 objects/operands are valid.
+				isDone := true
+				for i := 0; i < 3; i++ {
+					op, isOp, err := dummyParser.parseObject()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						continue
+					}
+					if isOp && !isValidOperand(op.String()) {
+						isDone = false
+						break
+					}
+				}
+
+				if isDone && encoder != nil {
+					// Some decoders return the data decoded so far for truncated data.
+					decoded, err := encoder.DecodeBytes(img.stream)
+					if err == nil && len(decoded) < decodedLength {
+						err = fmt.Errorf("decoded %d of %d bytes", len(decoded), decodedLength)
+					}
+					if err != nil {
+						common.Log.Trace("Inline image data does not decode at EI (%d bytes): %v", len(img.stream), err)
+						if fallback < 0 {
+							fallback, fallbackSkip = len(img.stream), len(skipBytes)
+						}
+						isDone = false
+					}
+				}
+
+				if isDone {
+					// Valid object or operand found, i.e. the EI marks the end of the data.
+					// -> image data finished.
+					if len(img.stream) > 100 {
+						common.Log.Trace("Image stream (%d): % x ...", len(img.stream), img.stream[:100])
+					} else {
+						common.Log.Trace("Image stream (%d): % x", len(img.stream), img.stream)
+					}
+					// Exit point.
+					return nil
+				}
+			}
+
+			// Seems like "<ws>EI" was part of the data.
+			img.stream = append(img.stream, skipBytes...)
+			skipBytes = []byte{} // Clear.
+			state = 0
+		}
+	}
+}
+
 // ParseInlineImage parses an inline image from a content stream, both reading its properties and binary data.
 // When called, "BI" has already been read from the stream.  This function
 // finishes reading through "EI" and then returns the ContentStreamInlineImage.
@@ -361,7 +883,9 @@ func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, e
 				im.Interpolate = valueObj
 			case "W", "Width":
 				im.Width = valueObj
-			case "Length", "Subtype", "Type":
+			case "L", "Length":
+				im.length = valueObj
+			case "Subtype", "Type":
 				common.Log.Debug("Ignoring inline parameter %s", *param)
 			default:
 				return nil, fmt.Errorf("unknown inline image parameter %s", *param)
@@ -392,105 +916,21 @@ func (csp *ContentStreamParser) ParseInlineImage() (*ContentStreamInlineImage, e
 					csp.reader.Discard(1)
 				}
 
-				// Unfortunately there is no good way to know how many bytes to read since it
-				// depends on the Filter and encoding etc.
-				// Therefore we will simply read until we find "<ws>EI<ws>" where <ws> is whitespace
-				// although of course that could be a part of the data (even if unlikely).
-				im.stream = []byte{}
-				state := 0
-				var skipBytes []byte
-				for {
-					c, err := csp.reader.ReadByte()
+				// When the length of the data is known, read exactly that many bytes.
+				if n := im.dataLength(); n >= 0 {
+					ok, err := csp.readInlineImageData(&im, n)
 					if err != nil {
-						common.Log.Debug("Unable to find end of image EI in inline image data")
 						return nil, err
 					}
-
-					if state == 0 {
-						if core.IsWhiteSpace(c) {
-							skipBytes = []byte{}
-							skipBytes = append(skipBytes, c)
-							state = 1
-						} else if c == 'E' {
-							// Allow cases where EI is not preceded by whitespace.
-							// The extra parsing after EI<ws> should be sufficient
-							// in order to decide if the image stream ended.
-							skipBytes = append(skipBytes, c)
-							state = 2
-						} else {
-							im.stream = append(im.stream, c)
-						}
-					} else if state == 1 {
-						skipBytes = append(skipBytes, c)
-						if c == 'E' {
-							state = 2
-						} else {
-							im.stream = append(im.stream, skipBytes...)
-							skipBytes = []byte{} // Clear.
-							// Need an extra check to decide if we fall back to state 0 or 1.
-							if core.IsWhiteSpace(c) {
-								state = 1
-							} else {
-								state = 0
-							}
-						}
-					} else if state == 2 {
-						skipBytes = append(skipBytes, c)
-						if c == 'I' {
-							state = 3
-						} else {
-							im.stream = append(im.stream, skipBytes...)
-							skipBytes = []byte{} // Clear.
-							state = 0
-						}
-					} else if state == 3 {
-						skipBytes = append(skipBytes, c)
-						if core.IsWhiteSpace(c) {
-							// Whitspace after EI.
-							// To ensure that is not a part of encoded image data: Peek up to 20 bytes ahead
-							// and check that the following data is valid objects/operands.
-							peekbytes, err := csp.reader.Peek(20)
-							if err != nil && err != io.EOF {
-								return nil, err
-							}
-							dummyParser := NewContentStreamParser(string(peekbytes))
-
-							// Assume is done, check that the following 3 objects/operands are valid.
-							isDone := true
-							for i := 0; i < 3; i++ {
-								op, isOp, err := dummyParser.parseObject()
-								if err != nil {
-									if err == io.EOF {
-										break
-									}
-									continue
-								}
-								if isOp && !isValidOperand(op.String()) {
-									isDone = false
-									break
-								}
-							}
-
-							if isDone {
-								// Valid object or operand found, i.e. the EI marks the end of the data.
-								// -> image data finished.
-								if len(im.stream) > 100 {
-									common.Log.Trace("Image stream (%d): % x ...", len(im.stream), im.stream[:100])
-								} else {
-									common.Log.Trace("Image stream (%d): % x", len(im.stream), im.stream)
-								}
-								// Exit point.
-								return &im, nil
-							}
-						}
-
-						// Seems like "<ws>EI" was part of the data.
-						im.stream = append(im.stream, skipBytes...)
-						skipBytes = []byte{} // Clear.
-						state = 0
+					if ok {
+						return &im, nil
 					}
+					common.Log.Debug("Inline image data not followed by EI - scanning for end of data")
 				}
-				// Never reached (exit point is at end of EI).
+				if err := csp.scanInlineImageData(&im); err != nil {
+					return nil, err
+				}
+				return &im, nil
 			}
 		}
 	}
@@ -0,0 +1,304 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	goimage "image"
+	gocolor "image/color"
+	"image/jpeg"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// parseInlineImage parses the first inline image in `content`.
+func parseInlineImage(t *testing.T, content string) *ContentStreamInlineImage {
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	for _, op := range *ops {
+		if op.Operand == "BI" {
+			img, ok := op.Params[0].(*ContentStreamInlineImage)
+			require.True(t, ok)
+			return img
+		}
+	}
+	t.Fatalf("no inline image in %q", content)
+	return nil
+}
+
+// TestInlineImageAbbreviations tests decoding inline images with abbreviated filter and
+// colorspace names.
+func TestInlineImageAbbreviations(t *testing.T) {
+	data := []byte{0x00, 0x40, 0x80, 0xc0, 0xff, 0x10}
+
+	flate, err := core.NewFlateEncoder().EncodeBytes(data)
+	require.NoError(t, err)
+	runLength, err := core.NewRunLengthEncoder().EncodeBytes(data)
+	require.NoError(t, err)
+
+	testcases := []struct {
+		params     string
+		stream     string
+		components int
+	}{
+		{"/W 2 /H 1 /CS /RGB /F /AHx", hex.EncodeToString(data) + ">", 3},
+		{"/W 3 /H 2 /CS /G /F [/AHx]", hex.EncodeToString(data) + ">", 1},
+		{"/W 2 /H 1 /CS /RGB /F /A85", encodeASCII85(t, data), 3},
+		{"/W 2 /H 1 /CS /RGB /F [/AHx /Fl]", hex.EncodeToString(flate) + ">", 3},
+		{"/W 2 /H 1 /CS /RGB /F [/A85 /Fl]", encodeASCII85(t, flate), 3},
+		{"/W 2 /H 1 /CS /RGB /F [/AHx /RL]", hex.EncodeToString(runLength) + ">", 3},
+		{"/W 3 /H 2 /CS [/I /G 255 <00>] /F /AHx", hex.EncodeToString(data) + ">", 1},
+	}
+
+	for _, tcase := range testcases {
+		img := parseInlineImage(t, "q BI "+tcase.params+" /BPC 8 ID "+tcase.stream+" EI Q")
+		image, err := img.ToImage(nil)
+		require.NoError(t, err, tcase.params)
+		require.Equal(t, data, image.Data, tcase.params)
+		require.Equal(t, tcase.components, image.ColorComponents, tcase.params)
+	}
+}
+
+// encodeASCII85 returns `data` encoded with ASCII85.
+func encodeASCII85(t *testing.T, data []byte) string {
+	encoded, err := core.NewASCII85Encoder().EncodeBytes(data)
+	require.NoError(t, err)
+	return string(encoded)
+}
+
+// TestInlineImageDecodeArray tests that the Decode array of inline images is applied to the
+// image data.
+func TestInlineImageDecodeArray(t *testing.T) {
+	testcases := []struct {
+		name     string
+		params   string
+		data     []byte
+		expected []byte
+	}{
+		{"gray", "/W 3 /H 1 /CS /G /BPC 8", []byte{0x00, 0x40, 0xff}, []byte{0x00, 0x40, 0xff}},
+		{"gray default", "/W 3 /H 1 /CS /G /BPC 8 /D [0 1]", []byte{0x00, 0x40, 0xff}, []byte{0x00, 0x40, 0xff}},
+		{"gray inverted", "/W 3 /H 1 /CS /G /BPC 8 /D [1 0]", []byte{0x00, 0x40, 0xff}, []byte{0xff, 0xbf, 0x00}},
+		{"gray half", "/W 3 /H 1 /CS /G /BPC 8 /D [0 0.5]", []byte{0x00, 0x40, 0xff}, []byte{0x00, 0x20, 0x80}},
+		// Rows of 4 bit samples are padded to whole bytes.
+		{"gray 4 bit inverted", "/W 3 /H 2 /CS /G /BPC 4 /D [1 0]", []byte{0x01, 0xf0, 0x23, 0x40},
+			[]byte{0xfe, 0x00, 0xdc, 0xb0}},
+		{"rgb", "/W 1 /H 1 /CS /RGB /BPC 8", []byte{0x10, 0x20, 0x30}, []byte{0x10, 0x20, 0x30}},
+		{"rgb inverted", "/W 1 /H 1 /CS /RGB /BPC 8 /D [1 0 1 0 1 0]", []byte{0x10, 0x20, 0x30},
+			[]byte{0xef, 0xdf, 0xcf}},
+		{"rgb green inverted", "/W 1 /H 1 /CS /RGB /BPC 8 /D [0 1 1 0 0 1]", []byte{0x10, 0x20, 0x30},
+			[]byte{0x10, 0xdf, 0x30}},
+		{"mask", "/W 10 /H 1 /IM true", []byte{0xa5, 0x40}, []byte{0xa5, 0x40}},
+		{"mask inverted", "/W 10 /H 1 /IM true /D [1 0]", []byte{0xa5, 0x40}, []byte{0x5a, 0x80}},
+		{"indexed", "/W 2 /H 1 /CS [/I /RGB 3 <000000ff0000>] /BPC 2 /D [3 0]", []byte{0x10},
+			[]byte{0xe0}},
+		{"invalid decode ignored", "/W 3 /H 1 /CS /G /BPC 8 /D [1 0 1]", []byte{0x00, 0x40, 0xff},
+			[]byte{0x00, 0x40, 0xff}},
+	}
+
+	for _, tcase := range testcases {
+		content := "BI " + tcase.params + " /F /AHx ID " + hex.EncodeToString(tcase.data) + "> EI"
+		image, err := parseInlineImage(t, content).ToImage(nil)
+		require.NoError(t, err, tcase.name)
+		require.Equal(t, tcase.expected, image.Data, tcase.name)
+	}
+}
+
+// TestInlineImageRoundTrip tests that an inline image with only some of the entries set is
+// written back unchanged.
+func TestInlineImageRoundTrip(t *testing.T) {
+	content := "q BI /W 2 /H 1 /CS /G ID \x00\xff EI Q"
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+
+	written := ops.Bytes()
+	require.Equal(t, "q\nBI\n/CS /G\n/H 1\n/W 2\nID \x00\xff\nEI\nQ\n", string(written))
+
+	ops2, err := NewContentStreamParser(string(written)).Parse()
+	require.NoError(t, err)
+	require.Equal(t, written, ops2.Bytes())
+	image, err := parseInlineImage(t, string(written)).ToImage(nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0xff}, image.Data)
+}
+
+// TestInlineImageDataLength tests that inline image data containing "EI" is read up to the end
+// of the data, using the length of the data when it is known.
+func TestInlineImageDataLength(t *testing.T) {
+	data := []byte("a EI Q Q Q b")
+
+	// Uncompressed data with a stored block, so that the data appears verbatim.
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.NoCompression)
+	require.NoError(t, err)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	flate := buf.String()
+	require.Contains(t, flate, " EI Q Q Q ")
+
+	w := strconv.Itoa(len(data))
+	testcases := []struct {
+		name   string
+		params string
+		stream string
+	}{
+		{"raw", "/W " + w + " /H 1 /CS /G /BPC 8", string(data)},
+		{"raw rgb", "/W 2 /H 2 /CS /RGB /BPC 8", string(data)},
+		{"length", "/W " + w + " /H 1 /CS /G /BPC 8 /F /Fl /L " + strconv.Itoa(len(flate)), flate},
+		{"flate", "/W " + w + " /H 1 /CS /G /BPC 8 /F /Fl", flate},
+	}
+	for _, tcase := range testcases {
+		content := "q BI " + tcase.params + " ID " + tcase.stream + "\nEI Q"
+		ops, err := NewContentStreamParser(content).Parse()
+		require.NoError(t, err, tcase.name)
+		require.Len(t, *ops, 3, tcase.name)
+		require.Equal(t, "Q", (*ops)[2].Operand, tcase.name)
+
+		image, err := parseInlineImage(t, content).ToImage(nil)
+		require.NoError(t, err, tcase.name)
+		require.Equal(t, data, image.Data, tcase.name)
+	}
+
+	// Falls back to scanning for EI when the data is shorter than expected.
+	img := parseInlineImage(t, "BI /W 4 /H 1 /CS /G ID \x01\x02 EI Q")
+	require.Equal(t, []byte{0x01, 0x02}, img.stream)
+}
+
+// TestInlineImageDCT tests that DCT encoded inline images take their dimensions and color
+// components from the decoded JPEG.
+func TestInlineImageDCT(t *testing.T) {
+	rgb := goimage.NewRGBA(goimage.Rect(0, 0, 8, 4))
+	gray := goimage.NewGray(goimage.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			rgb.Set(x, y, gocolor.RGBA{R: 0xff, G: 0x80, B: 0x00, A: 0xff})
+			gray.Set(x, y, gocolor.Gray{Y: 0x40})
+		}
+	}
+
+	testcases := []struct {
+		name       string
+		img        goimage.Image
+		params     string
+		components int
+		pixel      []byte
+	}{
+		{"rgb", rgb, "/CS /RGB /F [/AHx /DCT]", 3, []byte{0xff, 0x80, 0x00}},
+		{"gray", gray, "/CS /G /F [/AHx /DCT]", 1, []byte{0x40}},
+		// The colorspace does not determine the number of components.
+		{"gray as rgb", gray, "/CS /RGB /F [/AHx /DCT]", 1, []byte{0x40}},
+		{"gray inverted", gray, "/CS /G /D [1 0] /F [/AHx /DCT]", 1, []byte{0xbf}},
+	}
+
+	for _, tcase := range testcases {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, tcase.img, &jpeg.Options{Quality: 100}), tcase.name)
+
+		// The dimensions of the inline image parameters are overridden by the JPEG.
+		content := "BI /W 1 /H 1 /BPC 8 " + tcase.params + " ID " + hex.EncodeToString(buf.Bytes()) + "> EI"
+		image, err := parseInlineImage(t, content).ToImage(nil)
+		require.NoError(t, err, tcase.name)
+		require.Equal(t, int64(8), image.Width, tcase.name)
+		require.Equal(t, int64(4), image.Height, tcase.name)
+		require.Equal(t, int64(8), image.BitsPerComponent, tcase.name)
+		require.Equal(t, tcase.components, image.ColorComponents, tcase.name)
+		require.Len(t, image.Data, 8*4*tcase.components, tcase.name)
+		for i, val := range tcase.pixel {
+			require.InDelta(t, val, image.Data[i], 2, tcase.name)
+		}
+	}
+}
+
+// TestInlineImageCCITTFax tests decoding bilevel CCITTFax encoded inline images.
+func TestInlineImageCCITTFax(t *testing.T) {
+	const width, height = 20, 6
+	// Pixels are 1 byte per pixel for the encoder, 255 is white.
+	pixels := make([]byte, width*height)
+	expected := make([]byte, 0, 3*height)
+	for y := 0; y < height; y++ {
+		row := make([]byte, 3)
+		for x := 0; x < width; x++ {
+			if (x/3+y)%2 == 0 {
+				pixels[y*width+x] = 255
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		expected = append(expected, row...)
+	}
+
+	testcases := []struct {
+		name   string
+		k      int
+		params string
+		image  string
+	}{
+		{"group 4", -1, "/K -1 /Columns 20", "/BPC 1 /CS /G"},
+		{"group 3 1D", 0, "/Columns 20", "/BPC 1 /CS /G"},
+		{"group 3 2D", 4, "/K 4 /Columns 20 /EncodedByteAlign true", "/BPC 1 /CS /G"},
+		// Black pixels are 1 with BlackIs1, which is inverted back by the Decode array.
+		{"black is 1", -1, "/K -1 /Columns 20 /BlackIs1 true", "/BPC 1 /CS /G /D [1 0]"},
+		{"mask", -1, "/K -1 /Columns 20", "/IM true"},
+	}
+
+	// The padding bits at the end of the rows are not compared.
+	withoutPadding := func(data []byte) []byte {
+		data = append([]byte{}, data...)
+		for i := 2; i < len(data); i += 3 {
+			data[i] &= 0xf0
+		}
+		return data
+	}
+
+	for _, tcase := range testcases {
+		encoder := core.NewCCITTFaxEncoder()
+		encoder.K = tcase.k
+		encoder.Columns = width
+		encoder.EncodedByteAlign = strings.Contains(tcase.params, "EncodedByteAlign")
+		encoder.BlackIs1 = strings.Contains(tcase.params, "BlackIs1")
+		encoded, err := encoder.EncodeBytes(pixels)
+		require.NoError(t, err, tcase.name)
+
+		content := fmt.Sprintf("BI /W %d /H %d %s /F [/AHx /CCF] /DP [null << %s >>] ID %s> EI",
+			width, height, tcase.image, tcase.params, hex.EncodeToString(encoded))
+		image, err := parseInlineImage(t, content).ToImage(nil)
+		require.NoError(t, err, tcase.name)
+		require.Equal(t, int64(width), image.Width, tcase.name)
+		require.Equal(t, int64(height), image.Height, tcase.name)
+		require.Equal(t, int64(1), image.BitsPerComponent, tcase.name)
+		require.Equal(t, 1, image.ColorComponents, tcase.name)
+		require.Equal(t, expected, withoutPadding(image.Data), tcase.name)
+
+		// Single filter with the parameters directly in DecodeParms.
+		content = fmt.Sprintf("BI /W %d /H %d %s /F /CCF /DP << %s >> ID %s EI",
+			width, height, tcase.image, tcase.params, encoded)
+		image, err = parseInlineImage(t, content).ToImage(nil)
+		require.NoError(t, err, tcase.name)
+		require.Equal(t, expected, withoutPadding(image.Data), tcase.name)
+	}
+}
+
+// TestJPEGAdobeTransform tests finding the transform of the Adobe APP14 marker of JPEG data.
+func TestJPEGAdobeTransform(t *testing.T) {
+	adobe := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00,
+		0xff, 0xee, 0x00, 0x0e, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x02,
+		0xff, 0xda, 0x00, 0x02}
+	transform, ok := getJPEGAdobeTransform(adobe)
+	require.True(t, ok)
+	require.Equal(t, byte(2), transform)
+
+	_, ok = getJPEGAdobeTransform([]byte{0xff, 0xd8, 0xff, 0xda, 0x00, 0x02})
+	require.False(t, ok)
+	_, ok = getJPEGAdobeTransform([]byte("not a jpeg"))
+	require.False(t, ok)
+}
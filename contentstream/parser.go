@@ -21,13 +21,18 @@ import (
 // ContentStreamParser represents a content stream parser for parsing content streams in PDFs.
 type ContentStreamParser struct {
 	reader *bufio.Reader
+	depth  int // Current nesting depth of arrays and dictionaries.
+
+	// Minimum horizontal gap between glyphs, as a fraction of the font size, that is
+	// treated as a word break by ExtractText.
+	wordSpacingCutoff float64
 }
 
 // NewContentStreamParser creates a new instance of the content stream parser from an input content
 // stream string.
 func NewContentStreamParser(contentStr string) *ContentStreamParser {
 	// Each command has parameters and an operand (command).
-	parser := ContentStreamParser{}
+	parser := ContentStreamParser{wordSpacingCutoff: defaultWordSpacingCutoff}
 
 	buffer := bytes.NewBufferString(contentStr + "\n") // Add newline at end to get last operand without EOF error.
 	parser.reader = bufio.NewReader(buffer)
@@ -35,6 +40,30 @@ func NewContentStreamParser(contentStr string) *ContentStreamParser {
 	return &parser
 }
 
+// ParseConcatenated parses the content streams `streams`, e.g. those of a page, as one content
+// stream. The streams are joined with a newline, so that an operation may start in one stream
+// and end in the next without tokens at the boundary running together.
+func ParseConcatenated(streams [][]byte) (ContentStreamOperations, error) {
+	var buf bytes.Buffer
+	for i, stream := range streams {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.Write(stream)
+	}
+	ops, err := NewContentStreamParser(buf.String()).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return *ops, nil
+}
+
+// SetWordSpacingCutoff sets the minimum horizontal gap between glyphs, as a fraction of the
+// font size, that ExtractText treats as a space between words. The default is 0.1.
+func (csp *ContentStreamParser) SetWordSpacingCutoff(cutoff float64) {
+	csp.wordSpacingCutoff = cutoff
+}
+
 // Parse parses all commands in content stream, returning a list of operation data.
 func (csp *ContentStreamParser) Parse() (*ContentStreamOperations, error) {
 	operations := ContentStreamOperations{}
@@ -313,6 +342,11 @@ func (csp *ContentStreamParser) parseHexString() (*core.PdfObjectString, error)
 
 // Starts with '[' ends with ']'.  Can contain any kinds of direct objects.
 func (csp *ContentStreamParser) parseArray() (*core.PdfObjectArray, error) {
+	if err := csp.enterNested(); err != nil {
+		return nil, err
+	}
+	defer csp.exitNested()
+
 	arr := core.MakeArray()
 
 	csp.reader.ReadByte()
@@ -371,6 +405,10 @@ func (csp *ContentStreamParser) parseNull() (core.PdfObjectNull, error) {
 
 func (csp *ContentStreamParser) parseDict() (*core.PdfObjectDictionary, error) {
 	common.Log.Trace("Reading content stream dict!")
+	if err := csp.enterNested(); err != nil {
+		return nil, err
+	}
+	defer csp.exitNested()
 
 	dict := core.MakeDict()
 
@@ -436,6 +474,22 @@ func (csp *ContentStreamParser) parseDict() (*core.PdfObjectDictionary, error) {
 	return dict, nil
 }
 
+// enterNested increments the nesting depth when starting to parse an array or dictionary and
+// returns ErrNestingTooDeep if it exceeds maxNestingDepth.
+func (csp *ContentStreamParser) enterNested() error {
+	if csp.depth >= maxNestingDepth {
+		common.Log.Debug("ERROR: Nesting depth exceeds %d", maxNestingDepth)
+		return ErrNestingTooDeep
+	}
+	csp.depth++
+	return nil
+}
+
+// exitNested decrements the nesting depth when done parsing an array or dictionary.
+func (csp *ContentStreamParser) exitNested() {
+	csp.depth--
+}
+
 // An operand is a text command represented by a word.
 func (csp *ContentStreamParser) parseOperand() (*core.PdfObjectString, error) {
 	var bytes []byte
@@ -6,6 +6,7 @@
 package contentstream
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -248,3 +249,42 @@ EI (Very long string that is going to be only partially parser 12345678901234567
 		require.Equal(t, tcase.Expected, *ops)
 	}
 }
+
+func TestNestedOperands(t *testing.T) {
+	content := `/Span <</MCID 0 /Attrs <</O /Layout /BBox [0 0 [1 2] <</Nested [true null]>>]>>>> BDC
+[(a) [1 [2]] <</K (b)>>] TJ
+EMC`
+	ops, err := NewContentStreamParser(content).Parse()
+	require.NoError(t, err)
+	require.Len(t, *ops, 3)
+
+	props, ok := core.GetDict((*ops)[0].Params[1])
+	require.True(t, ok)
+	attrs, ok := core.GetDict(props.Get("Attrs"))
+	require.True(t, ok)
+	bbox, ok := core.GetArray(attrs.Get("BBox"))
+	require.True(t, ok)
+	require.Equal(t, 4, bbox.Len())
+	nested, ok := core.GetDict(bbox.Get(3))
+	require.True(t, ok)
+	require.Equal(t, "[true null]", nested.Get("Nested").WriteString())
+
+	arr, ok := core.GetArray((*ops)[1].Params[0])
+	require.True(t, ok)
+	require.Equal(t, "[(a) [1 [2]] <</K (b)>>]", arr.WriteString())
+
+	// Nesting up to the maximum depth is allowed.
+	deep := func(depth int) string {
+		return strings.Repeat("[", depth) + "1" + strings.Repeat("]", depth) + " TJ"
+	}
+	_, err = NewContentStreamParser(deep(maxNestingDepth)).Parse()
+	require.NoError(t, err)
+
+	_, err = NewContentStreamParser(deep(maxNestingDepth + 1)).Parse()
+	require.Equal(t, ErrNestingTooDeep, err)
+
+	// Dictionaries count towards the depth as well.
+	content = strings.Repeat("<</A ", 10000) + "1" + strings.Repeat(">>", 10000) + " gs"
+	_, err = NewContentStreamParser(content).Parse()
+	require.Equal(t, ErrNestingTooDeep, err)
+}
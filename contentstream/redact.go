@@ -0,0 +1,470 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"errors"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// Redact removes the content of `page` that intersects any of the rectangles `rects`, which are
+// specified in default user space, and covers the rectangles with filled black boxes.
+//
+// The redacted content is deleted from the content stream, not just covered, so that it cannot
+// be extracted or copied out of the document:
+//   - Glyphs whose bounding boxes intersect a rectangle are removed from the text showing
+//     operations. They are replaced by the equivalent spacing so that the remaining text stays in
+//     place.
+//   - Image XObjects and inline images intersecting a rectangle are removed.
+//   - Form XObjects intersecting a rectangle are removed as a whole, as their content may be
+//     shared with other pages.
+//
+// The content streams of the page are replaced by a single content stream.
+func Redact(page *model.PdfPage, rects []model.PdfRectangle) error {
+	if len(rects) == 0 {
+		return nil
+	}
+
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return err
+	}
+	ops, err := NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return err
+	}
+
+	r := newRedactor(rects)
+	redacted, err := r.redact(*ops, page.Resources)
+	if err != nil {
+		return err
+	}
+
+	// Draw the black boxes with the initial graphics state.
+	cc := NewContentCreator()
+	cc.Add_q()
+	for _, op := range redacted {
+		cc.AddOperand(*op)
+	}
+	cc.Add_Q()
+	cc.Add_q().Add_g(0)
+	for _, rect := range rects {
+		cc.Add_re(rect.Llx, rect.Lly, rect.Urx-rect.Llx, rect.Ury-rect.Lly)
+	}
+	cc.Add_f().Add_Q()
+
+	return page.SetContentStreams([]string{cc.String()}, core.NewFlateEncoder())
+}
+
+// redactTextState represents the text state parameters that are needed to position glyphs.
+type redactTextState struct {
+	tc    float64 // Character spacing.
+	tw    float64 // Word spacing.
+	th    float64 // Horizontal scaling (percent).
+	tl    float64 // Leading.
+	tfs   float64 // Font size.
+	trise float64 // Text rise.
+	font  *model.PdfFont
+}
+
+// redactor removes the content intersecting a set of rectangles from content stream operations.
+type redactor struct {
+	rects []model.PdfRectangle
+
+	state      redactTextState
+	stateStack []redactTextState
+	tm         transform.Matrix // Text matrix.
+	tlm        transform.Matrix // Text line matrix.
+	fonts      map[string]*model.PdfFont
+
+	// replaced maps the operations that are modified or removed (nil) to their replacement.
+	replaced map[*ContentStreamOperation][]*ContentStreamOperation
+}
+
+func newRedactor(rects []model.PdfRectangle) *redactor {
+	return &redactor{
+		rects:    rects,
+		state:    redactTextState{th: 100},
+		fonts:    map[string]*model.PdfFont{},
+		replaced: map[*ContentStreamOperation][]*ContentStreamOperation{},
+	}
+}
+
+// redact processes `ops` and returns the operations with the intersecting content removed.
+func (r *redactor) redact(ops []*ContentStreamOperation, resources *model.PdfPageResources) ([]*ContentStreamOperation, error) {
+	proc := NewContentStreamProcessor(ops)
+	proc.AddHandler(HandlerConditionEnumAllOperands, "",
+		func(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+			return r.handleOp(op, gs, resources)
+		})
+	if err := proc.Process(resources); err != nil {
+		return nil, err
+	}
+
+	var redacted []*ContentStreamOperation
+	for _, op := range ops {
+		if repl, ok := r.replaced[op]; ok {
+			redacted = append(redacted, repl...)
+			continue
+		}
+		redacted = append(redacted, op)
+	}
+	return redacted, nil
+}
+
+// handleOp updates the text state for `op` and records the replacement of `op` if it paints
+// content within the redacted rectangles.
+func (r *redactor) handleOp(op *ContentStreamOperation, gs GraphicsState, resources *model.PdfPageResources) error {
+	switch op.Operand {
+	case "q":
+		r.stateStack = append(r.stateStack, r.state)
+	case "Q":
+		if len(r.stateStack) > 0 {
+			r.state = r.stateStack[len(r.stateStack)-1]
+			r.stateStack = r.stateStack[:len(r.stateStack)-1]
+		}
+	case "BT":
+		r.tm = transform.IdentityMatrix()
+		r.tlm = r.tm
+	case "Tf":
+		if len(op.Params) != 2 {
+			return errors.New("invalid number of parameters for Tf")
+		}
+		name, ok := core.GetNameVal(op.Params[0])
+		if !ok {
+			return core.ErrTypeError
+		}
+		size, err := core.GetNumberAsFloat(op.Params[1])
+		if err != nil {
+			return err
+		}
+		font, err := r.getFont(name, resources)
+		if err != nil {
+			return err
+		}
+		r.state.font = font
+		r.state.tfs = size
+	case "Tc", "Tw", "Tz", "TL", "Ts":
+		if len(op.Params) != 1 {
+			return errors.New("invalid number of parameters")
+		}
+		val, err := core.GetNumberAsFloat(op.Params[0])
+		if err != nil {
+			return err
+		}
+		switch op.Operand {
+		case "Tc":
+			r.state.tc = val
+		case "Tw":
+			r.state.tw = val
+		case "Tz":
+			r.state.th = val
+		case "TL":
+			r.state.tl = val
+		case "Ts":
+			r.state.trise = val
+		}
+	case "Td", "TD":
+		f, err := core.GetNumbersAsFloat(op.Params)
+		if err != nil || len(f) != 2 {
+			return errors.New("invalid parameters for Td")
+		}
+		if op.Operand == "TD" {
+			r.state.tl = -f[1]
+		}
+		r.moveTo(f[0], f[1])
+	case "Tm":
+		f, err := core.GetNumbersAsFloat(op.Params)
+		if err != nil || len(f) != 6 {
+			return errors.New("invalid parameters for Tm")
+		}
+		r.tm = transform.NewMatrix(f[0], f[1], f[2], f[3], f[4], f[5])
+		r.tlm = r.tm
+	case "T*":
+		r.moveTo(0, -r.state.tl)
+	case "Tj":
+		if len(op.Params) != 1 {
+			return errors.New("invalid number of parameters for Tj")
+		}
+		elems, changed, err := r.showText(op.Params, gs)
+		if err != nil || !changed {
+			return err
+		}
+		r.replaced[op] = []*ContentStreamOperation{makeTJ(elems)}
+	case "TJ":
+		arr, ok := core.GetArray(firstParam(op))
+		if !ok {
+			return errors.New("invalid parameters for TJ")
+		}
+		elems, changed, err := r.showText(arr.Elements(), gs)
+		if err != nil || !changed {
+			return err
+		}
+		r.replaced[op] = []*ContentStreamOperation{makeTJ(elems)}
+	case "'", "\"":
+		// ' is T* followed by Tj and " additionally sets the word and character spacing first.
+		params := op.Params
+		var repl []*ContentStreamOperation
+		if op.Operand == "\"" {
+			if len(params) != 3 {
+				return errors.New("invalid number of parameters for \"")
+			}
+			f, err := core.GetNumbersAsFloat(params[:2])
+			if err != nil {
+				return err
+			}
+			r.state.tw, r.state.tc = f[0], f[1]
+			repl = append(repl,
+				&ContentStreamOperation{Operand: "Tw", Params: params[0:1]},
+				&ContentStreamOperation{Operand: "Tc", Params: params[1:2]})
+			params = params[2:]
+		}
+		if len(params) != 1 {
+			return errors.New("invalid number of parameters for '")
+		}
+		r.moveTo(0, -r.state.tl)
+		elems, changed, err := r.showText(params, gs)
+		if err != nil || !changed {
+			return err
+		}
+		repl = append(repl, &ContentStreamOperation{Operand: "T*"}, makeTJ(elems))
+		r.replaced[op] = repl
+	case "Do":
+		name, ok := core.GetNameVal(firstParam(op))
+		if !ok || resources == nil {
+			return nil
+		}
+		stream, xtype := resources.GetXObjectByName(core.PdfObjectName(name))
+		switch xtype {
+		case model.XObjectTypeImage:
+			if r.intersects(unitSquareBBox(gs.CTM)) {
+				r.replaced[op] = nil
+			}
+		case model.XObjectTypeForm:
+			if r.formIntersects(stream, gs.CTM) {
+				r.replaced[op] = nil
+			}
+		}
+	case "BI":
+		if r.intersects(unitSquareBBox(gs.CTM)) {
+			r.replaced[op] = nil
+		}
+	}
+	return nil
+}
+
+// showText positions the glyphs of the text showing operands `elems` (strings and TJ spacing
+// numbers) and returns the operands with the glyphs intersecting the redacted rectangles replaced
+// by spacing. The bool return value is true if any glyph was removed.
+func (r *redactor) showText(elems []core.PdfObject, gs GraphicsState) ([]core.PdfObject, bool, error) {
+	font := r.state.font
+	if font == nil {
+		common.Log.Debug("ERROR: Text shown without font")
+		return nil, false, errors.New("no font set")
+	}
+	tfs, th := r.state.tfs, r.state.th/100
+
+	var out []core.PdfObject
+	changed := false
+	for _, elem := range elems {
+		switch t := elem.(type) {
+		case *core.PdfObjectFloat, *core.PdfObjectInteger:
+			val, _ := core.GetNumberAsFloat(t)
+			r.tm.Concat(transform.TranslationMatrix(-val/1000*tfs*th, 0))
+			out = append(out, t)
+		case *core.PdfObjectString:
+			// Consecutive kept glyphs are shown as one string and consecutive removed glyphs are
+			// replaced by one spacing number.
+			var kept []byte
+			removed := 0.0
+			flush := func() {
+				if len(kept) > 0 {
+					out = append(out, core.MakeStringFromBytes(kept))
+					kept = nil
+				}
+				if removed != 0 && tfs != 0 && th != 0 {
+					out = append(out, core.MakeFloat(-removed/(tfs*th)*1000))
+				}
+				removed = 0
+			}
+			for _, code := range splitCharcodes(font, t.Bytes()) {
+				advance, bbox := r.glyph(code, gs)
+				if r.intersects(bbox) {
+					changed = true
+					if len(kept) > 0 {
+						flush()
+					}
+					removed += advance
+				} else {
+					if removed != 0 {
+						flush()
+					}
+					kept = append(kept, code...)
+				}
+				r.tm.Concat(transform.TranslationMatrix(advance, 0))
+			}
+			flush()
+		default:
+			return nil, false, core.ErrTypeError
+		}
+	}
+	return out, changed, nil
+}
+
+// glyph returns the horizontal displacement in text space and the bounding box in device space
+// of the glyph with the character code bytes `code`.
+func (r *redactor) glyph(code []byte, gs GraphicsState) (float64, model.PdfRectangle) {
+	state := r.state
+	font := state.font
+
+	width := 0.0
+	for _, charcode := range font.BytesToCharcodes(code) {
+		if m, ok := font.GetCharMetrics(charcode); ok {
+			width += m.Wx
+		}
+	}
+	// Word spacing applies to the single byte character code 32.
+	tw := 0.0
+	if len(code) == 1 && code[0] == ' ' {
+		tw = state.tw
+	}
+	advance := (width/1000*state.tfs + state.tc + tw) * state.th / 100
+
+	ascent, descent := 1000.0, -200.0
+	if desc := font.FontDescriptor(); desc != nil {
+		if a, err := desc.GetAscent(); err == nil && a > 0 {
+			ascent = a
+		}
+		if d, err := desc.GetDescent(); err == nil && d < 0 {
+			descent = d
+		}
+	}
+
+	stateMatrix := transform.NewMatrix(state.tfs*state.th/100, 0, 0, state.tfs, 0, state.trise)
+	trm := gs.CTM.Mult(r.tm).Mult(stateMatrix)
+	return advance, transformedBBox(trm, 0, descent/1000, width/1000, ascent/1000)
+}
+
+// moveTo moves to the start of the next line, offset by `tx`,`ty` from the start of the current line.
+func (r *redactor) moveTo(tx, ty float64) {
+	r.tlm.Concat(transform.TranslationMatrix(tx, ty))
+	r.tm = r.tlm
+}
+
+// getFont returns the font named `name` in `resources`.
+func (r *redactor) getFont(name string, resources *model.PdfPageResources) (*model.PdfFont, error) {
+	if font, ok := r.fonts[name]; ok {
+		return font, nil
+	}
+	if resources == nil {
+		return nil, model.ErrNoFont
+	}
+	obj, ok := resources.GetFontByName(core.PdfObjectName(name))
+	if !ok {
+		common.Log.Debug("ERROR: Font %s not found in resources", name)
+		return nil, model.ErrNoFont
+	}
+	font, err := model.NewPdfFontFromPdfObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	r.fonts[name] = font
+	return font, nil
+}
+
+// formIntersects returns true if the bounding box of the form XObject `stream` painted with
+// `ctm` intersects the redacted rectangles. Forms without a valid BBox are assumed to intersect.
+func (r *redactor) formIntersects(stream *core.PdfObjectStream, ctm transform.Matrix) bool {
+	form, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return true
+	}
+	bbox, ok := core.GetArray(form.BBox)
+	if !ok {
+		return true
+	}
+	rect, err := model.NewPdfRectangle(*bbox)
+	if err != nil {
+		return true
+	}
+	m := ctm
+	if matrix, ok := core.GetArray(form.Matrix); ok {
+		if f, err := matrix.ToFloat64Array(); err == nil && len(f) == 6 {
+			m = ctm.Mult(transform.NewMatrix(f[0], f[1], f[2], f[3], f[4], f[5]))
+		}
+	}
+	return r.intersects(transformedBBox(m, rect.Llx, rect.Lly, rect.Urx, rect.Ury))
+}
+
+// intersects returns true if `bbox` intersects any of the redacted rectangles.
+func (r *redactor) intersects(bbox model.PdfRectangle) bool {
+	for _, rect := range r.rects {
+		llx, urx := math.Min(rect.Llx, rect.Urx), math.Max(rect.Llx, rect.Urx)
+		lly, ury := math.Min(rect.Lly, rect.Ury), math.Max(rect.Lly, rect.Ury)
+		if bbox.Llx < urx && llx < bbox.Urx && bbox.Lly < ury && lly < bbox.Ury {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCharcodes splits the bytes of a shown string `data` into the bytes of each character code.
+// If the character codes of `font` cannot be delimited, the whole string is returned as one code.
+func splitCharcodes(font *model.PdfFont, data []byte) [][]byte {
+	n := 1
+	if font.IsCID() {
+		n = 2
+		if len(font.BytesToCharcodes(data))*2 != len(data) {
+			return [][]byte{data}
+		}
+	}
+	var codes [][]byte
+	for i := 0; i+n <= len(data); i += n {
+		codes = append(codes, data[i:i+n])
+	}
+	return codes
+}
+
+// unitSquareBBox returns the bounding box of the unit square transformed by `m`, i.e. the area
+// painted by an image.
+func unitSquareBBox(m transform.Matrix) model.PdfRectangle {
+	return transformedBBox(m, 0, 0, 1, 1)
+}
+
+// transformedBBox returns the bounding box of the rectangle (llx, lly, urx, ury) transformed by `m`.
+func transformedBBox(m transform.Matrix, llx, lly, urx, ury float64) model.PdfRectangle {
+	bbox := model.PdfRectangle{
+		Llx: math.Inf(1), Lly: math.Inf(1),
+		Urx: math.Inf(-1), Ury: math.Inf(-1),
+	}
+	for _, p := range [][2]float64{{llx, lly}, {urx, lly}, {llx, ury}, {urx, ury}} {
+		x, y := m.Transform(p[0], p[1])
+		bbox.Llx, bbox.Urx = math.Min(bbox.Llx, x), math.Max(bbox.Urx, x)
+		bbox.Lly, bbox.Ury = math.Min(bbox.Lly, y), math.Max(bbox.Ury, y)
+	}
+	return bbox
+}
+
+// makeTJ returns a TJ operation showing `elems`.
+func makeTJ(elems []core.PdfObject) *ContentStreamOperation {
+	return &ContentStreamOperation{
+		Operand: "TJ",
+		Params:  []core.PdfObject{core.MakeArray(elems...)},
+	}
+}
+
+// firstParam returns the first parameter of `op` or nil if it has none.
+func firstParam(op *ContentStreamOperation) core.PdfObject {
+	if len(op.Params) == 0 {
+		return nil
+	}
+	return op.Params[0]
+}
@@ -0,0 +1,84 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package contentstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+func TestRedact(t *testing.T) {
+	img := &model.Image{Width: 1, Height: 1, BitsPerComponent: 8, ColorComponents: 1, Data: []byte{0}}
+	ximg, err := model.NewXObjectImageFromImage(img, nil, nil)
+	require.NoError(t, err)
+
+	page := model.NewPdfPage()
+	page.Resources = model.NewPdfPageResources()
+	courier := model.NewStandard14FontMustCompile(model.CourierName)
+	require.NoError(t, page.Resources.SetFontByName("F1", courier.ToPdfObject()))
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im1", ximg))
+
+	// Courier glyphs are 600 units wide: 7.2 points at 12 points font size, so "Secret" spans
+	// from x=143.2 to x=186.4.
+	err = page.SetContentStreams([]string{`
+BT
+/F1 12 Tf
+100 700 Td
+(Hello Secret World) Tj
+0 -20 Td
+[(Public) -100 (Text)] TJ
+ET
+q 50 0 0 50 100 500 cm /Im1 Do Q
+q 50 0 0 50 300 500 cm /Im1 Do Q
+`}, nil)
+	require.NoError(t, err)
+
+	rects := []model.PdfRectangle{
+		{Llx: 145, Lly: 698, Urx: 185, Ury: 710},
+		{Llx: 120, Lly: 520, Urx: 130, Ury: 530},
+	}
+	require.NoError(t, Redact(page, rects))
+
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	ops, err := NewContentStreamParser(contents).Parse()
+	require.NoError(t, err)
+
+	var shown []core.PdfObject
+	var numImages, numRects int
+	for _, op := range *ops {
+		switch op.Operand {
+		case "Tj":
+			shown = append(shown, op.Params...)
+		case "TJ":
+			arr, ok := core.GetArray(op.Params[0])
+			require.True(t, ok)
+			shown = append(shown, arr.Elements()...)
+		case "Do":
+			numImages++
+		case "re":
+			numRects++
+		}
+	}
+
+	// The redacted glyphs are replaced by their advance so that " World" is not moved.
+	require.Len(t, shown, 6)
+	require.Equal(t, "Hello ", shown[0].(*core.PdfObjectString).Str())
+	spacing, err := core.GetNumberAsFloat(shown[1])
+	require.NoError(t, err)
+	require.InDelta(t, -3600, spacing, 1e-6)
+	require.Equal(t, " World", shown[2].(*core.PdfObjectString).Str())
+	require.Equal(t, "Public", shown[3].(*core.PdfObjectString).Str())
+	require.Equal(t, "Text", shown[5].(*core.PdfObjectString).Str())
+
+	require.Equal(t, 1, numImages)
+	require.Equal(t, 2, numRects)
+	require.NotContains(t, contents, "Secret")
+}
@@ -13,6 +13,45 @@ import (
 	"github.com/unidoc/unipdf/v3/model"
 )
 
+// inlineFilterNames maps the abbreviated filter names that may be used in inline images to
+// the full names (Table 94 p. 224 PDF32000_2008).
+var inlineFilterNames = map[core.PdfObjectName]core.PdfObjectName{
+	"AHx": core.StreamEncodingFilterNameASCIIHex,
+	"A85": core.StreamEncodingFilterNameASCII85,
+	"LZW": core.StreamEncodingFilterNameLZW,
+	"Fl":  core.StreamEncodingFilterNameFlate,
+	"RL":  core.StreamEncodingFilterNameRunLength,
+	"CCF": core.StreamEncodingFilterNameCCITTFax,
+	"DCT": core.StreamEncodingFilterNameDCT,
+}
+
+// inlineColorspaceNames maps the abbreviated colorspace names that may be used in inline images
+// to the full names (Table 94 p. 224 PDF32000_2008).
+var inlineColorspaceNames = map[core.PdfObjectName]core.PdfObjectName{
+	"G":    "DeviceGray",
+	"RGB":  "DeviceRGB",
+	"CMYK": "DeviceCMYK",
+	"I":    "Indexed",
+}
+
+// expandInlineFilterName returns the full filter name for the inline image filter `name`,
+// which may be abbreviated.
+func expandInlineFilterName(name core.PdfObjectName) core.PdfObjectName {
+	if full, ok := inlineFilterNames[name]; ok {
+		return full
+	}
+	return name
+}
+
+// expandInlineColorspaceName returns the full colorspace name for the inline image colorspace
+// `name`, which may be abbreviated.
+func expandInlineColorspaceName(name core.PdfObjectName) core.PdfObjectName {
+	if full, ok := inlineColorspaceNames[name]; ok {
+		return full
+	}
+	return name
+}
+
 func makeParamsFromFloats(vals []float64) []core.PdfObject {
 	var params []core.PdfObject
 	for _, val := range vals {
@@ -63,7 +102,7 @@ func newIndexedColorspaceFromPdfObject(obj core.PdfObject) (model.PdfColorspace,
 		common.Log.Debug("Error: Invalid cs array first element not a name (array: %#v)", *arr)
 		return nil, errors.New("type check error")
 	}
-	if *name != "I" && *name != "Indexed" {
+	if expandInlineColorspaceName(*name) != "Indexed" {
 		common.Log.Debug("Error: Invalid cs array first element != I (got: %v)", *name)
 		return nil, errors.New("range check error")
 	}
@@ -74,22 +113,14 @@ func newIndexedColorspaceFromPdfObject(obj core.PdfObject) (model.PdfColorspace,
 		common.Log.Debug("Error: Invalid cs array 2nd element not a name (array: %#v)", *arr)
 		return nil, errors.New("type check error")
 	}
-	if *name != "G" && *name != "RGB" && *name != "CMYK" && *name != "DeviceGray" && *name != "DeviceRGB" && *name != "DeviceCMYK" {
+	basename := expandInlineColorspaceName(*name)
+	if basename != "DeviceGray" && basename != "DeviceRGB" && basename != "DeviceCMYK" {
 		common.Log.Debug("Error: Invalid cs array 2nd element != G/RGB/CMYK (got: %v)", *name)
 		return nil, errors.New("range check error")
 	}
-	basename := ""
-	switch *name {
-	case "G", "DeviceGray":
-		basename = "DeviceGray"
-	case "RGB", "DeviceRGB":
-		basename = "DeviceRGB"
-	case "CMYK", "DeviceCMYK":
-		basename = "DeviceCMYK"
-	}
 
 	// Prepare to a format that can be loaded by model's newPdfColorspaceFromPdfObject.
-	csArr := core.MakeArray(core.MakeName("Indexed"), core.MakeName(basename), arr.Get(2), arr.Get(3))
+	csArr := core.MakeArray(core.MakeName("Indexed"), core.MakeName(string(basename)), arr.Get(2), arr.Get(3))
 
 	return model.NewPdfColorspaceFromPdfObject(csArr)
 }
@@ -28,14 +28,47 @@ type EncryptInfo struct {
 	ID0, ID1 string
 }
 
+// CryptOptions are optional settings of the crypt handler made by PdfCryptNewEncryptWithOptions.
+type CryptOptions struct {
+	// UnencryptedMetadata leaves the document metadata stream unencrypted (EncryptMetadata
+	// false), which requires a crypt filter of security handler revision 4 or above.
+	UnencryptedMetadata bool
+
+	// ID0 and ID1 are the document identifiers to use instead of generated ones. If ID0 is set,
+	// the arbitrary padding of the U entry (R<=4) is fixed instead of random, so that the
+	// encryption dictionary is reproducible.
+	ID0, ID1 string
+}
+
 // PdfCryptNewEncrypt makes the document crypt handler based on a specified crypt filter.
 func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions) (*PdfCrypt, *EncryptInfo, error) {
+	return PdfCryptNewEncryptWithOptions(cf, userPass, ownerPass, perm, nil)
+}
+
+// PdfCryptNewEncryptMetadata makes the document crypt handler based on a specified crypt filter
+// like PdfCryptNewEncrypt. If `encryptMetadata` is false, the document metadata stream is left
+// unencrypted, which requires a crypt filter of security handler revision 4 or above.
+func PdfCryptNewEncryptMetadata(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions,
+	encryptMetadata bool) (*PdfCrypt, *EncryptInfo, error) {
+	return PdfCryptNewEncryptWithOptions(cf, userPass, ownerPass, perm, &CryptOptions{
+		UnencryptedMetadata: !encryptMetadata,
+	})
+}
+
+// PdfCryptNewEncryptWithOptions makes the document crypt handler based on a specified crypt
+// filter like PdfCryptNewEncrypt, with the optional settings `opts`.
+func PdfCryptNewEncryptWithOptions(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions,
+	opts *CryptOptions) (*PdfCrypt, *EncryptInfo, error) {
+	if opts == nil {
+		opts = &CryptOptions{}
+	}
+	encryptMetadata := !opts.UnencryptedMetadata
 	crypter := &PdfCrypt{
 		encryptedObjects: make(map[PdfObject]bool),
 		cryptFilters:     make(cryptFilters),
 		encryptStd: security.StdEncryptDict{
 			P:               perm,
-			EncryptMetadata: true,
+			EncryptMetadata: encryptMetadata,
 		},
 	}
 	var vers Version
@@ -49,6 +82,9 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 
 		crypter.encrypt.Length = cf.KeyLength() * 8
 	}
+	if !encryptMetadata && crypter.encryptStd.R < 4 {
+		return nil, nil, errors.New("unencrypted metadata requires security handler revision 4 or above")
+	}
 	const (
 		defaultFilter = stdCryptFilter
 	)
@@ -60,17 +96,23 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 	ed := crypter.newEncryptDict()
 
 	// Prepare the ID object for the trailer.
-	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
-	id0 := string(hashcode[:])
-	b := make([]byte, 100)
-	rand.Read(b)
-	hashcode = md5.Sum(b)
-	id1 := string(hashcode[:])
-	common.Log.Trace("Random b: % x", b)
+	id0, id1 := opts.ID0, opts.ID1
+	if id0 == "" {
+		hashcode := md5.Sum([]byte(common.Now().Format(time.RFC850)))
+		id0 = string(hashcode[:])
+	}
+	if id1 == "" {
+		b := make([]byte, 100)
+		rand.Read(b)
+		hashcode := md5.Sum(b)
+		id1 = string(hashcode[:])
+		common.Log.Trace("Random b: % x", b)
+	}
 
 	common.Log.Trace("Gen Id 0: % x", id0)
 
 	crypter.id0 = string(id0)
+	crypter.fixedPadding = opts.ID0 != ""
 
 	err := crypter.generateParams(userPass, ownerPass)
 	if err != nil {
@@ -91,6 +133,53 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 	}, nil
 }
 
+// isUnencryptedMetadata returns true if the stream with dictionary `dict` is a metadata stream
+// and the metadata is not encrypted (EncryptMetadata false).
+func (crypt *PdfCrypt) isUnencryptedMetadata(dict *PdfObjectDictionary) bool {
+	if crypt.encryptStd.EncryptMetadata || crypt.encryptStd.R < 4 {
+		return false
+	}
+	s, ok := GetName(dict.Get("Type"))
+	return ok && *s == "Metadata"
+}
+
+// addIdentityCryptFilter prepends the Identity crypt filter to the filters of the stream with
+// dictionary `dict`, so that readers ignoring EncryptMetadata do not decrypt it either.
+func addIdentityCryptFilter(dict *PdfObjectDictionary) {
+	params := MakeDict()
+	params.Set("Type", MakeName("CryptFilterDecodeParms"))
+	params.Set("Name", MakeName("Identity"))
+
+	filters := MakeArray(MakeName(StreamEncodingFilterNameCrypt))
+	decodeParams := MakeArray(params)
+	switch t := TraceToDirectObject(dict.Get("Filter")).(type) {
+	case *PdfObjectName:
+		if *t == StreamEncodingFilterNameCrypt {
+			return
+		}
+		filters.Append(t)
+		decodeParams.Append(MakeNull())
+	case *PdfObjectArray:
+		if name, ok := GetName(t.Get(0)); ok && *name == StreamEncodingFilterNameCrypt {
+			return
+		}
+		filters.Append(t.Elements()...)
+		for range t.Elements() {
+			decodeParams.Append(MakeNull())
+		}
+	}
+	switch t := TraceToDirectObject(dict.Get("DecodeParms")).(type) {
+	case *PdfObjectDictionary:
+		decodeParams.Set(1, t)
+	case *PdfObjectArray:
+		for i, dp := range t.Elements() {
+			decodeParams.Set(i+1, dp)
+		}
+	}
+	dict.Set("Filter", filters)
+	dict.Set("DecodeParms", decodeParams)
+}
+
 // PdfCrypt provides PDF encryption/decryption support.
 // The PDF standard supports encryption of strings and streams (Section 7.6).
 type PdfCrypt struct {
@@ -98,6 +187,7 @@ type PdfCrypt struct {
 	encryptStd security.StdEncryptDict
 
 	id0              string
+	fixedPadding     bool
 	encryptionKey    []byte
 	decryptedObjects map[PdfObject]bool
 	encryptedObjects map[PdfObject]bool
@@ -115,7 +205,7 @@ type PdfCrypt struct {
 // encodeEncryptStd encodes fields of standard security handler to an Encrypt dictionary.
 func encodeEncryptStd(d *security.StdEncryptDict, ed *PdfObjectDictionary) {
 	ed.Set("R", MakeInteger(int64(d.R)))
-	ed.Set("P", MakeInteger(int64(d.P)))
+	ed.Set("P", MakeInteger(int64(int32(d.P))))
 
 	ed.Set("O", MakeStringFromBytes(d.O))
 	ed.Set("U", MakeStringFromBytes(d.U))
@@ -126,6 +216,8 @@ func encodeEncryptStd(d *security.StdEncryptDict, ed *PdfObjectDictionary) {
 		if d.R > 5 {
 			ed.Set("Perms", MakeStringFromBytes(d.Perms))
 		}
+	} else if d.R == 4 && !d.EncryptMetadata {
+		ed.Set("EncryptMetadata", MakeBool(false))
 	}
 }
 
@@ -504,6 +596,9 @@ func (crypt *PdfCrypt) securityHandler() security.StdHandler {
 	if crypt.encryptStd.R >= 5 {
 		return security.NewHandlerR6()
 	}
+	if crypt.fixedPadding {
+		return security.NewHandlerR4FixedPadding(crypt.id0, crypt.encrypt.Length)
+	}
 	return security.NewHandlerR4(crypt.id0, crypt.encrypt.Length)
 }
 
@@ -641,6 +736,9 @@ func (crypt *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 				return nil // Cross-reference streams should not be encrypted
 			}
 		}
+		if crypt.isUnencryptedMetadata(dict) {
+			return nil
+		}
 
 		objNum := obj.ObjectNumber
 		genNum := obj.GenerationNumber
@@ -824,6 +922,10 @@ func (crypt *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 		if s, ok := dict.Get("Type").(*PdfObjectName); ok && *s == "XRef" {
 			return nil // Cross-reference streams should not be encrypted
 		}
+		if crypt.isUnencryptedMetadata(dict) {
+			addIdentityCryptFilter(dict)
+			return nil
+		}
 
 		objNum := obj.ObjectNumber
 		genNum := obj.GenerationNumber
@@ -95,3 +95,29 @@ func TestDecryption1(t *testing.T) {
 		return
 	}
 }
+
+// Test that the Identity crypt filter is prepended to the filters of a stream and that the
+// stream can still be decoded.
+func TestAddIdentityCryptFilter(t *testing.T) {
+	encoder := NewFlateEncoder()
+	stream, err := MakeStream([]byte("<x:xmpmeta/>"), encoder)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	addIdentityCryptFilter(stream.PdfObjectDictionary)
+	addIdentityCryptFilter(stream.PdfObjectDictionary)
+
+	if s := stream.Get("Filter").WriteString(); s != "[/Crypt /FlateDecode]" {
+		t.Fatalf("Unexpected filters: %s", s)
+	}
+	if s := stream.Get("DecodeParms").WriteString(); s != "[<</Type /CryptFilterDecodeParms/Name /Identity>> null]" {
+		t.Fatalf("Unexpected decode params: %s", s)
+	}
+	decoded, err := DecodeStream(stream)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if string(decoded) != "<x:xmpmeta/>" {
+		t.Fatalf("Unexpected decoded data: %q", decoded)
+	}
+}
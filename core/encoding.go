@@ -51,6 +51,11 @@ const (
 	StreamEncodingFilterNameJBIG2     = "JBIG2Decode"
 	StreamEncodingFilterNameJPX       = "JPXDecode"
 	StreamEncodingFilterNameRaw       = "Raw"
+
+	// StreamEncodingFilterNameCrypt is the filter used to select the crypt filter of a stream.
+	// Decryption is performed by the security handler, so it does not alter the stream data
+	// when decoding.
+	StreamEncodingFilterNameCrypt = "Crypt"
 )
 
 const (
@@ -512,8 +517,12 @@ func (enc *LZWEncoder) GetFilterName() string {
 // MakeDecodeParams makes a new instance of an encoding dictionary based on
 // the current encoder settings.
 func (enc *LZWEncoder) MakeDecodeParams() PdfObject {
+	if enc.Predictor <= 1 && enc.EarlyChange == 1 {
+		return nil
+	}
+
+	decodeParams := MakeDict()
 	if enc.Predictor > 1 {
-		decodeParams := MakeDict()
 		decodeParams.Set("Predictor", MakeInteger(int64(enc.Predictor)))
 
 		// Only add if not default option.
@@ -526,9 +535,11 @@ func (enc *LZWEncoder) MakeDecodeParams() PdfObject {
 		if enc.Colors != 1 {
 			decodeParams.Set("Colors", MakeInteger(int64(enc.Colors)))
 		}
-		return decodeParams
 	}
-	return nil
+	if enc.EarlyChange != 1 {
+		decodeParams.Set("EarlyChange", MakeInteger(int64(enc.EarlyChange)))
+	}
+	return decodeParams
 }
 
 // MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
@@ -634,6 +645,15 @@ func newLZWEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfObject
 		return encoder, nil
 	}
 
+	// EarlyChange belongs in DecodeParms, which takes precedence over the stream dictionary.
+	if obj := decodeParams.Get("EarlyChange"); obj != nil {
+		earlyChange, ok := obj.(*PdfObjectInteger)
+		if !ok || (*earlyChange != 0 && *earlyChange != 1) {
+			return nil, fmt.Errorf("invalid EarlyChange")
+		}
+		encoder.EarlyChange = int(*earlyChange)
+	}
+
 	obj = decodeParams.Get("Predictor")
 	if obj != nil {
 		predictor, ok := obj.(*PdfObjectInteger)
@@ -1828,7 +1848,7 @@ func newCCITTFaxEncoderFromStream(streamObj *PdfObjectStream, decodeParams *PdfO
 		}
 	}
 
-	if rows, err := GetNumberAsInt64(decodeParams.Get("DamagedRowsBeforeError")); err != nil {
+	if rows, err := GetNumberAsInt64(decodeParams.Get("DamagedRowsBeforeError")); err == nil {
 		encoder.DamagedRowsBeforeError = int(rows)
 	}
 
@@ -1889,7 +1909,7 @@ func (enc *CCITTFaxEncoder) UpdateParams(params *PdfObjectDictionary) {
 		}
 	}
 
-	if rows, err := GetNumberAsInt64(params.Get("DamagedRowsBeforeError")); err != nil {
+	if rows, err := GetNumberAsInt64(params.Get("DamagedRowsBeforeError")); err == nil {
 		enc.DamagedRowsBeforeError = int(rows)
 	}
 }
@@ -1912,32 +1932,34 @@ func (enc *CCITTFaxEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// reassemble image
-	var decoded []byte
-	decodedIdx := 0
-	var bitPos byte
-	var currentByte byte
+	// Reassemble the image. The decoded pixels are 1 for white unless BlackIs1 is set, in
+	// which case 1 is black. Each row is Columns pixels wide and starts on a byte boundary as
+	// required for image data, the remaining bits of the last byte are padded with white.
+	var white byte = 1
+	if enc.BlackIs1 {
+		white = 0
+	}
+	rowBytes := (enc.Columns + 7) / 8
+	decoded := make([]byte, 0, rowBytes*len(pixels))
 	for i := range pixels {
-		for j := range pixels[i] {
-			currentByte |= pixels[i][j] << (7 - bitPos)
+		var bitPos byte
+		var currentByte byte
+		for j := 0; j < rowBytes*8; j++ {
+			pixel := white
+			if j < len(pixels[i]) && j < enc.Columns {
+				pixel = pixels[i][j]
+			}
+			currentByte |= pixel << (7 - bitPos)
 
 			bitPos++
-
 			if bitPos == 8 {
 				decoded = append(decoded, currentByte)
 				currentByte = 0
-
-				decodedIdx++
-
 				bitPos = 0
 			}
 		}
 	}
 
-	if bitPos > 0 {
-		decoded = append(decoded, currentByte)
-	}
-
 	return decoded, nil
 }
 
@@ -1947,22 +1969,28 @@ func (enc *CCITTFaxEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, er
 }
 
 // EncodeBytes encodes the image data using either Group3 or Group4 CCITT facsimile (fax) encoding.
-// `data` is expected to be 1 color component, 1 byte per component.
+// `data` is expected to be 1 color component, 1 byte per component, where 255 is white.
+// A trailing incomplete row is padded with white.
 func (enc *CCITTFaxEncoder) EncodeBytes(data []byte) ([]byte, error) {
-	var pixels [][]byte
+	if enc.Columns <= 0 {
+		return nil, errors.New("invalid Columns")
+	}
+
+	// The pixel values are the output values of the decoder: 1 for white unless BlackIs1 is set.
+	var white, black byte = 1, 0
+	if enc.BlackIs1 {
+		white, black = 0, 1
+	}
 
+	var pixels [][]byte
 	for i := 0; i < len(data); i += enc.Columns {
 		pixelsRow := make([]byte, enc.Columns)
-
-		pixel := 0
 		for j := 0; j < enc.Columns; j++ {
-			if data[i+j] == 255 {
-				pixelsRow[pixel] = 1
+			if i+j >= len(data) || data[i+j] == 255 {
+				pixelsRow[j] = white
 			} else {
-				pixelsRow[pixel] = 0
+				pixelsRow[j] = black
 			}
-
-			pixel++
 		}
 
 		pixels = append(pixels, pixelsRow)
@@ -2318,6 +2346,9 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 			mencoder.AddEncoder(encoder)
 			common.Log.Trace("Added DCT encoder...")
 			common.Log.Trace("Multi encoder: %#v", mencoder)
+		} else if *name == StreamEncodingFilterNameCrypt {
+			// Applied by the security handler.
+			continue
 		} else {
 			common.Log.Error("Unsupported filter %s", *name)
 			return nil, fmt.Errorf("invalid filter in multi filter array")
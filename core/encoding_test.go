@@ -6,6 +6,7 @@
 package core
 
 import (
+	"bytes"
 	"encoding/base64"
 	"testing"
 
@@ -193,6 +194,33 @@ func TestLZWEncoding(t *testing.T) {
 	}
 }
 
+// Test that the EarlyChange of LZW streams is written to and read from DecodeParms.
+func TestLZWEarlyChangeDecodeParms(t *testing.T) {
+	encoder := NewLZWEncoder()
+	if params := encoder.MakeDecodeParams(); params != nil {
+		t.Fatalf("Unexpected decode params for default encoder: %v", params)
+	}
+
+	encoder.EarlyChange = 0
+	rawStream := bytes.Repeat([]byte("some text that is long enough to increase the code length "), 100)
+	encoded, err := encoder.EncodeBytes(rawStream)
+	if err != nil {
+		t.Fatalf("Failed to encode data: %v", err)
+	}
+
+	stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: encoded}
+	stream.Set("Filter", MakeName(encoder.GetFilterName()))
+	stream.Set("DecodeParms", encoder.MakeDecodeParams())
+
+	decoded, err := DecodeStream(stream)
+	if err != nil {
+		t.Fatalf("Failed to decode stream: %v", err)
+	}
+	if !bytes.Equal(decoded, rawStream) {
+		t.Fatalf("Slices not matching")
+	}
+}
+
 // Test run length encoding.
 func TestRunLengthEncoding(t *testing.T) {
 	rawStream := []byte("this is a dummy text with some \x01\x02\x03 binary data")
@@ -367,3 +395,66 @@ func TestMultiEncoder(t *testing.T) {
 		return
 	}
 }
+
+// Test CCITTFax encoding and decoding with the BlackIs1 and EncodedByteAlign parameters for
+// an image whose width is not a multiple of 8. The decoded rows must start on byte boundaries
+// and have the polarity defined by BlackIs1.
+func TestCCITTFaxEncodingParams(t *testing.T) {
+	const columns = 10
+	gray := []byte{
+		255, 255, 0, 0, 255, 255, 255, 255, 255, 0,
+		0, 0, 0, 0, 0, 255, 255, 255, 255, 255,
+		255, 255, 255, 255, 255, 255, 255, 255, 255, 255,
+		0, 255, 0, 255, 0, 255, 0, 255, 0, 255,
+	}
+	// Rows packed with 1 for white, padded to a byte boundary with white.
+	whiteIs1 := []byte{
+		0xcf, 0xbf,
+		0x07, 0xff,
+		0xff, 0xff,
+		0x55, 0x7f,
+	}
+
+	for _, k := range []int{-1, 0, 2} {
+		for _, blackIs1 := range []bool{false, true} {
+			for _, align := range []bool{false, true} {
+				encoder := NewCCITTFaxEncoder()
+				encoder.K = k
+				encoder.Columns = columns
+				encoder.BlackIs1 = blackIs1
+				encoder.EncodedByteAlign = align
+
+				encoded, err := encoder.EncodeBytes(gray)
+				if err != nil {
+					t.Fatalf("K=%d BlackIs1=%v align=%v: encode failed: %v", k, blackIs1, align, err)
+				}
+
+				// Decode with parameters loaded from a stream dictionary.
+				stream := &PdfObjectStream{PdfObjectDictionary: encoder.MakeStreamDict(), Stream: encoded}
+				decoder, err := newCCITTFaxEncoderFromStream(stream, nil)
+				if err != nil {
+					t.Fatalf("K=%d BlackIs1=%v align=%v: %v", k, blackIs1, align, err)
+				}
+				if decoder.BlackIs1 != blackIs1 || decoder.EncodedByteAlign != align {
+					t.Fatalf("K=%d BlackIs1=%v align=%v: parameters not loaded", k, blackIs1, align)
+				}
+
+				decoded, err := decoder.DecodeStream(stream)
+				if err != nil {
+					t.Fatalf("K=%d BlackIs1=%v align=%v: decode failed: %v", k, blackIs1, align, err)
+				}
+
+				expected := make([]byte, len(whiteIs1))
+				for i, b := range whiteIs1 {
+					if blackIs1 {
+						b = ^b
+					}
+					expected[i] = b
+				}
+				if !compareSlices(decoded, expected) {
+					t.Errorf("K=%d BlackIs1=%v align=%v: % x != % x", k, blackIs1, align, decoded, expected)
+				}
+			}
+		}
+	}
+}
@@ -497,7 +497,9 @@ func (parser *PdfParser) parseObject() (PdfObject, error) {
 			}
 		}
 
-		common.Log.Trace("Peek string: %s", string(bb))
+		if common.Log.IsLogLevel(common.LogLevelTrace) {
+			common.Log.Trace("Peek string: %s", string(bb))
+		}
 		// Determine type.
 		if bb[0] == '/' {
 			name, err := parser.parseName()
@@ -545,7 +547,9 @@ func (parser *PdfParser) parseObject() (PdfObject, error) {
 			result1 := reReference.FindStringSubmatch(string(peekStr))
 			if len(result1) > 1 {
 				bb, _ = parser.reader.ReadBytes('R')
-				common.Log.Trace("-> !Ref: '%s'", string(bb[:]))
+				if common.Log.IsLogLevel(common.LogLevelTrace) {
+					common.Log.Trace("-> !Ref: '%s'", string(bb[:]))
+				}
 				ref, err := parseReference(string(bb))
 				ref.parser = parser
 				return &ref, err
@@ -600,7 +604,9 @@ func (parser *PdfParser) ParseDict() (*PdfObjectDictionary, error) {
 			return nil, err
 		}
 
-		common.Log.Trace("Dict peek: %s (% x)!", string(bb), string(bb))
+		if common.Log.IsLogLevel(common.LogLevelTrace) {
+			common.Log.Trace("Dict peek: %s (% x)!", string(bb), string(bb))
+		}
 		if (bb[0] == '>') && (bb[1] == '>') {
 			common.Log.Trace("EOF dictionary")
 			parser.reader.ReadByte()
@@ -1151,7 +1157,9 @@ func (parser *PdfParser) seekToEOFMarker(fSize int64) error {
 		// Read the data.
 		b1 := make([]byte, buflen)
 		parser.rs.Read(b1)
-		common.Log.Trace("Looking for EOF marker: \"%s\"", string(b1))
+		if common.Log.IsLogLevel(common.LogLevelTrace) {
+			common.Log.Trace("Looking for EOF marker: \"%s\"", string(b1))
+		}
 		ind := reEOF.FindAllStringIndex(string(b1), -1)
 		if ind != nil {
 			// Found it.
@@ -1412,7 +1420,9 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 			return &indirect, err
 		}
 	}
-	common.Log.Trace("(indirect obj peek \"%s\"", string(bb))
+	if common.Log.IsLogLevel(common.LogLevelTrace) {
+		common.Log.Trace("(indirect obj peek \"%s\"", string(bb))
+	}
 
 	indices := reIndirectObject.FindStringSubmatchIndex(string(bb))
 	if len(indices) < 6 {
@@ -1453,7 +1463,9 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 		if err != nil {
 			return &indirect, err
 		}
-		common.Log.Trace("Ind. peek: %s (% x)!", string(bb), string(bb))
+		if common.Log.IsLogLevel(common.LogLevelTrace) {
+			common.Log.Trace("Ind. peek: %s (% x)!", string(bb), string(bb))
+		}
 
 		if IsWhiteSpace(bb[0]) {
 			parser.skipSpaces()
@@ -1713,7 +1725,7 @@ func (parser *PdfParser) IsEncrypted() (bool, error) {
 		return false, err
 	}
 	// list objects that should never be decrypted
-	for _, key := range []string{"Info", "Encrypt"} {
+	for _, key := range []string{"Encrypt"} {
 		f := parser.trailer.Get(PdfObjectName(key))
 		if f == nil {
 			continue
@@ -791,7 +791,9 @@ func (ind *PdfIndirectObject) String() string {
 func (ind *PdfIndirectObject) WriteString() string {
 	var b strings.Builder
 	b.WriteString(strconv.FormatInt(ind.ObjectNumber, 10))
-	b.WriteString(" 0 R")
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(ind.GenerationNumber, 10))
+	b.WriteString(" R")
 	return b.String()
 }
 
@@ -804,7 +806,9 @@ func (stream *PdfObjectStream) String() string {
 func (stream *PdfObjectStream) WriteString() string {
 	var b strings.Builder
 	b.WriteString(strconv.FormatInt(stream.ObjectNumber, 10))
-	b.WriteString(" 0 R")
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(stream.GenerationNumber, 10))
+	b.WriteString(" R")
 	return b.String()
 }
 
@@ -844,6 +848,88 @@ func TraceToDirectObject(obj PdfObject) PdfObject {
 	return obj
 }
 
+// CloneObject returns a deep copy of `obj`. Arrays, dictionaries, indirect objects and streams
+// are copied recursively so that no data is shared with `obj`. References are copied but not
+// followed.
+func CloneObject(obj PdfObject) PdfObject {
+	return cloneObject(obj, map[PdfObject]PdfObject{})
+}
+
+// cloneObject returns a deep copy of `obj`. `cloned` maps the objects that have been cloned so
+// far to their copies, so that cycles through indirect objects are cloned once.
+func cloneObject(obj PdfObject, cloned map[PdfObject]PdfObject) PdfObject {
+	if obj == nil {
+		return nil
+	}
+	if c, ok := cloned[obj]; ok {
+		return c
+	}
+
+	switch t := obj.(type) {
+	case *PdfObjectBool:
+		c := *t
+		return &c
+	case *PdfObjectInteger:
+		c := *t
+		return &c
+	case *PdfObjectFloat:
+		c := *t
+		return &c
+	case *PdfObjectString:
+		c := *t
+		return &c
+	case *PdfObjectName:
+		c := *t
+		return &c
+	case *PdfObjectNull:
+		return &PdfObjectNull{}
+	case *PdfObjectReference:
+		c := *t
+		return &c
+	case *PdfObjectArray:
+		c := &PdfObjectArray{vec: make([]PdfObject, 0, len(t.vec))}
+		cloned[obj] = c
+		for _, elem := range t.vec {
+			c.vec = append(c.vec, cloneObject(elem, cloned))
+		}
+		return c
+	case *PdfObjectDictionary:
+		c := MakeDict()
+		c.parser = t.parser
+		cloned[obj] = c
+		for _, key := range t.keys {
+			c.Set(key, cloneObject(t.dict[key], cloned))
+		}
+		return c
+	case *PdfIndirectObject:
+		c := &PdfIndirectObject{PdfObjectReference: t.PdfObjectReference}
+		cloned[obj] = c
+		c.PdfObject = cloneObject(t.PdfObject, cloned)
+		return c
+	case *PdfObjectStream:
+		c := &PdfObjectStream{PdfObjectReference: t.PdfObjectReference}
+		cloned[obj] = c
+		if t.PdfObjectDictionary != nil {
+			c.PdfObjectDictionary = cloneObject(t.PdfObjectDictionary, cloned).(*PdfObjectDictionary)
+		}
+		if t.Stream != nil {
+			c.Stream = make([]byte, len(t.Stream))
+			copy(c.Stream, t.Stream)
+		}
+		return c
+	case *PdfObjectStreams:
+		c := &PdfObjectStreams{PdfObjectReference: t.PdfObjectReference}
+		cloned[obj] = c
+		for _, elem := range t.vec {
+			c.vec = append(c.vec, cloneObject(elem, cloned))
+		}
+		return c
+	}
+
+	common.Log.Debug("CloneObject: unsupported object type %T, not copied", obj)
+	return obj
+}
+
 // Convenience methods for converting PdfObject to underlying types.
 
 // GetBool returns the *PdfObjectBool object that is represented by a PdfObject directly or indirectly
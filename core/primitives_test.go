@@ -115,6 +115,51 @@ func TestUTF16StringEncodeDecode(t *testing.T) {
 	}
 }
 
+// TestCloneObject tests that cloned objects share no data with the original objects.
+func TestCloneObject(t *testing.T) {
+	dict := MakeDict()
+	dict.Set("Name", MakeName("A"))
+	dict.Set("Array", MakeArray(MakeInteger(1), MakeString("abc"), MakeFloat(1.5)))
+	stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: []byte("data")}
+	dict.Set("Stream", stream)
+	ind := &PdfIndirectObject{PdfObject: dict}
+	ind.ObjectNumber = 7
+	// Cycle back to the indirect object.
+	dict.Set("Self", ind)
+
+	original := ind.PdfObject.WriteString()
+	cloned, ok := CloneObject(ind).(*PdfIndirectObject)
+	if !ok {
+		t.Fatalf("cloned object not an indirect object")
+	}
+	if cloned == ind || cloned.ObjectNumber != 7 {
+		t.Fatalf("invalid cloned indirect object: %v", cloned)
+	}
+	clonedDict := cloned.PdfObject.(*PdfObjectDictionary)
+	if clonedDict.Get("Self") != cloned {
+		t.Fatalf("cycle not preserved in clone")
+	}
+	if clonedDict.WriteString() != original {
+		t.Fatalf("clone differs: %s != %s", clonedDict.WriteString(), original)
+	}
+
+	*clonedDict.Get("Name").(*PdfObjectName) = "B"
+	arr := clonedDict.Get("Array").(*PdfObjectArray)
+	*arr.Get(0).(*PdfObjectInteger) = 2
+	arr.Append(MakeNull())
+	clonedDict.Get("Stream").(*PdfObjectStream).Stream[0] = 'x'
+
+	if dict.WriteString() != original {
+		t.Fatalf("original changed: %s != %s", dict.WriteString(), original)
+	}
+	if string(stream.Stream) != "data" {
+		t.Fatalf("original stream changed: %s", stream.Stream)
+	}
+	if CloneObject(nil) != nil {
+		t.Fatalf("clone of nil not nil")
+	}
+}
+
 func BenchmarkPdfObjectIntegerWriteString(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		i := MakeInteger(int64(n))
@@ -36,3 +36,89 @@ const (
 func (p Permissions) Allowed(p2 Permissions) bool {
 	return p&p2 == p2
 }
+
+// permReserved are the bits of the /P value which must be set: bits 7-8 and 13-32.
+const permReserved = Permissions(1<<6|1<<7) | Permissions(math.MaxUint32&^(1<<12-1))
+
+// permMask are the bits of the /P value which must be cleared: bits 1-2.
+const permMask = Permissions(3)
+
+// WithReservedBits returns the permissions with the reserved bits of the /P value set as required
+// by the standard security handler (bits 7-8 and 13-32 set, bits 1-2 cleared).
+func (p Permissions) WithReservedBits() Permissions {
+	return (p | permReserved) &^ permMask
+}
+
+// GetP returns the /P value of the encryption dictionary for the permissions.
+func (p Permissions) GetP() int32 {
+	return int32(p.WithReservedBits())
+}
+
+// AccessPermissions is a struct literal friendly representation of the access permissions of a
+// PDF file. The zero value denies all the permissions.
+type AccessPermissions struct {
+	// AllowPrinting allows printing the document, with a low quality unless
+	// AllowHighQualityPrinting is also set.
+	AllowPrinting bool
+	// AllowModify allows modifying the contents of the document.
+	AllowModify bool
+	// AllowCopy allows copying or extracting the text and graphics of the document.
+	AllowCopy bool
+	// AllowAnnotate allows adding or modifying annotations and filling in form fields.
+	AllowAnnotate bool
+	// AllowFillForms allows filling in form fields, even if AllowAnnotate is not set.
+	AllowFillForms bool
+	// AllowExtractForAccessibility allows extracting text and graphics for accessibility.
+	AllowExtractForAccessibility bool
+	// AllowAssembly allows inserting, rotating or deleting pages and creating bookmarks.
+	AllowAssembly bool
+	// AllowHighQualityPrinting allows printing the document with a high quality.
+	AllowHighQualityPrinting bool
+}
+
+// permFlags maps the fields of AccessPermissions to their Permissions bits.
+func (a *AccessPermissions) permFlags() []struct {
+	allowed *bool
+	perm    Permissions
+} {
+	return []struct {
+		allowed *bool
+		perm    Permissions
+	}{
+		{&a.AllowPrinting, PermPrinting},
+		{&a.AllowModify, PermModify},
+		{&a.AllowCopy, PermExtractGraphics},
+		{&a.AllowAnnotate, PermAnnotate},
+		{&a.AllowFillForms, PermFillForms},
+		{&a.AllowExtractForAccessibility, PermDisabilityExtract},
+		{&a.AllowAssembly, PermRotateInsert},
+		{&a.AllowHighQualityPrinting, PermFullPrintQuality},
+	}
+}
+
+// Permissions returns the permission bitmask for `a`, with the reserved bits set.
+func (a AccessPermissions) Permissions() Permissions {
+	p := permReserved
+	for _, f := range a.permFlags() {
+		if *f.allowed {
+			p |= f.perm
+		}
+	}
+	return p
+}
+
+// GetP returns the /P value of the encryption dictionary for `a`.
+func (a AccessPermissions) GetP() int32 {
+	return a.Permissions().GetP()
+}
+
+// PermissionsFromP returns the access permissions corresponding to the /P value `p` of an
+// encryption dictionary.
+func PermissionsFromP(p int) AccessPermissions {
+	var a AccessPermissions
+	perm := Permissions(uint32(p))
+	for _, f := range a.permFlags() {
+		*f.allowed = perm.Allowed(f.perm)
+	}
+	return a
+}
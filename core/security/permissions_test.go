@@ -0,0 +1,51 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import "testing"
+
+func TestAccessPermissionsGetP(t *testing.T) {
+	testcases := []struct {
+		perms    AccessPermissions
+		expected int32
+	}{
+		{AccessPermissions{}, -3904},
+		{AccessPermissions{AllowPrinting: true}, -3900},
+		{AccessPermissions{
+			AllowPrinting:                true,
+			AllowModify:                  true,
+			AllowCopy:                    true,
+			AllowAnnotate:                true,
+			AllowFillForms:               true,
+			AllowExtractForAccessibility: true,
+			AllowAssembly:                true,
+			AllowHighQualityPrinting:     true,
+		}, -4},
+	}
+
+	for _, tcase := range testcases {
+		p := tcase.perms.GetP()
+		if p != tcase.expected {
+			t.Errorf("%+v: expected P = %d, got %d", tcase.perms, tcase.expected, p)
+		}
+		if back := PermissionsFromP(int(p)); back != tcase.perms {
+			t.Errorf("P = %d: expected %+v, got %+v", p, tcase.perms, back)
+		}
+	}
+}
+
+func TestPermissionsWithReservedBits(t *testing.T) {
+	if p := PermOwner.GetP(); p != -4 {
+		t.Errorf("expected owner P = -4, got %d", p)
+	}
+	p := (PermOwner &^ PermPrinting).WithReservedBits()
+	if p.Allowed(PermPrinting) {
+		t.Errorf("printing should not be allowed (P = %d)", p.GetP())
+	}
+	if !p.Allowed(PermModify) {
+		t.Errorf("modify should be allowed (P = %d)", p.GetP())
+	}
+}
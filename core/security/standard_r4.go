@@ -27,6 +27,12 @@ func NewHandlerR4(id0 string, length int) StdHandler {
 	return stdHandlerR4{ID0: id0, Length: length}
 }
 
+// NewHandlerR4FixedPadding creates a new standard security handler for R<=4 like NewHandlerR4,
+// which pads the generated U entry with fixed instead of random bytes, for reproducible output.
+func NewHandlerR4FixedPadding(id0 string, length int) StdHandler {
+	return stdHandlerR4{ID0: id0, Length: length, fixedPadding: true}
+}
+
 // stdHandlerR4 is a standard security handler for R<=4.
 // It uses RC4 and MD5 to generate encryption parameters.
 // This legacy handler also requires Length parameter from
@@ -34,6 +40,9 @@ func NewHandlerR4(id0 string, length int) StdHandler {
 type stdHandlerR4 struct {
 	Length int
 	ID0    string
+
+	// fixedPadding pads the U entry with fixed bytes instead of random ones.
+	fixedPadding bool
 }
 
 func (stdHandlerR4) paddedPass(pass []byte) []byte {
@@ -207,6 +216,10 @@ func (sh stdHandlerR4) alg5(ekey []byte, upass []byte) ([]byte, error) {
 	// Append 16 bytes of arbitrary padding to the output from the final
 	// invocation of the RC4 function and store the 32-byte result as
 	// the value of the U entry in the encryption dictionary.
+	if sh.fixedPadding {
+		copy(bb[16:32], padding)
+		return bb, nil
+	}
 	_, err = rand.Read(bb[16:32])
 	if err != nil {
 		return nil, errors.New("failed to gen rand number")
@@ -74,6 +74,9 @@ func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
 		return newJBIG2EncoderFromStream(streamObj, nil)
 	case StreamEncodingFilterNameJPX:
 		return NewJPXEncoder(), nil
+	case StreamEncodingFilterNameCrypt:
+		// Applied by the security handler.
+		return NewRawEncoder(), nil
 	}
 	common.Log.Debug("ERROR: Unsupported encoding method!")
 	return nil, fmt.Errorf("unsupported encoding method (%s)", *method)
@@ -0,0 +1,77 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"math"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TextBlock is the text shown in a text object (BT ... ET) of a page content stream, split into
+// lines by the text positioning operators Td, TD, T*, ' and ".
+// The bounding box is in device coordinates.
+type TextBlock struct {
+	BBox  model.PdfRectangle
+	Lines []string
+}
+
+// ExtractTextBlocks returns the text of `e` (an Extractor for a page) as a TextBlock for each text
+// object that shows text, in content stream order. Text objects of form XObjects are included
+// where the forms are drawn. Unlike ExtractText, the text is not reordered by its position on the
+// page so that blocks can be clustered, e.g. to reconstruct tables.
+func (e *Extractor) ExtractTextBlocks() ([]TextBlock, error) {
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]TextBlock, 0, len(pt.blocks))
+	for _, b := range pt.blocks {
+		blocks = append(blocks, b.toTextBlock())
+	}
+	return blocks, nil
+}
+
+// textBlock holds the marks of a text object and the indexes of the marks that start its lines.
+type textBlock struct {
+	marks      []textMark
+	lineStarts []int
+}
+
+// addBlock adds the marks of text object `to` to the blocks of `pt`. Text objects without marks
+// are skipped.
+func (pt *PageText) addBlock(to *textObject) {
+	if len(to.marks) == 0 {
+		return
+	}
+	pt.blocks = append(pt.blocks, textBlock{marks: to.marks, lineStarts: to.lineStarts})
+}
+
+// toTextBlock returns the public view of `b`. Spaces are inserted between the marks of a line in
+// the same way as for the page text.
+func (b textBlock) toTextBlock() TextBlock {
+	block := TextBlock{BBox: b.marks[0].bbox}
+	for _, tm := range b.marks[1:] {
+		block.BBox = rectUnion(block.BBox, tm.bbox)
+	}
+
+	starts := append([]int{0}, b.lineStarts...)
+	for i, start := range starts {
+		end := len(b.marks)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		// The marks of a line are in rendering order, so a single textLine is built for each
+		// orientation by using an infinite line tolerance.
+		var words []string
+		for _, tl := range (PageText{marks: b.marks[start:end]}).toLines(math.Inf(1)) {
+			words = append(words, tl.words()...)
+		}
+		block.Lines = append(block.Lines, strings.Join(words, ""))
+	}
+	return block
+}
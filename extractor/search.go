@@ -0,0 +1,163 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// SearchOptions defines options for Search.
+type SearchOptions struct {
+	// IgnoreCase makes the search case-insensitive.
+	IgnoreCase bool
+
+	// WholeWord only matches the query if it is not preceded or followed by a letter or digit.
+	WholeWord bool
+}
+
+// Match represents an occurrence of a search query in the text of a page.
+type Match struct {
+	// Text is the matched text as extracted from the page.
+	Text string
+
+	// Offset is the offset of Text in the extracted page text, as returned by PageText.Text().
+	Offset int
+
+	// BBoxes are the bounding boxes of the matched text, one per line spanned by the match.
+	BBoxes []model.PdfRectangle
+}
+
+// Search returns the occurrences of `query` in the text of `page`. Runs of whitespace in the query
+// and in the page text are treated as a single space, so that matches can span text showing
+// operators and lines. If `options` is nil, the search is case-sensitive and matches parts of
+// words.
+func Search(page *model.PdfPage, query string, options *SearchOptions) ([]Match, error) {
+	e, err := New(page)
+	if err != nil {
+		return nil, err
+	}
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	return pt.search(query, options)
+}
+
+// search returns the occurrences of `query` in the text of `pt`.
+func (pt PageText) search(query string, options *SearchOptions) ([]Match, error) {
+	if options == nil {
+		options = &SearchOptions{}
+	}
+	q, _ := normalizeSearchText(strings.TrimSpace(query), options.IgnoreCase)
+	if q == "" {
+		return nil, errors.New("empty search query")
+	}
+	text := pt.Text()
+	norm, offsets := normalizeSearchText(text, options.IgnoreCase)
+
+	var matches []Match
+	for pos := 0; pos < len(norm); {
+		i := strings.Index(norm[pos:], q)
+		if i < 0 {
+			break
+		}
+		start, end := pos+i, pos+i+len(q)
+		pos = start + 1
+		if options.WholeWord && !isWordBoundary(norm, start, end) {
+			continue
+		}
+		pos = end
+
+		// Map the match back to the extracted text. The end offset is the end of the last
+		// matched rune.
+		origStart := offsets[start]
+		_, size := utf8.DecodeRuneInString(text[offsets[end-1]:])
+		origEnd := offsets[end-1] + size
+		matches = append(matches, Match{
+			Text:   text[origStart:origEnd],
+			Offset: origStart,
+			BBoxes: pt.rangeBBoxes(origStart, origEnd),
+		})
+	}
+	return matches, nil
+}
+
+// normalizeSearchText returns `text` with runs of whitespace replaced by a single space and
+// lower cased if `ignoreCase` is true. It also returns the offset in `text` of the rune each
+// byte of the normalized text originates from.
+func normalizeSearchText(text string, ignoreCase bool) (string, []int) {
+	var b strings.Builder
+	offsets := make([]int, 0, len(text))
+	lastSpace := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if !lastSpace {
+				b.WriteByte(' ')
+				offsets = append(offsets, i)
+			}
+			lastSpace = true
+			continue
+		}
+		lastSpace = false
+		if ignoreCase {
+			r = unicode.ToLower(r)
+		}
+		n := b.Len()
+		b.WriteRune(r)
+		for ; n < b.Len(); n++ {
+			offsets = append(offsets, i)
+		}
+	}
+	return b.String(), offsets
+}
+
+// isWordBoundary returns true if the text between byte offsets `start` and `end` of `text` is
+// neither preceded nor followed by a letter or digit.
+func isWordBoundary(text string, start, end int) bool {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+// rangeBBoxes returns the bounding boxes of the text between offsets `start` and `end` of the
+// extracted text of `pt`, one per line.
+func (pt PageText) rangeBBoxes(start, end int) []model.PdfRectangle {
+	marks := pt.viewMarks
+	i := sort.Search(len(marks), func(i int) bool { return marks[i].Offset+len(marks[i].Text) > start })
+
+	var bboxes []model.PdfRectangle
+	newLine := true
+	for ; i < len(marks) && marks[i].Offset < end; i++ {
+		tm := marks[i]
+		if tm.Meta && tm.Text == lineJoiner {
+			newLine = true
+			continue
+		}
+		if tm.Meta || isTextSpace(tm.Text) {
+			continue
+		}
+		if newLine {
+			bboxes = append(bboxes, tm.BBox)
+			newLine = false
+			continue
+		}
+		bboxes[len(bboxes)-1] = rectUnion(bboxes[len(bboxes)-1], tm.BBox)
+	}
+	return bboxes
+}
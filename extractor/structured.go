@@ -0,0 +1,137 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// StructuredPage is the text of a page organized as a tree of blocks, lines, words and
+// characters with their bounding boxes. It is intended to be serialized, e.g. to JSON.
+// All bounding boxes are in device coordinates.
+type StructuredPage struct {
+	Blocks []StructuredBlock `json:"blocks"`
+}
+
+// StructuredBlock is a group of vertically adjacent lines of text, e.g. a paragraph.
+type StructuredBlock struct {
+	BBox  model.PdfRectangle `json:"bbox"`
+	Lines []StructuredLine   `json:"lines"`
+}
+
+// StructuredLine is a line of text.
+type StructuredLine struct {
+	BBox  model.PdfRectangle `json:"bbox"`
+	Text  string             `json:"text"`
+	Words []StructuredWord   `json:"words"`
+}
+
+// StructuredWord is a word of text and the font it was drawn with.
+type StructuredWord struct {
+	BBox     model.PdfRectangle `json:"bbox"`
+	Text     string             `json:"text"`
+	Font     string             `json:"font,omitempty"`
+	FontSize float64            `json:"font_size"`
+	Chars    []StructuredChar   `json:"chars"`
+}
+
+// StructuredChar is a character of text. Text may contain more than one rune for ligatures
+// and characters that map to several code points.
+type StructuredChar struct {
+	BBox model.PdfRectangle `json:"bbox"`
+	Text string             `json:"text"`
+}
+
+// ExtractStructured returns the text of `e` (an Extractor for a page) organized into blocks,
+// lines, words and characters.
+func (e *Extractor) ExtractStructured() (*StructuredPage, error) {
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	page := pt.structured()
+	return &page, nil
+}
+
+// structured returns the StructuredPage for the marks of `pt`, which must have been processed by
+// computeViews.
+func (pt PageText) structured() StructuredPage {
+	var lines []StructuredLine
+	var line StructuredLine
+	var word *StructuredWord
+	endWord := func() {
+		if word != nil {
+			if len(line.Words) == 0 {
+				line.BBox = word.BBox
+			}
+			line.Words = append(line.Words, *word)
+			line.BBox = rectUnion(line.BBox, word.BBox)
+			word = nil
+		}
+	}
+	endLine := func() {
+		endWord()
+		if len(line.Words) > 0 {
+			lines = append(lines, line)
+		}
+		line = StructuredLine{}
+	}
+
+	for _, tm := range pt.viewMarks {
+		if tm.Meta && tm.Text == lineJoiner {
+			endLine()
+			continue
+		}
+		line.Text += tm.Text
+		if isTextSpace(tm.Text) {
+			endWord()
+			continue
+		}
+		if word == nil {
+			word = &StructuredWord{BBox: tm.BBox, FontSize: tm.FontSize}
+			if tm.Font != nil {
+				word.Font = tm.Font.BaseFont()
+			}
+		}
+		word.Text += tm.Text
+		word.BBox = rectUnion(word.BBox, tm.BBox)
+		word.Chars = append(word.Chars, StructuredChar{BBox: tm.BBox, Text: tm.Text})
+	}
+	endLine()
+
+	for i := range lines {
+		lines[i].Text = strings.TrimSpace(lines[i].Text)
+	}
+	return StructuredPage{Blocks: groupBlocks(lines)}
+}
+
+// groupBlocks groups consecutive `lines` into blocks. A new block is started when the vertical
+// gap to the previous line exceeds a fraction of the line height or when the text moves up the
+// page, e.g. to the next column.
+func groupBlocks(lines []StructuredLine) []StructuredBlock {
+	var blocks []StructuredBlock
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1].BBox
+			h := maxFloat(prev.Height(), l.BBox.Height())
+			gap := prev.Lly - l.BBox.Ury
+			if gap <= maxBlockLineGap*h && gap >= -h {
+				b := &blocks[len(blocks)-1]
+				b.Lines = append(b.Lines, l)
+				b.BBox = rectUnion(b.BBox, l.BBox)
+				continue
+			}
+		}
+		blocks = append(blocks, StructuredBlock{BBox: l.BBox, Lines: []StructuredLine{l}})
+	}
+	return blocks
+}
+
+// maxBlockLineGap is the largest vertical gap between lines of the same block, relative to the
+// line height.
+const maxBlockLineGap = 0.75
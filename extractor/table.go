@@ -0,0 +1,373 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
+	"github.com/unidoc/unipdf/v3/model"
+)
+
+// TextTable represents a table detected on a page.
+type TextTable struct {
+	// BBox is the bounding box of the table in device coordinates.
+	BBox model.PdfRectangle
+
+	// Cells contains the text of the table cells, row by row from the top of the table.
+	// All rows have the same number of cells.
+	Cells [][]string
+}
+
+// ExtractTables detects tables on the page of `e` and returns their text.
+// Tables are detected from horizontal and vertical ruling lines drawn in the page content stream.
+// If the page has no ruled tables, tables are detected from runs of lines whose words are
+// separated into aligned columns by wide gaps.
+// NOTE: Ruling lines drawn in form XObjects are not taken into account.
+func (e *Extractor) ExtractTables() ([]TextTable, error) {
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	rulings, err := extractRulings(e.contents, e.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := pt.gridTables(rulings)
+	if len(tables) == 0 {
+		tables = pt.whitespaceTables()
+	}
+	return tables, nil
+}
+
+const (
+	// rulingTol is the tolerance in points used when comparing ruling line positions.
+	rulingTol = 2.0
+
+	// minColumnGap is the minimum horizontal gap between words of different columns relative
+	// to the font size, for tables without ruling lines.
+	minColumnGap = 1.0
+)
+
+// ruling is a horizontal or vertical line segment in device coordinates. For horizontal lines
+// `pos` is the y coordinate and `lo`, `hi` the x extent, and vice versa for vertical lines.
+type ruling struct {
+	vertical bool
+	pos      float64
+	lo, hi   float64
+}
+
+// extractRulings returns the axis-aligned line segments stroked or filled in `contents`.
+// Thin filled rectangles, which are commonly used to draw table rules, are returned as lines.
+func extractRulings(contents string, resources *model.PdfPageResources) ([]ruling, error) {
+	operations, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var rulings []ruling
+	var path []ruling
+	var current, start transform.Point
+	addSegment := func(p1, p2 transform.Point) {
+		switch {
+		case math.Abs(p1.Y-p2.Y) < rulingTol:
+			path = append(path, ruling{pos: (p1.Y + p2.Y) / 2,
+				lo: math.Min(p1.X, p2.X), hi: math.Max(p1.X, p2.X)})
+		case math.Abs(p1.X-p2.X) < rulingTol:
+			path = append(path, ruling{vertical: true, pos: (p1.X + p2.X) / 2,
+				lo: math.Min(p1.Y, p2.Y), hi: math.Max(p1.Y, p2.Y)})
+		}
+	}
+
+	processor := contentstream.NewContentStreamProcessor(*operations)
+	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+			resources *model.PdfPageResources) error {
+			switch op.Operand {
+			case "m", "l":
+				vals, err := core.GetNumbersAsFloat(op.Params)
+				if err != nil || len(vals) != 2 {
+					return nil
+				}
+				p := devicePoint(gs.CTM, vals[0], vals[1])
+				if op.Operand == "l" {
+					addSegment(current, p)
+				} else {
+					start = p
+				}
+				current = p
+			case "h":
+				addSegment(current, start)
+				current = start
+			case "re":
+				vals, err := core.GetNumbersAsFloat(op.Params)
+				if err != nil || len(vals) != 4 {
+					return nil
+				}
+				x, y, w, h := vals[0], vals[1], vals[2], vals[3]
+				corners := []transform.Point{
+					devicePoint(gs.CTM, x, y),
+					devicePoint(gs.CTM, x+w, y),
+					devicePoint(gs.CTM, x+w, y+h),
+					devicePoint(gs.CTM, x, y+h),
+				}
+				bbox := model.PdfRectangle{
+					Llx: math.Min(corners[0].X, corners[2].X), Lly: math.Min(corners[0].Y, corners[2].Y),
+					Urx: math.Max(corners[0].X, corners[2].X), Ury: math.Max(corners[0].Y, corners[2].Y),
+				}
+				switch {
+				case bbox.Height() < rulingTol:
+					path = append(path, ruling{pos: (bbox.Lly + bbox.Ury) / 2, lo: bbox.Llx, hi: bbox.Urx})
+				case bbox.Width() < rulingTol:
+					path = append(path, ruling{vertical: true, pos: (bbox.Llx + bbox.Urx) / 2,
+						lo: bbox.Lly, hi: bbox.Ury})
+				default:
+					for i := range corners {
+						addSegment(corners[i], corners[(i+1)%4])
+					}
+				}
+				current, start = corners[0], corners[0]
+			case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*":
+				rulings = append(rulings, path...)
+				path = nil
+			case "n":
+				path = nil
+			}
+			return nil
+		})
+	if err := processor.Process(resources); err != nil {
+		return nil, err
+	}
+	return rulings, nil
+}
+
+// devicePoint returns the point (`x`, `y`) transformed by `ctm`.
+func devicePoint(ctm transform.Matrix, x, y float64) transform.Point {
+	x, y = ctm.Transform(x, y)
+	return transform.Point{X: x, Y: y}
+}
+
+// rulingGrid is a grid of cells formed by connected ruling lines. `xs` are the x coordinates of
+// the column boundaries in increasing order and `ys` are the y coordinates of the row boundaries
+// in decreasing order.
+type rulingGrid struct {
+	xs, ys []float64
+}
+
+// gridTables returns the tables formed by the grids of `rulings` with the text of `pt` in their
+// cells.
+func (pt PageText) gridTables(rulings []ruling) []TextTable {
+	grids := rulingGrids(rulings)
+	if len(grids) == 0 {
+		return nil
+	}
+
+	cells := make([][][]strings.Builder, len(grids))
+	for i, g := range grids {
+		cells[i] = make([][]strings.Builder, len(g.ys)-1)
+		for j := range cells[i] {
+			cells[i][j] = make([]strings.Builder, len(g.xs)-1)
+		}
+	}
+
+	// Add the text of the marks to the cells containing their centers. Runs of whitespace are
+	// replaced by a single space.
+	separator := false
+	for _, tm := range pt.viewMarks {
+		if tm.Meta || isTextSpace(tm.Text) {
+			separator = true
+			continue
+		}
+		x := (tm.BBox.Llx + tm.BBox.Urx) / 2
+		y := (tm.BBox.Lly + tm.BBox.Ury) / 2
+		for i, g := range grids {
+			col := sort.SearchFloat64s(g.xs, x) - 1
+			row := sort.Search(len(g.ys), func(k int) bool { return g.ys[k] < y }) - 1
+			if col < 0 || col >= len(g.xs)-1 || row < 0 || row >= len(g.ys)-1 {
+				continue
+			}
+			cell := &cells[i][row][col]
+			if separator && cell.Len() > 0 {
+				cell.WriteByte(' ')
+			}
+			cell.WriteString(tm.Text)
+			break
+		}
+		separator = false
+	}
+
+	tables := make([]TextTable, len(grids))
+	for i, g := range grids {
+		tables[i].BBox = model.PdfRectangle{Llx: g.xs[0], Lly: g.ys[len(g.ys)-1], Urx: g.xs[len(g.xs)-1], Ury: g.ys[0]}
+		tables[i].Cells = make([][]string, len(cells[i]))
+		for j, row := range cells[i] {
+			tables[i].Cells[j] = make([]string, len(row))
+			for k := range row {
+				tables[i].Cells[j][k] = row[k].String()
+			}
+		}
+	}
+	return tables
+}
+
+// rulingGrids returns the grids formed by groups of intersecting horizontal and vertical
+// `rulings`. Groups that form a single cell, e.g. a box around some text, are not considered
+// tables.
+func rulingGrids(rulings []ruling) []rulingGrid {
+	// Group the rulings connected through intersections.
+	group := make([]int, len(rulings))
+	for i := range group {
+		group[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+	for i, r1 := range rulings {
+		for j := i + 1; j < len(rulings); j++ {
+			r2 := rulings[j]
+			if r1.vertical == r2.vertical {
+				continue
+			}
+			if r1.pos >= r2.lo-rulingTol && r1.pos <= r2.hi+rulingTol &&
+				r2.pos >= r1.lo-rulingTol && r2.pos <= r1.hi+rulingTol {
+				group[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := map[int][]ruling{}
+	var roots []int
+	for i, r := range rulings {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], r)
+	}
+
+	var grids []rulingGrid
+	for _, root := range roots {
+		var xs, ys []float64
+		for _, r := range groups[root] {
+			if r.vertical {
+				xs = append(xs, r.pos)
+			} else {
+				ys = append(ys, r.pos)
+			}
+		}
+		xs = clusterPositions(xs)
+		ys = clusterPositions(ys)
+		if len(xs) < 2 || len(ys) < 2 || (len(xs)-1)*(len(ys)-1) < 2 {
+			continue
+		}
+		for i, j := 0, len(ys)-1; i < j; i, j = i+1, j-1 {
+			ys[i], ys[j] = ys[j], ys[i]
+		}
+		grids = append(grids, rulingGrid{xs: xs, ys: ys})
+	}
+
+	// Order the tables from the top of the page.
+	sort.SliceStable(grids, func(i, j int) bool { return grids[i].ys[0] > grids[j].ys[0] })
+	common.Log.Trace("rulingGrids: %d rulings -> %d grids", len(rulings), len(grids))
+	return grids
+}
+
+// clusterPositions returns the sorted distinct values of `vals`, merging values closer than
+// rulingTol.
+func clusterPositions(vals []float64) []float64 {
+	sort.Float64s(vals)
+	var clustered []float64
+	for _, v := range vals {
+		if n := len(clustered); n > 0 && v-clustered[n-1] < rulingTol {
+			continue
+		}
+		clustered = append(clustered, v)
+	}
+	return clustered
+}
+
+// whitespaceTables returns the tables formed by runs of at least two consecutive lines of `pt`
+// that are split by wide gaps into the same number of horizontally overlapping columns.
+func (pt PageText) whitespaceTables() []TextTable {
+	var tables []TextTable
+	var rows [][]StructuredWord // Cells of the current run, each cell being a merged word.
+	flush := func() {
+		if len(rows) >= 2 {
+			table := TextTable{BBox: rows[0][0].BBox}
+			for _, row := range rows {
+				cells := make([]string, len(row))
+				for i, cell := range row {
+					cells[i] = cell.Text
+					table.BBox = rectUnion(table.BBox, cell.BBox)
+				}
+				table.Cells = append(table.Cells, cells)
+			}
+			tables = append(tables, table)
+		}
+		rows = nil
+	}
+
+	for _, block := range pt.structured().Blocks {
+		for _, line := range block.Lines {
+			row := splitColumns(line.Words)
+			if len(row) < 2 {
+				flush()
+				continue
+			}
+			if len(rows) > 0 && !columnsAligned(rows[len(rows)-1], row) {
+				flush()
+			}
+			rows = append(rows, row)
+		}
+	}
+	flush()
+	return tables
+}
+
+// splitColumns merges `words` of a line into cells separated by gaps wider than minColumnGap
+// times the font size.
+func splitColumns(words []StructuredWord) []StructuredWord {
+	var cells []StructuredWord
+	for i, w := range words {
+		if i > 0 {
+			last := &cells[len(cells)-1]
+			if w.BBox.Llx-last.BBox.Urx < minColumnGap*math.Max(w.FontSize, last.FontSize) {
+				last.Text += " " + w.Text
+				last.BBox = rectUnion(last.BBox, w.BBox)
+				continue
+			}
+		}
+		cells = append(cells, StructuredWord{BBox: w.BBox, Text: w.Text, FontSize: w.FontSize})
+	}
+	return cells
+}
+
+// columnsAligned returns true if rows `r1` and `r2` have the same number of cells and each
+// cell of `r2` horizontally overlaps the corresponding cell of `r1` and no other.
+func columnsAligned(r1, r2 []StructuredWord) bool {
+	if len(r1) != len(r2) {
+		return false
+	}
+	for i := range r1 {
+		if r2[i].BBox.Urx < r1[i].BBox.Llx || r2[i].BBox.Llx > r1[i].BBox.Urx {
+			return false
+		}
+		if i+1 < len(r1) && r2[i].BBox.Urx >= r1[i+1].BBox.Llx {
+			return false
+		}
+	}
+	return true
+}
@@ -111,6 +111,7 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				if inTextObj {
 					common.Log.Debug("BT called while in a text object")
 					pageText.marks = append(pageText.marks, to.marks...)
+					pageText.addBlock(to)
 				}
 				inTextObj = true
 				to = newTextObject(e, resources, gs, &state, &fontStack)
@@ -127,6 +128,7 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				}
 				inTextObj = false
 				pageText.marks = append(pageText.marks, to.marks...)
+				pageText.addBlock(to)
 				to.reset()
 			case "T*": // Move to start of next text line
 				to.nextLine()
@@ -327,6 +329,7 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				}
 
 				pageText.marks = append(pageText.marks, formResult.pageText.marks...)
+				pageText.blocks = append(pageText.blocks, formResult.pageText.blocks...)
 				state.numChars += formResult.numChars
 				state.numMisses += formResult.numMisses
 			}
@@ -639,14 +642,15 @@ type textState struct {
 
 // textObject represents a PDF text object.
 type textObject struct {
-	e         *Extractor
-	resources *model.PdfPageResources
-	gs        contentstream.GraphicsState
-	fontStack *fontStacker
-	state     *textState
-	tm        transform.Matrix // Text matrix. For the character pointer.
-	tlm       transform.Matrix // Text line matrix. For the start of line pointer.
-	marks     []textMark       // Text marks get written here.
+	e          *Extractor
+	resources  *model.PdfPageResources
+	gs         contentstream.GraphicsState
+	fontStack  *fontStacker
+	state      *textState
+	tm         transform.Matrix // Text matrix. For the character pointer.
+	tlm        transform.Matrix // Text line matrix. For the start of line pointer.
+	marks      []textMark       // Text marks get written here.
+	lineStarts []int            // Indexes of the marks that start a new line.
 }
 
 // newTextState returns a default textState.
@@ -672,11 +676,12 @@ func newTextObject(e *Extractor, resources *model.PdfPageResources, gs contentst
 }
 
 // reset sets the text matrix `Tm` and the text line matrix `Tlm` of the text
-// object to the identity matrix. In addition, the marks collection is cleared.
+// object to the identity matrix. In addition, the marks collection and line starts are cleared.
 func (to *textObject) reset() {
 	to.tm = transform.IdentityMatrix()
 	to.tlm = transform.IdentityMatrix()
 	to.marks = nil
+	to.lineStarts = nil
 }
 
 // renderText processes and renders byte array `data` for extraction purposes.
@@ -740,6 +745,22 @@ func (to *textObject) renderText(data []byte) error {
 		// c is the character size in unscaled text units.
 		c := transform.Point{X: m.Wx * glyphTextRatio, Y: m.Wy * glyphTextRatio}
 
+		if font.IsVertical() {
+			mark := to.newVerticalTextMark(string(r), trm, c, math.Abs(spaceWidth*trm.ScalingFactorX()), font)
+			if encoder := font.Encoder(); encoder != nil {
+				if original, ok := encoder.CharcodeToRune(code); ok {
+					mark.original = string(original)
+				}
+			}
+			common.Log.Trace("i=%d code=%d mark=%s trm=%s", i, code, mark, trm)
+			to.marks = append(to.marks, mark)
+
+			// In vertical writing mode the text advances by the vertical displacement of the glyph.
+			// Horizontal scaling does not apply.
+			to.tm.Concat(translationMatrix(transform.Point{Y: c.Y*tfs + state.tc + w}))
+			continue
+		}
+
 		// t0 is the end of this character.
 		// t is the displacement of the text cursor when the character is rendered.
 		t0 := transform.Point{X: (c.X*tfs + w) * th}
@@ -800,9 +821,13 @@ func translationMatrix(p transform.Point) transform.Matrix {
 // start of line pointer.
 // Move to the start of the next line, offset from the start of the current line by (tx, ty).
 // `tx` and `ty` are in unscaled text space units.
+// The marks rendered after the move start a new line of the text object.
 func (to *textObject) moveTo(tx, ty float64) {
 	to.tlm.Concat(transform.NewMatrix(1, 0, 0, 1, tx, ty))
 	to.tm = to.tlm
+	if n := len(to.marks); n > 0 && (len(to.lineStarts) == 0 || to.lineStarts[len(to.lineStarts)-1] != n) {
+		to.lineStarts = append(to.lineStarts, n)
+	}
 }
 
 // textMark represents text drawn on a page and its position in device coordinates.
@@ -872,6 +897,34 @@ func (to *textObject) newTextMark(text string, trm transform.Matrix, end transfo
 	return tm
 }
 
+// newVerticalTextMark returns a textMark for text `text` rendered in vertical writing mode with
+// text rendering matrix `trm`. `c` is the size of the glyph in unscaled text units where c.Y is the
+// (negative) vertical displacement.
+// The glyph origin in vertical writing mode is at the top center of the glyph. The mark is laid out
+// as text rotated so that it reads downwards, with successive columns from right to left, so that
+// the marks are sorted in reading order.
+func (to *textObject) newVerticalTextMark(text string, trm transform.Matrix, c transform.Point,
+	spaceWidth float64, font *model.PdfFont) textMark {
+	// The corners of the glyph box in device coordinates.
+	halfWidth := c.X / 2
+	var corners [4]transform.Point
+	for i, p := range []transform.Point{{X: -halfWidth}, {X: halfWidth}, {X: -halfWidth, Y: c.Y}, {X: halfWidth, Y: c.Y}} {
+		corners[i] = translation(trm.Mult(translationMatrix(p)))
+	}
+
+	// Rotate the text space so that the text direction is downwards and the glyph's up direction
+	// points to the right.
+	rotated := trm.Mult(translationMatrix(transform.Point{X: -halfWidth})).Mult(transform.NewMatrix(0, -1, 1, 0, 0, 0))
+	tm := to.newTextMark(text, rotated, corners[2], spaceWidth, font, to.state.tc)
+
+	bbox := model.PdfRectangle{Llx: corners[0].X, Lly: corners[0].Y, Urx: corners[0].X, Ury: corners[0].Y}
+	for _, p := range corners[1:] {
+		bbox = rectUnion(bbox, model.PdfRectangle{Llx: p.X, Lly: p.Y, Urx: p.X, Ury: p.Y})
+	}
+	tm.bbox = bbox
+	return tm
+}
+
 // isTextSpace returns true if `text` contains nothing but space code points.
 func isTextSpace(text string) bool {
 	for _, r := range text {
@@ -916,9 +969,10 @@ func (tm textMark) ToTextMark() TextMark {
 
 // PageText represents the layout of text on a device page.
 type PageText struct {
-	marks     []textMark // Texts and their positions on a PDF page.
-	viewText  string     // Extracted page text.
-	viewMarks []TextMark // Public view of `marks`.
+	marks     []textMark  // Texts and their positions on a PDF page.
+	blocks    []textBlock // Texts of the text objects (BT ... ET) on a PDF page.
+	viewText  string      // Extracted page text.
+	viewMarks []TextMark  // Public view of `marks`.
 }
 
 // String returns a string describing `pt`.
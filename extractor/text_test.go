@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/creator"
 	"github.com/unidoc/unipdf/v3/internal/transform"
 	"github.com/unidoc/unipdf/v3/model"
@@ -141,6 +142,419 @@ func TestTextExtractionFragments(t *testing.T) {
 	}
 }
 
+// withoutLicenseNotice returns `text` without the notice appended to short texts extracted by
+// unlicensed copies, which are not recognized as tests when the testing flags are registered
+// after the package initialization.
+func withoutLicenseNotice(text string) string {
+	return strings.TrimSuffix(text, "- [Unlicensed UniDoc - Get a license on https://unidoc.io]")
+}
+
+// TestTextExtractionType3 tests text extraction with a Type3 font whose glyph names have no
+// Unicode meaning, so that the text can only be recovered through the ToUnicode CMap.
+func TestTextExtractionType3(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+/CMapType 2 def
+1 begincodespacerange
+<00> <FF>
+endcodespacerange
+2 beginbfchar
+<01> <0048>
+<02> <0069>
+endbfchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+	toUnicode, err := core.MakeStream([]byte(cmap), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	glyph, err := core.MakeStream([]byte("50 0 0 0 50 100 d1 0 0 50 100 re f"), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	charProcs := core.MakeDict()
+	charProcs.Set("g1", glyph)
+	charProcs.Set("g2", glyph)
+	encoding := core.MakeDict()
+	encoding.Set("Type", core.MakeName("Encoding"))
+	encoding.Set("Differences", core.MakeArray(core.MakeInteger(1), core.MakeName("g1"), core.MakeName("g2")))
+
+	font := core.MakeDict()
+	font.Set("Type", core.MakeName("Font"))
+	font.Set("Subtype", core.MakeName("Type3"))
+	font.Set("FontBBox", core.MakeArrayFromIntegers([]int{0, 0, 100, 100}))
+	font.Set("FontMatrix", core.MakeArrayFromFloats([]float64{0.01, 0, 0, 0.01, 0, 0}))
+	font.Set("CharProcs", charProcs)
+	font.Set("Encoding", encoding)
+	font.Set("FirstChar", core.MakeInteger(1))
+	font.Set("LastChar", core.MakeInteger(2))
+	font.Set("Widths", core.MakeArrayFromIntegers([]int{50, 50}))
+	font.Set("ToUnicode", toUnicode)
+
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("T3", font)
+
+	contents := `
+        BT
+        /T3 24 Tf
+        10 10 Td
+        <0102>Tj
+        0 -30 Td
+        <02>Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	text, err := e.ExtractText()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+	if text = withoutLicenseNotice(text); text != "Hi\ni" {
+		t.Fatalf("Text mismatch: Got %q. Expected %q", text, "Hi\ni")
+	}
+}
+
+// TestTextExtractionVertical tests text extraction with a font in vertical writing mode, where
+// text advances downwards and columns are read from right to left.
+func TestTextExtractionVertical(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Adobe-Identity-UCS def
+/CMapType 2 def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+4 beginbfchar
+<0001> <65E5>
+<0002> <672C>
+<0003> <8A9E>
+<0004> <6587>
+endbfchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+	toUnicode, err := core.MakeStream([]byte(cmap), nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	descendant := core.MakeDict()
+	descendant.Set("Type", core.MakeName("Font"))
+	descendant.Set("Subtype", core.MakeName("CIDFontType2"))
+	descendant.Set("BaseFont", core.MakeName("TestCJK"))
+	sysInfo := core.MakeDict()
+	sysInfo.Set("Registry", core.MakeString("Adobe"))
+	sysInfo.Set("Ordering", core.MakeString("Identity"))
+	sysInfo.Set("Supplement", core.MakeInteger(0))
+	descendant.Set("CIDSystemInfo", sysInfo)
+	descendant.Set("DW", core.MakeInteger(1000))
+	descendant.Set("W2", core.MakeArray(core.MakeInteger(4), core.MakeArrayFromIntegers([]int{-500, 500, 880})))
+
+	font := core.MakeDict()
+	font.Set("Type", core.MakeName("Font"))
+	font.Set("Subtype", core.MakeName("Type0"))
+	font.Set("BaseFont", core.MakeName("TestCJK"))
+	font.Set("Encoding", core.MakeName("Identity-V"))
+	font.Set("DescendantFonts", core.MakeArray(descendant))
+	font.Set("ToUnicode", toUnicode)
+
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("F1", font)
+
+	// The left column is drawn first.
+	contents := `
+        BT
+        /F1 20 Tf
+        1 0 0 1 100 700 Tm
+        <00030004>Tj
+        <0001>Tj
+        1 0 0 1 130 700 Tm
+        <00010002>Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+	if text := withoutLicenseNotice(pt.Text()); text != "日本\n語文日" {
+		t.Fatalf("Text mismatch: Got %q. Expected %q", text, "日本\n語文日")
+	}
+
+	// The glyph boxes are centered on the column and advance downwards. The glyph for CID 4 has a
+	// vertical displacement of 500 from W2.
+	expected := map[string]model.PdfRectangle{
+		"日": r(120, 680, 140, 700),
+		"本": r(120, 660, 140, 680),
+		"語": r(90, 680, 110, 700),
+		"文": r(90, 670, 110, 680),
+	}
+	for _, tm := range pt.Marks().Elements()[:5] {
+		b, ok := expected[tm.Text]
+		if !ok || tm.Meta {
+			continue
+		}
+		delete(expected, tm.Text)
+		if !rectEquals(b, tm.BBox) {
+			t.Errorf("BBox mismatch for %q: Got %+v. Expected %+v", tm.Text, tm.BBox, b)
+		}
+	}
+	if len(expected) != 0 {
+		t.Errorf("Missing marks: %v", expected)
+	}
+}
+
+// TestExtractStructured tests grouping of extracted text into blocks, lines, words and characters.
+func TestExtractStructured(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("F1", helvetica.ToPdfObject())
+
+	contents := `
+        BT
+        /F1 10 Tf
+        100 700 Td
+        (Hello World)Tj
+        0 -12 Td
+        (Second line)Tj
+        0 -40 Td
+        (New block)Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	page, err := e.ExtractStructured()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+	if len(page.Blocks) != 2 {
+		t.Fatalf("Expected 2 blocks. Got %d", len(page.Blocks))
+	}
+	var lines []string
+	for _, b := range page.Blocks {
+		for _, l := range b.Lines {
+			lines = append(lines, l.Text)
+		}
+	}
+	expected := []string{"Hello World", "Second line", "New block"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Lines mismatch: Got %q. Expected %q", lines, expected)
+	}
+	if n := len(page.Blocks[0].Lines); n != 2 {
+		t.Fatalf("Expected 2 lines in first block. Got %d", n)
+	}
+
+	words := page.Blocks[0].Lines[0].Words
+	if len(words) != 2 || words[0].Text != "Hello" || words[1].Text != "World" {
+		t.Fatalf("Words mismatch: %+v", words)
+	}
+	w := words[0]
+	if w.Font != "Helvetica" || w.FontSize != 10 || len(w.Chars) != 5 {
+		t.Fatalf("Word mismatch: font=%q size=%g chars=%d", w.Font, w.FontSize, len(w.Chars))
+	}
+	if math.Abs(w.BBox.Llx-100) > 0.01 || w.BBox.Urx <= w.Chars[3].BBox.Urx {
+		t.Fatalf("Word bbox mismatch: %+v", w.BBox)
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("Error marshalling: err=%v", err)
+	}
+	var decoded StructuredPage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Error unmarshalling: err=%v", err)
+	}
+	if decoded.Blocks[1].Lines[0].Words[1].Text != "block" {
+		t.Fatalf("JSON round trip mismatch: %s", data)
+	}
+}
+
+// TestExtractTextBlocks tests splitting extracted text into blocks for the text objects and lines
+// for the text positioning operators.
+func TestExtractTextBlocks(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("F1", helvetica.ToPdfObject())
+
+	contents := `
+        BT
+        /F1 10 Tf
+        12 TL
+        100 700 Td
+        (Hello World)Tj
+        0 -12 Td
+        (Second)Tj
+        [(li)-20(ne)]TJ
+        T*
+        (Third line)Tj
+        (Fourth line)'
+        ET
+        BT
+        200 0 Td
+        ET
+        BT
+        /F1 10 Tf
+        300 700 Td
+        (Cell)Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	blocks, err := e.ExtractTextBlocks()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks. Got %d", len(blocks))
+	}
+	expected := []string{"Hello World", "Secondline", "Third line", "Fourth line"}
+	if strings.Join(blocks[0].Lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Lines mismatch: Got %q. Expected %q", blocks[0].Lines, expected)
+	}
+	if len(blocks[1].Lines) != 1 || blocks[1].Lines[0] != "Cell" {
+		t.Fatalf("Lines mismatch: Got %q", blocks[1].Lines)
+	}
+	if math.Abs(blocks[1].BBox.Llx-300) > 0.01 || blocks[0].BBox.Urx >= blocks[1].BBox.Llx {
+		t.Fatalf("BBox mismatch: %+v %+v", blocks[0].BBox, blocks[1].BBox)
+	}
+}
+
+// TestSearch tests searching text with bounding boxes, including matches that cross text showing
+// operators and lines.
+func TestSearch(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	courier := model.NewStandard14FontMustCompile(model.CourierName)
+	resources.SetFontByName("F1", courier.ToPdfObject())
+
+	// Courier glyphs are 6 points wide at 10 points font size.
+	contents := `
+        BT
+        /F1 10 Tf
+        100 700 Td
+        (The quick )Tj
+        [(br) (own)] TJ
+        ( fox)Tj
+        0 -20 Td
+        (jumps over the Brownie)Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	pt, _, _, err := e.ExtractPageText()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+
+	matches, err := pt.search("brown", nil)
+	if err != nil {
+		t.Fatalf("Error searching: err=%v", err)
+	}
+	if len(matches) != 1 || matches[0].Text != "brown" || len(matches[0].BBoxes) != 1 {
+		t.Fatalf("Unexpected matches: %+v", matches)
+	}
+	if b := matches[0].BBoxes[0]; math.Abs(b.Llx-160) > 0.01 || math.Abs(b.Urx-190) > 0.01 {
+		t.Fatalf("Unexpected bbox: %+v", b)
+	}
+
+	matches, err = pt.search("BROWN", &SearchOptions{IgnoreCase: true})
+	if err != nil {
+		t.Fatalf("Error searching: err=%v", err)
+	}
+	if len(matches) != 2 || matches[1].Text != "Brown" {
+		t.Fatalf("Unexpected case-insensitive matches: %+v", matches)
+	}
+
+	matches, err = pt.search("brown", &SearchOptions{IgnoreCase: true, WholeWord: true})
+	if err != nil {
+		t.Fatalf("Error searching: err=%v", err)
+	}
+	if len(matches) != 1 || matches[0].Text != "brown" {
+		t.Fatalf("Unexpected whole word matches: %+v", matches)
+	}
+
+	// A match spanning two lines has a bounding box per line.
+	matches, err = pt.search("fox  jumps", nil)
+	if err != nil {
+		t.Fatalf("Error searching: err=%v", err)
+	}
+	if len(matches) != 1 || len(matches[0].BBoxes) != 2 {
+		t.Fatalf("Unexpected multi-line matches: %+v", matches)
+	}
+	if matches[0].Text != "fox\njumps" {
+		t.Fatalf("Unexpected multi-line match text: %q", matches[0].Text)
+	}
+
+	if _, err := pt.search(" ", nil); err == nil {
+		t.Fatalf("Expected error for empty query")
+	}
+}
+
+// TestExtractTables tests table detection from ruling lines and from aligned columns of text.
+func TestExtractTables(t *testing.T) {
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("F1", helvetica.ToPdfObject())
+
+	// A 2x3 grid drawn with stroked lines and a thin filled rectangle, and some text outside.
+	contents := `
+        0.5 w
+        100 700 m 400 700 l 100 680 m 400 680 l S
+        100 659.5 300 1 re f
+        q 1 0 0 1 100 0 cm
+        0 660 m 0 700 l 100 660 m 100 700 l 200 660 m 200 700 l 300 660 m 300 700 l S
+        Q
+        BT
+        /F1 10 Tf
+        105 685 Td (Name) Tj
+        100 0 Td (Unit) Tj
+        100 0 Td (Total) Tj
+        -200 -20 Td (Big apple) Tj
+        200 0 Td (12) Tj
+        -195 100 Td (Title) Tj
+        ET
+        `
+	e := Extractor{resources: resources, contents: contents}
+	tables, err := e.ExtractTables()
+	if err != nil {
+		t.Fatalf("Error extracting tables: err=%v", err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("Expected 1 table. Got %d", len(tables))
+	}
+	expected := [][]string{{"Name", "Unit", "Total"}, {"Big apple", "", "12"}}
+	if fmt.Sprint(tables[0].Cells) != fmt.Sprint(expected) {
+		t.Fatalf("Cells mismatch: Got %q. Expected %q", tables[0].Cells, expected)
+	}
+	if b := tables[0].BBox; math.Abs(b.Llx-100) > 1 || math.Abs(b.Urx-400) > 1 ||
+		math.Abs(b.Lly-660) > 1 || math.Abs(b.Ury-700) > 1 {
+		t.Fatalf("Unexpected table bbox: %+v", b)
+	}
+
+	// Without ruling lines, columns are detected from wide gaps between words.
+	contents = `
+        BT
+        /F1 10 Tf
+        100 700 Td (Some introduction text) Tj
+        0 -20 Td (Fruit) Tj 100 0 Td (Price) Tj
+        -100 -12 Td (Green apple) Tj 100 0 Td (1.20) Tj
+        -100 -12 Td (Pear) Tj 100 0 Td (0.80) Tj
+        ET
+        `
+	e = Extractor{resources: resources, contents: contents}
+	tables, err = e.ExtractTables()
+	if err != nil {
+		t.Fatalf("Error extracting tables: err=%v", err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("Expected 1 table. Got %d", len(tables))
+	}
+	expected = [][]string{{"Fruit", "Price"}, {"Green apple", "1.20"}, {"Pear", "0.80"}}
+	if fmt.Sprint(tables[0].Cells) != fmt.Sprint(expected) {
+		t.Fatalf("Cells mismatch: Got %q. Expected %q", tables[0].Cells, expected)
+	}
+}
+
 // TestTextExtractionFiles tests text extraction on a set of PDF files.
 // It checks for the existence of specified strings of words on specified pages.
 // We currently only check within lines as our line order is still improving.
@@ -95,6 +95,7 @@ type CMap struct {
 	nbits      int // 8 bits for simple fonts, 16 bits for CID fonts.
 	ctype      int
 	version    string
+	wmode      int    // Writing mode: 0 for horizontal, 1 for vertical.
 	usecmap    string // Base this cmap on `usecmap` if `usecmap` is not empty.
 	systemInfo CIDSystemInfo
 
@@ -361,6 +362,11 @@ func (cmap *CMap) Name() string {
 	return cmap.name
 }
 
+// WMode returns the writing mode of the CMap: 0 for horizontal and 1 for vertical.
+func (cmap *CMap) WMode() int {
+	return cmap.wmode
+}
+
 // Type returns the CMap type.
 func (cmap *CMap) Type() int {
 	return cmap.ctype
@@ -443,11 +449,12 @@ func (cmap *CMap) toBfData() string {
 	}
 	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
 
-	// charRanges is a list of the contiguous character code ranges in `codes`.
+	// charRanges is a list of the contiguous character code ranges in `codes` that map to
+	// contiguous runes.
 	var charRanges []charRange
 	c0, c1 := codes[0], codes[0]+1
 	for _, c := range codes[1:] {
-		if c != c1 {
+		if c != c1 || cmap.codeToUnicode[c] != cmap.codeToUnicode[c0]+rune(c-c0) {
 			charRanges = append(charRanges, charRange{c0, c1})
 			c0 = c
 		}
@@ -92,6 +92,11 @@ func (cmap *CMap) parse() error {
 				if err != nil {
 					return err
 				}
+			case cmapwmode:
+				err := cmap.parseWMode()
+				if err != nil {
+					return err
+				}
 			}
 		}
 		prev = o
@@ -166,6 +171,33 @@ func (cmap *CMap) parseType() error {
 	return nil
 }
 
+// parseWMode parses a cmap writing mode and adds it to `cmap`.
+// cmap writing modes are defined like this: /WMode 1 def
+func (cmap *CMap) parseWMode() error {
+	wmode := 0
+	done := false
+	for i := 0; i < 3 && !done; i++ {
+		o, err := cmap.parseObject()
+		if err != nil {
+			return err
+		}
+		switch t := o.(type) {
+		case cmapOperand:
+			switch t.Operand {
+			case "def":
+				done = true
+			default:
+				common.Log.Debug("ERROR: parseWMode: state error. o=%#v", o)
+				return ErrBadCMap
+			}
+		case cmapInt:
+			wmode = int(t.val)
+		}
+	}
+	cmap.wmode = wmode
+	return nil
+}
+
 // parseVersion parses a cmap version and adds it to `cmap`.
 // cmap names are defined like this: /CMapType 1 def
 // We don't need the version. We do this to eat up the version code in the cmap definition
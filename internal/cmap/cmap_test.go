@@ -615,6 +615,16 @@ var (
 		0x0316: '̖',
 		0x0317: '̗',
 	}
+	// codeToUnicode4 has contiguous codes that don't map to contiguous runes, as in the ToUnicode
+	// CMaps of composite fonts where the codes are glyph indexes.
+	codeToUnicode4 = map[CharCode]rune{ // 6 entries
+		0x1081: '日',
+		0x1082: '月',
+		0x1083: '火',
+		0x1156: '本',
+		0x1157: '本' + 1,
+		0x28a4: '語',
+	}
 )
 
 const bfData1 = `
@@ -658,6 +668,7 @@ func TestCMapCreation(t *testing.T) {
 	checkCmapWriteRead(t, codeToUnicode1)
 	checkCmapWriteRead(t, codeToUnicode2)
 	checkCmapWriteRead(t, codeToUnicode3)
+	checkCmapWriteRead(t, codeToUnicode4)
 }
 
 // checkCmapWriteRead creates CMap data from `codeToUnicode` then parses it and checks that the
@@ -701,3 +712,40 @@ func checkCmapWriteRead(t *testing.T, codeToUnicode map[CharCode]rune) {
 		}
 	}
 }
+
+// TestCMapWMode checks that the writing mode is parsed from CMaps.
+func TestCMapWMode(t *testing.T) {
+	data := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /Test-V def
+/CMapType 1 def
+/WMode 1 def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+1 begincidrange
+<0000> <FFFF> 0
+endcidrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`
+	cmap, err := LoadCmapFromDataCID([]byte(data))
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if cmap.WMode() != 1 {
+		t.Errorf("Expected WMode 1. Got %d", cmap.WMode())
+	}
+
+	for name, expected := range map[string]int{"UniJIS-UCS2-H": 0, "UniJIS-UCS2-V": 1} {
+		cmap, err := LoadPredefinedCMap(name)
+		if err != nil {
+			t.Fatalf("Error loading %s: %v", name, err)
+		}
+		if cmap.WMode() != expected {
+			t.Errorf("%s: expected WMode %d. Got %d", name, expected, cmap.WMode())
+		}
+	}
+}
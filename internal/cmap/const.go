@@ -33,4 +33,5 @@ const (
 	cmapname    = "CMapName"
 	cmaptype    = "CMapType"
 	cmapversion = "CMapVersion"
+	cmapwmode   = "WMode"
 )
@@ -7,6 +7,7 @@ package model
 
 import (
 	"bytes"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
@@ -498,7 +499,7 @@ func (a *PdfAppender) Sign(pageNum int, field *PdfFieldSignature) error {
 	if acroForm == nil {
 		acroForm = NewPdfAcroForm()
 	}
-	acroForm.SigFlags = core.MakeInteger(3)
+	acroForm.SigFlags = core.MakeInteger(int64(SigFlagSignaturesExist | SigFlagAppendOnly))
 
 	fields := append(acroForm.AllFields(), field.PdfField)
 	acroForm.Fields = &fields
@@ -707,6 +708,14 @@ func (a *PdfAppender) Write(w io.Writer) error {
 	writer.minorVersion = a.roReader.PdfVersion().Minor
 	writer.appendReplaceMap = a.replaceObjects
 
+	ids, err := revisionIDs(trailer)
+	if err != nil {
+		return err
+	}
+	if ids != nil {
+		writer.ids = ids
+	}
+
 	xrefType := a.parser.GetXrefType()
 	if xrefType != nil {
 		v := *xrefType == core.XrefTypeObjectStream
@@ -832,3 +841,18 @@ func (a *PdfAppender) WriteToFile(outputPath string) error {
 	defer fWrite.Close()
 	return a.Write(fWrite)
 }
+
+// revisionIDs returns the file identifiers of a new revision of the document with trailer
+// `trailer`, which keep the permanent identifier of the document and change the one identifying
+// the revision. It returns nil if the document has no identifiers.
+func revisionIDs(trailer *core.PdfObjectDictionary) (*core.PdfObjectArray, error) {
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	if !ok || ids.Len() != 2 {
+		return nil, nil
+	}
+	revisionID := make([]byte, 16)
+	if _, err := rand.Read(revisionID); err != nil {
+		return nil, err
+	}
+	return core.MakeArray(ids.Get(0), core.MakeHexString(string(revisionID))), nil
+}
@@ -125,7 +125,7 @@ func TestAppenderNoop(t *testing.T) {
 			3: core.XrefObject{ObjectNumber: 3, XType: 0, Offset: 178},
 			4: core.XrefObject{ObjectNumber: 4, XType: 0, Offset: 457},
 			5: core.XrefObject{ObjectNumber: 5, XType: 0, Offset: 740},
-			6: core.XrefObject{ObjectNumber: 6, XType: 0, Offset: 802},
+			6: core.XrefObject{ObjectNumber: 6, XType: 0, Offset: 819},
 		}
 		require.Equal(t, expected, xrefs.ObjectMap)
 	}
@@ -317,6 +317,47 @@ func TestAppenderAddAnnotation(t *testing.T) {
 	}
 }
 
+// Test that an incremental update keeps the original bytes and the permanent document identifier.
+func TestAppenderKeepsDocumentID(t *testing.T) {
+	original, err := ioutil.ReadFile(testPdf3pages)
+	require.NoError(t, err)
+	reader, err := model.NewPdfReader(bytes.NewReader(original))
+	require.NoError(t, err)
+	origTrailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	origIDs, ok := core.GetArray(origTrailer.Get("ID"))
+	require.True(t, ok)
+
+	appender, err := model.NewPdfAppender(reader)
+	require.NoError(t, err)
+	page := reader.PageList[0]
+	annotation := model.NewPdfAnnotationSquare()
+	annotation.Rect = core.MakeArrayFromFloats([]float64{50, 50, 150, 250})
+	page.AddAnnotation(annotation.PdfAnnotation)
+	appender.UpdatePage(page)
+
+	var buf bytes.Buffer
+	require.NoError(t, appender.Write(&buf))
+	out := buf.Bytes()
+	require.Equal(t, original, out[:len(original)])
+
+	reader, err = model.NewPdfReader(bytes.NewReader(out))
+	require.NoError(t, err)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	require.True(t, ok)
+	require.Equal(t, 2, ids.Len())
+	require.Equal(t, origIDs.Get(0).String(), ids.Get(0).String())
+	require.NotEqual(t, origIDs.Get(1).String(), ids.Get(1).String())
+
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	annots, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annots, 1)
+}
+
 // Append annotation to page which already has annotations.
 func TestAppenderAddAnnotation2(t *testing.T) {
 	f1, err := os.Open("testdata/OoPdfFormExample.pdf")
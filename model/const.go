@@ -20,6 +20,9 @@ var (
 	ErrNoFont                   = errors.New("font not defined")
 	ErrFontNotSupported         = errors.New("unsupported font")
 	ErrType1CFontNotSupported   = errors.New("Type1C fonts are not currently supported")
-	ErrType3FontNotSupported    = errors.New("Type3 fonts are not currently supported")
 	ErrTTCmapNotSupported       = errors.New("unsupported TrueType cmap format")
+	ErrGlyphNotFound            = errors.New("glyph not found in font")
+
+	// Deprecated: Type3 fonts are now loaded; this error is no longer returned.
+	ErrType3FontNotSupported = errors.New("Type3 fonts are not currently supported")
 )
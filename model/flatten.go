@@ -6,6 +6,7 @@
 package model
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/unidoc/unipdf/v3/common"
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/transform"
 )
 
 // ContentStreamWrapper wraps the Page's contentstream into q ... Q blocks.
@@ -248,3 +250,251 @@ func getAnnotationActiveAppearance(annot *PdfAnnotation) (*XObjectForm, *PdfRect
 	common.Log.Debug("Invalid type for N: %T", nobj)
 	return nil, nil, errors.New("type check error")
 }
+
+// FlattenForms flattens the form fields of the pages added to the writer into the page contents.
+// The normal appearance (AP /N) of each widget annotation is drawn at the annotation Rect, taking
+// the BBox and Matrix of the appearance into account, and the widget annotations are removed
+// from the pages. Hidden widgets are removed without being drawn. No AcroForm is written
+// afterwards.
+func (w *PdfWriter) FlattenForms() error {
+	pagesDict, ok := core.GetDict(w.pages)
+	if !ok {
+		return errors.New("invalid Pages obj (not a dict)")
+	}
+	kids, ok := core.GetArray(pagesDict.Get("Kids"))
+	if !ok {
+		return errors.New("invalid Pages Kids obj (not an array)")
+	}
+	for _, kid := range kids.Elements() {
+		pageDict, ok := core.GetDict(kid)
+		if !ok {
+			return errors.New("page object should be a dictionary")
+		}
+		if err := w.flattenPageWidgets(pageDict); err != nil {
+			return err
+		}
+	}
+
+	// The form and its fields are no longer referenced.
+	if form := w.catalog.Get("AcroForm"); form != nil {
+		w.removeFields(form, map[core.PdfObject]struct{}{})
+	}
+	w.acroForm = nil
+	w.sigFlags = nil
+	w.catalog.Remove("AcroForm")
+	return nil
+}
+
+// flattenPageWidgets draws the appearances of the widget annotations of the page dictionary
+// `pageDict` in its contents and removes the widgets from its annotations.
+func (w *PdfWriter) flattenPageWidgets(pageDict *core.PdfObjectDictionary) error {
+	annots, ok := core.GetArray(pageDict.Get("Annots"))
+	if !ok {
+		return nil
+	}
+
+	var kept []core.PdfObject
+	var content bytes.Buffer
+	for _, annotObj := range annots.Elements() {
+		annotDict, ok := core.GetDict(annotObj)
+		if !ok {
+			kept = append(kept, annotObj)
+			continue
+		}
+		if subtype, _ := core.GetNameVal(annotDict.Get("Subtype")); subtype != "Widget" {
+			kept = append(kept, annotObj)
+			continue
+		}
+		w.removeObject(core.ResolveReference(annotObj))
+		// Remove the fields of the widget, which are written with the form otherwise.
+		visited := map[core.PdfObject]struct{}{}
+		for parent := annotDict.Get("Parent"); parent != nil; {
+			parentObj := core.ResolveReference(parent)
+			if _, ok := visited[parentObj]; ok {
+				break
+			}
+			visited[parentObj] = struct{}{}
+			w.removeObject(parentObj)
+			parentDict, ok := core.GetDict(parentObj)
+			if !ok {
+				break
+			}
+			parent = parentDict.Get("Parent")
+		}
+
+		// Hidden flag (bit 2, Table 165 p. 386 PDF32000_2008).
+		if flags, ok := core.GetIntVal(annotDict.Get("F")); ok && flags&2 != 0 {
+			continue
+		}
+		xform, ok := getWidgetNormalAppearance(annotDict)
+		if !ok {
+			continue
+		}
+		cm, ok := widgetAppearanceMatrix(annotDict, xform)
+		if !ok {
+			continue
+		}
+
+		resDict, ok := core.GetDict(pageDict.Get("Resources"))
+		if !ok {
+			resDict = core.MakeDict()
+			pageDict.Set("Resources", resDict)
+		}
+		if _, ok := core.GetDict(resDict.Get("XObject")); !ok {
+			resDict.Set("XObject", core.MakeDict())
+		}
+		name, err := stampResourceName(resDict.Get("XObject"), "Fm", xform)
+		if err != nil {
+			return err
+		}
+		if err := w.addObjects(xform); err != nil {
+			return err
+		}
+		fmt.Fprintf(&content, "q\n%.6f %.6f %.6f %.6f %.6f %.6f cm\n/%s Do\nQ\n",
+			cm[0], cm[1], cm[3], cm[4], cm[6], cm[7], name)
+	}
+
+	if len(kept) > 0 {
+		pageDict.Set("Annots", core.MakeArray(kept...))
+	} else {
+		pageDict.Remove("Annots")
+	}
+	if content.Len() == 0 {
+		return nil
+	}
+
+	// Isolate the graphics state of the existing contents from the flattened fields.
+	contents := core.MakeArray()
+	data := content.Bytes()
+	if obj := pageDict.Get("Contents"); obj != nil {
+		qStream, err := core.MakeStream([]byte("q\n"), nil)
+		if err != nil {
+			return err
+		}
+		contents.Append(qStream)
+		if arr, ok := core.GetArray(obj); ok {
+			contents.Append(arr.Elements()...)
+		} else {
+			contents.Append(obj)
+		}
+		data = append([]byte("Q\n"), data...)
+	}
+	stream, err := core.MakeStream(data, core.NewFlateEncoder())
+	if err != nil {
+		return err
+	}
+	contents.Append(stream)
+	pageDict.Set("Contents", contents)
+	return w.addObjects(contents)
+}
+
+// getWidgetNormalAppearance returns the normal appearance stream of the widget annotation
+// dictionary `annotDict`. If the normal appearance is a dictionary of appearance states, the
+// state is selected by the AS entry.
+func getWidgetNormalAppearance(annotDict *core.PdfObjectDictionary) (*core.PdfObjectStream, bool) {
+	apDict, ok := core.GetDict(annotDict.Get("AP"))
+	if !ok {
+		return nil, false
+	}
+	n := core.ResolveReference(apDict.Get("N"))
+	if stream, ok := core.GetStream(n); ok {
+		return stream, true
+	}
+	states, ok := core.GetDict(n)
+	if !ok {
+		return nil, false
+	}
+	state, ok := core.GetName(annotDict.Get("AS"))
+	if !ok {
+		return nil, false
+	}
+	return core.GetStream(states.Get(*state))
+}
+
+// widgetAppearanceMatrix returns the matrix that maps the appearance stream `xform`, once
+// transformed by its Matrix, onto the Rect of the annotation dictionary `annotDict`
+// (section 12.5.5 "Appearance Streams", PDF32000_2008).
+func widgetAppearanceMatrix(annotDict *core.PdfObjectDictionary, xform *core.PdfObjectStream) (transform.Matrix, bool) {
+	rectArr, ok := core.GetArray(annotDict.Get("Rect"))
+	if !ok {
+		return transform.Matrix{}, false
+	}
+	rect, err := NewPdfRectangle(*rectArr)
+	if err != nil {
+		return transform.Matrix{}, false
+	}
+	bboxArr, ok := core.GetArray(xform.Get("BBox"))
+	if !ok {
+		return transform.Matrix{}, false
+	}
+	bbox, err := NewPdfRectangle(*bboxArr)
+	if err != nil {
+		return transform.Matrix{}, false
+	}
+	m := []float64{1, 0, 0, 1, 0, 0}
+	if arr, ok := core.GetArray(xform.Get("Matrix")); ok {
+		if f, err := arr.GetAsFloat64Slice(); err == nil && len(f) == 6 {
+			m = f
+		}
+	}
+
+	// Bounding box of the BBox transformed by the Matrix.
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{
+		{bbox.Llx, bbox.Lly}, {bbox.Urx, bbox.Lly}, {bbox.Urx, bbox.Ury}, {bbox.Llx, bbox.Ury},
+	} {
+		x := m[0]*corner[0] + m[2]*corner[1] + m[4]
+		y := m[1]*corner[0] + m[3]*corner[1] + m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	llx, lly := math.Min(rect.Llx, rect.Urx), math.Min(rect.Lly, rect.Ury)
+	sx, sy := 1.0, 1.0
+	if maxX > minX {
+		sx = math.Abs(rect.Urx-rect.Llx) / (maxX - minX)
+	}
+	if maxY > minY {
+		sy = math.Abs(rect.Ury-rect.Lly) / (maxY - minY)
+	}
+	return transform.NewMatrix(sx, 0, 0, sy, llx-minX*sx, lly-minY*sy), true
+}
+
+// removeFields removes the fields of the field tree `obj`, i.e. an AcroForm or a field dictionary
+// and its kids, from the objects to be written.
+func (w *PdfWriter) removeFields(obj core.PdfObject, visited map[core.PdfObject]struct{}) {
+	obj = core.ResolveReference(obj)
+	if _, ok := visited[obj]; ok {
+		return
+	}
+	visited[obj] = struct{}{}
+	w.removeObject(obj)
+
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return
+	}
+	for _, key := range []core.PdfObjectName{"Fields", "Kids"} {
+		if arr, ok := core.GetArray(dict.Get(key)); ok {
+			w.removeObject(dict.Get(key))
+			for _, kid := range arr.Elements() {
+				w.removeFields(kid, visited)
+			}
+		}
+	}
+}
+
+// removeObject removes `obj` from the objects to be written.
+func (w *PdfWriter) removeObject(obj core.PdfObject) {
+	if !w.hasObject(obj) {
+		return
+	}
+	delete(w.objectsMap, obj)
+	for i, o := range w.objects {
+		if o == obj {
+			w.objects = append(w.objects[:i], w.objects[i+1:]...)
+			break
+		}
+	}
+}
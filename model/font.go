@@ -270,7 +270,7 @@ func newPdfFontFromPdfObject(fontObj core.PdfObject, allowType0 bool) (*PdfFont,
 		// In the case of not yet supported fonts, we attempt to return enough information in the
 		// font for the caller to see some font properties.
 		// TODO(peterwilliams97): Add support for these fonts and remove this special error handling.
-		if err == ErrType3FontNotSupported || err == ErrType1CFontNotSupported {
+		if err == ErrType1CFontNotSupported {
 			simplefont, err2 := newSimpleFontFromPdfObject(d, base, nil)
 			if err2 != nil {
 				common.Log.Debug("ERROR: While loading simple font: font=%s err=%v", base, err2)
@@ -467,6 +467,13 @@ func (font *PdfFont) CharcodesToUnicode(charcodes []textencoding.CharCode) []run
 	return strlist
 }
 
+// IsVertical returns true if `font` is a composite font in vertical writing mode (WMode 1), i.e.
+// its text advances downwards.
+func (font *PdfFont) IsVertical() bool {
+	t, ok := font.context.(*pdfFontType0)
+	return ok && t.vertical
+}
+
 // ToPdfObject converts the PdfFont object to its PDF representation.
 func (font *PdfFont) ToPdfObject() core.PdfObject {
 	if font.context == nil {
@@ -609,7 +616,9 @@ func (base fontCommon) asPdfObjectDictionary(subtype string) *core.PdfObjectDict
 
 	d := core.MakeDict()
 	d.Set("Type", core.MakeName("Font"))
-	d.Set("BaseFont", core.MakeName(base.basefont))
+	if base.basefont != "" || base.subtype != "Type3" {
+		d.Set("BaseFont", core.MakeName(base.basefont))
+	}
 	d.Set("Subtype", core.MakeName(base.subtype))
 
 	if base.fontDescriptor != nil {
@@ -705,13 +714,9 @@ func newFontBaseFieldsFromPdfObject(fontObj core.PdfObject) (*core.PdfObjectDict
 		font.name = name
 	}
 
-	if subtype == "Type3" {
-		common.Log.Debug("ERROR: Type 3 font not supported. d=%s", d)
-		return d, font, ErrType3FontNotSupported
-	}
-
+	// BaseFont is not used by Type 3 fonts.
 	basefont, ok := core.GetNameVal(d.Get("BaseFont"))
-	if !ok {
+	if !ok && subtype != "Type3" {
 		common.Log.Debug("ERROR: Font Incompatibility. BaseFont (Required) missing")
 		return d, font, ErrRequiredAttributeMissing
 	}
@@ -106,6 +106,15 @@ type pdfFontType0 struct {
 	Encoding       core.PdfObject
 	DescendantFont *PdfFont // Can be either CIDFontType0 or CIDFontType2 font.
 	codeToCID      *cmap.CMap
+
+	// usedCodes holds the character codes encoded with PdfFont.EncodeCompositeString.
+	usedCodes map[textencoding.CharCode]struct{}
+
+	// Vertical writing mode (WMode 1) and the vertical displacements (w1y) of the glyphs from the
+	// descendant font DW2 and W2 entries, in glyph space units.
+	vertical             bool
+	verticalWidth        map[textencoding.CharCode]float64
+	defaultVerticalWidth float64
 }
 
 // pdfFontType0FromSkeleton returns a pdfFontType0 with its common fields initalized.
@@ -135,12 +144,20 @@ func (font pdfFontType0) GetRuneMetrics(r rune) (fonts.CharMetrics, bool) {
 }
 
 // GetCharMetrics returns the char metrics for character code `code`.
+// For fonts in vertical writing mode, Wy is the vertical displacement of the glyph.
 func (font pdfFontType0) GetCharMetrics(code textencoding.CharCode) (fonts.CharMetrics, bool) {
 	if font.DescendantFont == nil {
 		common.Log.Debug("ERROR: No descendant. font=%s", font)
 		return fonts.CharMetrics{}, false
 	}
-	return font.DescendantFont.GetCharMetrics(code)
+	m, ok := font.DescendantFont.GetCharMetrics(code)
+	if ok && font.vertical {
+		m.Wy = font.defaultVerticalWidth
+		if w, has := font.verticalWidth[code]; has {
+			m.Wy = w
+		}
+	}
+	return m, ok
 }
 
 // Encoder returns the font's text encoder.
@@ -215,6 +232,7 @@ func newPdfFontType0FromPdfObject(d *core.PdfObjectDictionary, base *fontCommon)
 
 	encoderName, ok := core.GetNameVal(d.Get("Encoding"))
 	if ok {
+		font.vertical = encoderName == "Identity-V"
 		if encoderName == "Identity-H" || encoderName == "Identity-V" {
 			font.encoder = textencoding.NewIdentityTextEncoder(encoderName)
 		} else if cmap.IsPredefinedCMap(encoderName) {
@@ -225,6 +243,18 @@ func newPdfFontType0FromPdfObject(d *core.PdfObjectDictionary, base *fontCommon)
 		} else {
 			common.Log.Debug("Unhandled cmap %q", encoderName)
 		}
+		if font.codeToCID != nil && font.codeToCID.WMode() == 1 {
+			font.vertical = true
+		}
+	} else if stream, ok := core.GetStream(d.Get("Encoding")); ok {
+		// Embedded CMap stream.
+		if wmode, ok := core.GetIntVal(stream.Get("WMode")); ok && wmode == 1 {
+			font.vertical = true
+		}
+	}
+	if font.vertical {
+		dfDict, _ := core.GetDict(arr.Get(0))
+		font.loadVerticalMetrics(dfDict)
 	}
 
 	if cidToUnicode := df.baseFields().toUnicodeCmap; cidToUnicode != nil {
@@ -237,6 +267,63 @@ func newPdfFontType0FromPdfObject(d *core.PdfObjectDictionary, base *fontCommon)
 	return font, nil
 }
 
+// loadVerticalMetrics loads the vertical displacements of the glyphs from the DW2 and W2 entries
+// of the descendant CIDFont dictionary `d`. The default displacement is -1000.
+// See section 9.7.4.3 "Glyph Metrics in CIDFonts" (p. 271 PDF32000_2008).
+func (font *pdfFontType0) loadVerticalMetrics(d *core.PdfObjectDictionary) {
+	font.defaultVerticalWidth = -1000
+	font.verticalWidth = map[textencoding.CharCode]float64{}
+	if d == nil {
+		return
+	}
+	if dw2, ok := core.GetArray(d.Get("DW2")); ok && dw2.Len() == 2 {
+		if w1y, err := core.GetNumberAsFloat(dw2.Get(1)); err == nil {
+			font.defaultVerticalWidth = w1y
+		}
+	}
+
+	w2, ok := core.GetArray(d.Get("W2"))
+	if !ok {
+		return
+	}
+	// W2 consists of entries of the form `c [w1y_1 vx_1 vy_1 w1y_2 vx_2 vy_2 ...]` or
+	// `cfirst clast w1y vx vy`.
+	objs := w2.Elements()
+	for i := 0; i < len(objs); {
+		first, ok := core.GetIntVal(objs[i])
+		if !ok || i+1 >= len(objs) {
+			common.Log.Debug("ERROR: Invalid W2 entry: %v", w2)
+			return
+		}
+		if arr, ok := core.GetArray(objs[i+1]); ok {
+			vals, err := arr.ToFloat64Array()
+			if err != nil {
+				common.Log.Debug("ERROR: Invalid W2 entry: %v", w2)
+				return
+			}
+			for j := 0; j+2 < len(vals); j += 3 {
+				font.verticalWidth[textencoding.CharCode(first+j/3)] = vals[j]
+			}
+			i += 2
+			continue
+		}
+		if i+4 >= len(objs) {
+			common.Log.Debug("ERROR: Invalid W2 entry: %v", w2)
+			return
+		}
+		last, ok := core.GetIntVal(objs[i+1])
+		w1y, err := core.GetNumberAsFloat(objs[i+2])
+		if !ok || err != nil {
+			common.Log.Debug("ERROR: Invalid W2 entry: %v", w2)
+			return
+		}
+		for c := first; c <= last; c++ {
+			font.verticalWidth[textencoding.CharCode(c)] = w1y
+		}
+		i += 5
+	}
+}
+
 // pdfCIDFontType0 implements pdfFont
 var _ pdfFont = (*pdfCIDFontType0)(nil)
 
@@ -634,6 +721,74 @@ func NewCompositePdfFontFromTTFFile(filePath string) (*PdfFont, error) {
 	return &font, nil
 }
 
+// EncodeCompositeString encodes the UTF-8 string `text` with the Type0 (composite) font `font`,
+// returning a string that can be used as an operand of the Tj and TJ operators. With the
+// Identity-H encoding used by NewCompositePdfFontFromTTFFile, each character is represented by
+// its two byte glyph index. As the Identity-H encoding of fonts loaded from a document does not
+// map characters to glyphs, their character codes are looked up in the ToUnicode CMap, and an
+// error is returned for such fonts without one. The character codes are recorded as used, see
+// UsedCharcodes.
+// An error wrapping ErrGlyphNotFound is returned if the font has no glyph for a character of `text`.
+func (font *PdfFont) EncodeCompositeString(text string) (*core.PdfObjectString, error) {
+	type0, ok := font.context.(*pdfFontType0)
+	if !ok {
+		return nil, fmt.Errorf("font %s is not a Type0 font", font.BaseFont())
+	}
+	if type0.encoder == nil {
+		return nil, fmt.Errorf("font %s has no encoder", font.BaseFont())
+	}
+	runeToCharcode := type0.encoder.RuneToCharcode
+	if _, ok := type0.encoder.(textencoding.IdentityEncoder); ok {
+		// The identity encoding maps characters to their code points rather than to glyphs.
+		if type0.toUnicodeCmap == nil {
+			return nil, fmt.Errorf("font %s cannot map characters to glyphs without a ToUnicode CMap",
+				font.BaseFont())
+		}
+		runeToCharcode = func(r rune) (textencoding.CharCode, bool) {
+			code, ok := type0.toUnicodeCmap.RuneToCID(r)
+			return textencoding.CharCode(code), ok
+		}
+	}
+
+	codes := make([]textencoding.CharCode, 0, len(text))
+	for _, r := range text {
+		code, ok := runeToCharcode(r)
+		if !ok || code > 0xffff {
+			return nil, fmt.Errorf("%w: rune %+q in font %s", ErrGlyphNotFound, r, font.BaseFont())
+		}
+		codes = append(codes, code)
+	}
+
+	if type0.usedCodes == nil {
+		type0.usedCodes = make(map[textencoding.CharCode]struct{})
+	}
+	encoded := make([]byte, 0, 2*len(codes))
+	for _, code := range codes {
+		type0.usedCodes[code] = struct{}{}
+		encoded = append(encoded, byte(code>>8), byte(code))
+	}
+	return core.MakeStringFromBytes(encoded), nil
+}
+
+// UsedCharcodes returns the sorted character codes encoded with EncodeCompositeString, e.g. for
+// determining which glyphs need to be kept when subsetting the font. With the Identity-H encoding
+// and identity CIDToGIDMap of fonts created by NewCompositePdfFontFromTTFFile, the character
+// codes are the glyph indices.
+func (font *PdfFont) UsedCharcodes() []textencoding.CharCode {
+	type0, ok := font.context.(*pdfFontType0)
+	if !ok {
+		return nil
+	}
+	codes := make([]textencoding.CharCode, 0, len(type0.usedCodes))
+	for code := range type0.usedCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i] < codes[j]
+	})
+	return codes
+}
+
 func makeCIDWidthArr(runes []rune, widths map[rune]int, gids map[rune]fonts.GID) *core.PdfObjectArray {
 	// Construct W array. Stores character code to width mappings.
 	arr := &core.PdfObjectArray{}
@@ -1,10 +1,15 @@
 package model
 
 import (
+	"bytes"
+	"errors"
 	"sort"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/internal/textencoding"
 	"github.com/unidoc/unipdf/v3/model/internal/fonts"
 )
 
@@ -59,3 +64,91 @@ func TestCIDWidthArr(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeCompositeString(t *testing.T) {
+	font, err := NewCompositePdfFontFromTTFFile("../creator/testdata/wts11.ttf")
+	require.NoError(t, err)
+
+	str, err := font.EncodeCompositeString("日本語")
+	require.NoError(t, err)
+	require.Len(t, str.Bytes(), 6)
+
+	text, _, numMisses := font.CharcodeBytesToUnicode(str.Bytes())
+	require.Equal(t, "日本語", text)
+	require.Zero(t, numMisses)
+
+	_, err = font.EncodeCompositeString("本本")
+	require.NoError(t, err)
+	require.ElementsMatch(t, font.BytesToCharcodes(str.Bytes()), font.UsedCharcodes())
+
+	// No CJK glyphs in OpenSans.
+	font, err = NewCompositePdfFontFromTTFFile("testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+	_, err = font.EncodeCompositeString("A日")
+	require.True(t, errors.Is(err, ErrGlyphNotFound), err)
+	require.Empty(t, font.UsedCharcodes())
+
+	// Simple fonts are not supported.
+	_, err = NewStandard14FontMustCompile(HelveticaName).EncodeCompositeString("A")
+	require.Error(t, err)
+}
+
+// TestEncodeCompositeStringLoaded checks that a Type0 font loaded from a document with the
+// Identity-H encoding maps characters to glyphs through its ToUnicode CMap.
+func TestEncodeCompositeStringLoaded(t *testing.T) {
+	font, err := NewCompositePdfFontFromTTFFile("../creator/testdata/wts11.ttf")
+	require.NoError(t, err)
+	expected, err := font.EncodeCompositeString("日本語")
+	require.NoError(t, err)
+
+	page := NewPdfPage()
+	require.NoError(t, page.Resources.SetFontByName("F1", font.ToPdfObject()))
+	content := "BT /F1 12 Tf 100 700 Td " + expected.WriteString() + " Tj ET"
+	require.NoError(t, page.SetContentStreams([]string{content}, nil))
+
+	writer := NewPdfWriter()
+	require.NoError(t, writer.AddPage(page))
+	var buf bytes.Buffer
+	require.NoError(t, writer.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	obj, ok := page.Resources.GetFontByName("F1")
+	require.True(t, ok)
+	loaded, err := NewPdfFontFromPdfObject(obj)
+	require.NoError(t, err)
+
+	str, err := loaded.EncodeCompositeString("日本語")
+	require.NoError(t, err)
+	require.Equal(t, expected.Bytes(), str.Bytes())
+	text, _, numMisses := loaded.CharcodeBytesToUnicode(str.Bytes())
+	require.Equal(t, "日本語", text)
+	require.Zero(t, numMisses)
+
+	// U+E000 is in the private use area, which the font does not cover.
+	_, err = loaded.EncodeCompositeString("\ue000")
+	require.True(t, errors.Is(err, ErrGlyphNotFound), err)
+}
+
+func TestType0VerticalMetrics(t *testing.T) {
+	descendant := core.MakeDict()
+	descendant.Set("DW2", core.MakeArrayFromIntegers([]int{880, -900}))
+	descendant.Set("W2", core.MakeArray(
+		core.MakeInteger(5), core.MakeArrayFromIntegers([]int{-500, 500, 880, -600, 500, 880}),
+		core.MakeInteger(10), core.MakeInteger(12), core.MakeInteger(-700), core.MakeInteger(500), core.MakeInteger(880),
+	))
+
+	font := &pdfFontType0{vertical: true, DescendantFont: DefaultFont()}
+	font.loadVerticalMetrics(descendant)
+	for code, expected := range map[textencoding.CharCode]float64{
+		4: -900, 5: -500, 6: -600, 7: -900, 10: -700, 12: -700, 13: -900,
+	} {
+		m, ok := font.GetCharMetrics(code)
+		require.True(t, ok)
+		require.Equal(t, expected, m.Wy, "code %d", code)
+	}
+	require.True(t, (&PdfFont{context: font}).IsVertical())
+	require.False(t, DefaultFont().IsVertical())
+}
@@ -61,6 +61,12 @@ type pdfFontSimple struct {
 	Widths    core.PdfObject
 	Encoding  core.PdfObject
 
+	// These fields are specific to Type 3 fonts (9.6.5, "Type 3 Fonts").
+	FontBBox   core.PdfObject
+	FontMatrix core.PdfObject
+	CharProcs  core.PdfObject
+	Resources  core.PdfObject
+
 	// Standard 14 fonts metrics
 	fontMetrics map[rune]fonts.CharMetrics
 }
@@ -221,9 +227,40 @@ func newSimpleFontFromPdfObject(d *core.PdfObjectDictionary, base *fontCommon,
 	}
 
 	font.Encoding = core.TraceToDirectObject(d.Get("Encoding"))
+
+	if base.subtype == "Type3" {
+		if err := font.loadType3Fields(d); err != nil {
+			return nil, err
+		}
+	}
 	return font, nil
 }
 
+// loadType3Fields loads the Type 3 specific entries of font dictionary `d`.
+// The glyph widths of Type 3 fonts are expressed in the glyph space defined by FontMatrix, so they
+// are scaled to the 1/1000 text space units used for the widths of other fonts.
+func (font *pdfFontSimple) loadType3Fields(d *core.PdfObjectDictionary) error {
+	font.FontBBox = d.Get("FontBBox")
+	font.FontMatrix = d.Get("FontMatrix")
+	font.CharProcs = d.Get("CharProcs")
+	font.Resources = d.Get("Resources")
+
+	arr, ok := core.GetArray(font.FontMatrix)
+	if !ok || arr.Len() != 6 {
+		common.Log.Debug("ERROR: Type3 font has invalid FontMatrix (%T)", font.FontMatrix)
+		return ErrRequiredAttributeMissing
+	}
+	matrix, err := arr.ToFloat64Array()
+	if err != nil {
+		common.Log.Debug("ERROR: Type3 FontMatrix: %v", err)
+		return err
+	}
+	for code, w := range font.charWidths {
+		font.charWidths[code] = w * matrix[0] * 1000
+	}
+	return nil
+}
+
 // addEncoding adds the encoding to the font and sets the `font.encoder` field.
 // The order of precedence is important:
 // 1. If encoder already set, load it initially (with subsequent steps potentially overwriting).
@@ -378,6 +415,10 @@ func (font *pdfFontSimple) ToPdfObject() core.PdfObject {
 			d.Set("Encoding", encObj)
 		}
 	}
+	d.SetIfNotNil("FontBBox", font.FontBBox)
+	d.SetIfNotNil("FontMatrix", font.FontMatrix)
+	d.SetIfNotNil("CharProcs", font.CharProcs)
+	d.SetIfNotNil("Resources", font.Resources)
 
 	return font.container
 }
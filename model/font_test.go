@@ -865,6 +865,48 @@ func newStandandTextEncoder(t *testing.T) textencoding.SimpleEncoder {
 	return enc
 }
 
+// TestLoadType3Font tests loading a Type3 font. Its widths are given in glyph space and must be
+// scaled by the FontMatrix.
+func TestLoadType3Font(t *testing.T) {
+	rawpdf := `
+10 0 obj
+<</Type /Font/Subtype /Type3/FontBBox [0 0 100 100]/FontMatrix [0.01 0 0 0.01 0 0]/CharProcs 11 0 R/Encoding 12 0 R/FirstChar 1/LastChar 2/Widths [50 60]>>
+endobj
+11 0 obj
+<</H 13 0 R/i 13 0 R>>
+endobj
+12 0 obj
+<</Type /Encoding/Differences [1 /H /i]>>
+endobj
+13 0 obj
+<</Length 33>>
+stream
+50 0 0 0 50 100 d1 0 0 50 100 re f
+endstream
+endobj
+`
+	objects, err := testutils.ParseIndirectObjects(rawpdf)
+	require.NoError(t, err)
+
+	font, err := model.NewPdfFontFromPdfObject(objects[10])
+	require.NoError(t, err)
+	require.Equal(t, "Type3", font.Subtype())
+
+	metrics, ok := font.GetCharMetrics(1)
+	require.True(t, ok)
+	require.Equal(t, 500.0, metrics.Wx)
+	metrics, ok = font.GetCharMetrics(2)
+	require.True(t, ok)
+	require.Equal(t, 600.0, metrics.Wx)
+
+	require.Equal(t, "Hi", string(font.CharcodesToUnicode([]textencoding.CharCode{1, 2})))
+
+	// The Type3 entries are written back and no BaseFont is added.
+	obj1 := core.FlattenObject(objects[10])
+	obj2 := core.FlattenObject(font.ToPdfObject())
+	require.True(t, core.EqualObjects(obj1, obj2), "obj1=%s\nobj2=%s", obj1, obj2)
+}
+
 func TestNewFontFromFile(t *testing.T) {
 	_, err := model.NewPdfFontFromTTFFile("testdata/font/OpenSans-Regular.ttf")
 	if err != nil {
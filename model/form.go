@@ -6,6 +6,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/unidoc/unipdf/v3/common"
@@ -20,6 +21,10 @@ Ch = choice
 Sig = signature
 */
 
+// defaultFormFontName is the name of the standard Helvetica font in the default resources of
+// forms, as used by common viewers.
+const defaultFormFontName = core.PdfObjectName("Helv")
+
 // PdfAcroForm represents the AcroForm dictionary used for representation of form data in PDF.
 type PdfAcroForm struct {
 	Fields          *[]*PdfField
@@ -34,6 +39,21 @@ type PdfAcroForm struct {
 	container *core.PdfIndirectObject
 }
 
+// SigFlag represents the signature flags of an AcroForm (SigFlags entry). They are document
+// level characteristics related to signature fields.
+type SigFlag uint32
+
+// The following constants define the bitwise signature flags.
+const (
+	// SigFlagSignaturesExist indicates that the document contains at least one signature field.
+	SigFlagSignaturesExist SigFlag = 1
+
+	// SigFlagAppendOnly indicates that the document contains signatures that may be invalidated
+	// if the file is saved in a way that alters its previous contents, as opposed to an
+	// incremental update.
+	SigFlagAppendOnly SigFlag = (1 << 1)
+)
+
 // NewPdfAcroForm returns a new PdfAcroForm with an intialized container (indirect object).
 func NewPdfAcroForm() *PdfAcroForm {
 	return &PdfAcroForm{
@@ -65,6 +85,76 @@ func (form *PdfAcroForm) AllFields() []*PdfField {
 	return fields
 }
 
+// PdfFieldInfo describes a terminal form field with its current value, type and flags,
+// accounting for values inherited from ancestor fields.
+type PdfFieldInfo struct {
+	// Name is the fully qualified name of the field.
+	Name string
+	// Type is the field type: Tx, Btn, Ch or Sig. Empty if not specified.
+	Type string
+	// Value is the current value of the field (V). Nil if not set.
+	Value core.PdfObject
+	// Flags are the field flags (Ff).
+	Flags FieldFlag
+	// Field is the underlying field, which can be modified and written back via the writer.
+	Field *PdfField
+}
+
+// IsReadOnly returns true if the field is read-only.
+func (info *PdfFieldInfo) IsReadOnly() bool {
+	return info.Flags.Has(FieldFlagReadOnly)
+}
+
+// IsRequired returns true if the field is required.
+func (info *PdfFieldInfo) IsRequired() bool {
+	return info.Flags.Has(FieldFlagRequired)
+}
+
+// FieldInfos returns information about all the terminal fields of the form, i.e. the fields
+// which carry values, in the order they appear in the field hierarchy.
+func (form *PdfAcroForm) FieldInfos() ([]*PdfFieldInfo, error) {
+	var infos []*PdfFieldInfo
+	for _, f := range form.AllFields() {
+		if !f.IsTerminal() {
+			continue
+		}
+
+		name, err := f.FullName()
+		if err != nil {
+			return nil, err
+		}
+		info := &PdfFieldInfo{
+			Name:  name,
+			Flags: f.Flags(),
+			Field: f,
+		}
+
+		_, err = f.inherit(func(node *PdfField) bool {
+			if node.FT != nil {
+				info.Type = node.FT.String()
+				return true
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.inherit(func(node *PdfField) bool {
+			if node.V != nil {
+				info.Value = node.V
+				return true
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 // signatureFields returns a slice of all signature fields in the form.
 func (form *PdfAcroForm) signatureFields() []*PdfFieldSignature {
 	var sigfields []*PdfFieldSignature
@@ -181,6 +271,52 @@ func (r *PdfReader) newPdfAcroFormFromDict(container *core.PdfIndirectObject, d
 	return acroForm, nil
 }
 
+// SetDefaultAppearance sets the default appearance (DA) of the variable text fields of the form
+// to use `font` at `size` points (0 for auto sizing) in black. The font is registered as `name`
+// in the default resources (DR) of the form.
+func (form *PdfAcroForm) SetDefaultAppearance(name core.PdfObjectName, font *PdfFont, size float64) error {
+	if font == nil {
+		return errors.New("font not set")
+	}
+	if form.DR == nil {
+		form.DR = NewPdfPageResources()
+	}
+	if err := form.DR.SetFontByName(name, font.ToPdfObject()); err != nil {
+		return err
+	}
+	form.DA = core.MakeString(fmt.Sprintf("/%s %v Tf 0 g", name, size))
+	return nil
+}
+
+// setDefaultAppearanceResources sets default resources containing the standard Helvetica font
+// and, if the form has none, a default appearance using it in the AcroForm dictionary `dict`
+// written for the form, so that viewers are able to display the text of filled fields.
+// The form and its resources are not modified.
+func (form *PdfAcroForm) setDefaultAppearanceResources(dict *core.PdfObjectDictionary) error {
+	resources := core.MakeDict()
+	fonts := core.MakeDict()
+	if form.DR != nil {
+		dr, ok := core.GetDict(form.DR.ToPdfObject())
+		if !ok {
+			return core.ErrTypeError
+		}
+		resources.Merge(dr)
+		if drFonts, ok := core.GetDict(dr.Get("Font")); ok {
+			fonts.Merge(drFonts)
+		}
+	}
+	if fonts.Get(defaultFormFontName) == nil {
+		fonts.Set(defaultFormFontName, DefaultFont().ToPdfObject())
+	}
+	resources.Set("Font", fonts)
+	dict.Set("DR", resources)
+
+	if form.DA == nil {
+		dict.Set("DA", core.MakeString(fmt.Sprintf("/%s 0 Tf 0 g", defaultFormFontName)))
+	}
+	return nil
+}
+
 // GetContainingPdfObject returns the container of the PdfAcroForm (indirect object).
 func (form *PdfAcroForm) GetContainingPdfObject() core.PdfObject {
 	return form.container
@@ -6,6 +6,7 @@
 package model
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -160,3 +161,84 @@ endobj
 	_ = raw
 	t.Skip("Not implemented yet")
 }
+
+// Test reading the field values, types and flags of a form.
+func TestAcroFormFieldInfos(t *testing.T) {
+	f, err := os.Open("testdata/OoPdfFormExample.pdf")
+	require.NoError(t, err)
+	defer f.Close()
+
+	reader, err := NewPdfReader(f)
+	require.NoError(t, err)
+
+	infos, err := reader.AcroForm.FieldInfos()
+	require.NoError(t, err)
+	require.Len(t, infos, 17)
+
+	byName := map[string]*PdfFieldInfo{}
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+	require.Equal(t, "Tx", byName["Given Name Text Box"].Type)
+	require.Equal(t, "Ch", byName["Country Combo Box"].Type)
+	require.True(t, byName["Country Combo Box"].Flags.Has(FieldFlagEdit))
+	require.Equal(t, "Btn", byName["Language 2 Check Box"].Type)
+	require.Equal(t, core.MakeName("Yes"), byName["Language 2 Check Box"].Value)
+
+	// Type, value and flags are inherited from the parent field.
+	parent := NewPdfField()
+	parent.T = core.MakeString("person")
+	parent.FT = core.MakeName("Tx")
+	parent.Ff = core.MakeInteger(int64(FieldFlagReadOnly | FieldFlagRequired))
+	parent.V = core.MakeString("John")
+	kid := NewPdfField()
+	kid.T = core.MakeString("name")
+	kid.Parent = parent
+	parent.Kids = []*PdfField{kid}
+
+	form := NewPdfAcroForm()
+	*form.Fields = append(*form.Fields, parent)
+	infos, err = form.FieldInfos()
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, "person.name", infos[0].Name)
+	require.Equal(t, "Tx", infos[0].Type)
+	require.Equal(t, "John", infos[0].Value.(*core.PdfObjectString).Str())
+	require.True(t, infos[0].IsReadOnly())
+	require.True(t, infos[0].IsRequired())
+	require.Equal(t, kid, infos[0].Field)
+}
+
+func TestAcroFormDefaultAppearance(t *testing.T) {
+	form := NewPdfAcroForm()
+	require.Error(t, form.SetDefaultAppearance("F1", nil, 12))
+	require.NoError(t, form.SetDefaultAppearance("Cour", NewStandard14FontMustCompile(CourierName), 10.5))
+	require.Equal(t, "/Cour 10.5 Tf 0 g", form.DA.Str())
+	require.True(t, form.DR.HasFontByName("Cour"))
+
+	// The standard Helvetica font is added to the written resources, keeping the set appearance.
+	dict, ok := core.GetDict(form.ToPdfObject())
+	require.True(t, ok)
+	require.NoError(t, form.setDefaultAppearanceResources(dict))
+	require.Equal(t, "/Cour 10.5 Tf 0 g", dict.Get("DA").(*core.PdfObjectString).Str())
+	resources, err := NewPdfPageResourcesFromDict(dict.Get("DR").(*core.PdfObjectDictionary))
+	require.NoError(t, err)
+	require.True(t, resources.HasFontByName("Cour"))
+	require.True(t, resources.HasFontByName("Helv"))
+	require.False(t, form.DR.HasFontByName("Helv"))
+
+	form = NewPdfAcroForm()
+	dict, ok = core.GetDict(form.ToPdfObject())
+	require.True(t, ok)
+	require.NoError(t, form.setDefaultAppearanceResources(dict))
+	require.Nil(t, form.DA)
+	require.Nil(t, form.DR)
+	require.Equal(t, "/Helv 0 Tf 0 g", dict.Get("DA").(*core.PdfObjectString).Str())
+	resources, err = NewPdfPageResourcesFromDict(dict.Get("DR").(*core.PdfObjectDictionary))
+	require.NoError(t, err)
+	fontObj, ok := resources.GetFontByName("Helv")
+	require.True(t, ok)
+	font, err := NewPdfFontFromPdfObject(fontObj)
+	require.NoError(t, err)
+	require.Equal(t, "Helvetica", font.BaseFont())
+}
@@ -12,6 +12,7 @@ import (
 	gocolor "image/color"
 	"image/draw"
 	"io"
+	"math"
 
 	// Imported for initialization side effects.
 	_ "image/gif"
@@ -32,7 +33,8 @@ type Image struct {
 	Data             []byte // Image data stored as bytes.
 
 	// Transparency data: alpha channel.
-	// Stored in same bits per component as original data with 1 color component.
+	// Stored as 8 bits per pixel regardless of BitsPerComponent, so that it is not affected by
+	// conversions between bit depths.
 	alphaData []byte // Alpha channel data.
 	hasAlpha  bool   // Indicates whether the alpha channel data is available.
 
@@ -101,13 +103,15 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 
 			// Calculate index of byte containing the gray value
 			// in the image data, based on the specified x,y coordinates.
-			idx := (y*int(img.Width) + x) / divider
+			// Each row starts at a byte boundary.
+			rowBytes := (int(img.Width) + divider - 1) / divider
+			idx := y*rowBytes + x/divider
 			if idx >= lenData {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
 			// Calculate bit position at which the color data starts.
-			pos := 8 - uint(((y*int(img.Width)+x)%divider)*bpc+bpc)
+			pos := 8 - uint((x%divider)*bpc+bpc)
 
 			// Extract gray color value starting at the calculated position.
 			val := float64(((1 << uint(img.BitsPerComponent)) - 1) & (data[idx] >> pos))
@@ -149,14 +153,15 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 		// RGB image.
 		switch img.BitsPerComponent {
 		case 4:
-			// 4 bit per component RGB image.
-			idx := (y*int(img.Width) + x) * 3 / 2
+			// 4 bit per component RGB image. Each row starts at a byte boundary.
+			rowBytes := (int(img.Width)*3 + 1) / 2
+			idx := y*rowBytes + x*3/2
 			if idx+1 >= lenData {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
 			// Calculate bit position at which the color data starts.
-			pos := (y*int(img.Width) + x) * 3 % 2
+			pos := x * 3 % 2
 
 			var r, g, b uint8
 			if pos == 0 {
@@ -188,10 +193,7 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
-			a := uint16(0xffff)
-			if img.alphaData != nil && len(img.alphaData) > idx+1 {
-				a = uint16(img.alphaData[idx])<<8 | uint16(img.alphaData[idx+1])
-			}
+			a := uint16(img.alphaAt(x, y)) * 0x101
 
 			return gocolor.RGBA64{
 				R: uint16(data[i])<<8 | uint16(data[i+1]),
@@ -208,16 +210,11 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 				return nil, fmt.Errorf("image coordinates out of range (%d, %d)", x, y)
 			}
 
-			a := uint8(0xff)
-			if img.alphaData != nil && len(img.alphaData) > idx {
-				a = uint8(img.alphaData[idx])
-			}
-
 			return gocolor.RGBA{
 				R: uint8(data[i] & 0xff),
 				G: uint8(data[i+1] & 0xff),
 				B: uint8(data[i+2] & 0xff),
-				A: a,
+				A: img.alphaAt(x, y),
 			}, nil
 		}
 	case 4:
@@ -239,6 +236,110 @@ func (img *Image) ColorAt(x, y int) (gocolor.Color, error) {
 	return nil, errors.New("unsupported image colorspace")
 }
 
+// alphaAt returns the alpha value of the pixel specified by the x and y coordinates.
+// Returns 0xff (opaque) if the image has no alpha channel.
+func (img *Image) alphaAt(x, y int) uint8 {
+	idx := y*int(img.Width) + x
+	if !img.hasAlpha || idx >= len(img.alphaData) {
+		return 0xff
+	}
+	return img.alphaData[idx]
+}
+
+// applyColorKeyMask sets the alpha channel of the image from a color key mask, as specified
+// by the Mask entry of an image XObject. `ranges` contains a [min max] pair of sample values
+// for each color component. Pixels with all components within the ranges are made fully
+// transparent.
+func (img *Image) applyColorKeyMask(ranges []float64) error {
+	if len(ranges) != 2*img.ColorComponents {
+		return fmt.Errorf("invalid color key mask length %d for %d components", len(ranges), img.ColorComponents)
+	}
+
+	width, height := int(img.Width), int(img.Height)
+	rowBytes := (width*img.ColorComponents*int(img.BitsPerComponent) + 7) / 8
+	if len(img.Data) < rowBytes*height {
+		return fmt.Errorf("not enough image data for color key mask (%d < %d)", len(img.Data), rowBytes*height)
+	}
+
+	alphaData := make([]byte, width*height)
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		samples := img.rowSamples(y)
+		for x := 0; x < width; x++ {
+			masked := true
+			for c := 0; c < img.ColorComponents; c++ {
+				val := float64(samples[x*img.ColorComponents+c])
+				if val < ranges[2*c] || val > ranges[2*c+1] {
+					masked = false
+					break
+				}
+			}
+
+			if masked {
+				hasAlpha = true
+			} else {
+				alphaData[y*width+x] = 0xff
+			}
+		}
+	}
+
+	img.hasAlpha = hasAlpha
+	if hasAlpha {
+		img.alphaData = alphaData
+	}
+	return nil
+}
+
+// applySoftMask sets the alpha channel of the image from the grayscale soft mask image `mask`
+// (SMask). The mask is scaled to the dimensions of the image if they differ.
+func (img *Image) applySoftMask(mask *Image) error {
+	if mask.ColorComponents != 1 {
+		return fmt.Errorf("invalid soft mask: %d color components", mask.ColorComponents)
+	}
+	if mask.Width <= 0 || mask.Height <= 0 {
+		return fmt.Errorf("invalid soft mask dimensions %dx%d", mask.Width, mask.Height)
+	}
+	rowBytes := (int(mask.Width)*int(mask.BitsPerComponent) + 7) / 8
+	if len(mask.Data) < rowBytes*int(mask.Height) {
+		return fmt.Errorf("not enough soft mask data (%d < %d)", len(mask.Data), rowBytes*int(mask.Height))
+	}
+
+	width, height := int(img.Width), int(img.Height)
+	maxVal := float64(uint32(1)<<uint32(mask.BitsPerComponent) - 1)
+	dMin, dMax := 0.0, 1.0
+	if len(mask.decode) == 2 {
+		dMin, dMax = mask.decode[0], mask.decode[1]
+	}
+
+	alphaData := make([]byte, width*height)
+	var samples []uint32
+	maskY := -1
+	for y := 0; y < height; y++ {
+		// Nearest neighbour scaling of the mask.
+		if my := y * int(mask.Height) / height; my != maskY {
+			maskY = my
+			samples = mask.rowSamples(maskY)
+		}
+		for x := 0; x < width; x++ {
+			mx := x * int(mask.Width) / width
+			alpha := interpolate(float64(samples[mx]), 0, maxVal, dMin, dMax)
+			alpha = math.Max(0, math.Min(1, alpha))
+			alphaData[y*width+x] = uint8(math.Round(alpha * 255))
+		}
+	}
+
+	img.alphaData = alphaData
+	img.hasAlpha = true
+	return nil
+}
+
+// rowSamples returns the samples of row `y` of the image. Each row starts at a byte boundary.
+func (img *Image) rowSamples(y int) []uint32 {
+	rowBytes := (int(img.Width)*img.ColorComponents*int(img.BitsPerComponent) + 7) / 8
+	samples := sampling.ResampleBytes(img.Data[y*rowBytes:(y+1)*rowBytes], int(img.BitsPerComponent))
+	return samples[:int(img.Width)*img.ColorComponents]
+}
+
 // Resample resamples the image data converting from current BitsPerComponent to a target BitsPerComponent
 // value.  Sets the image's BitsPerComponent to the target value following resampling.
 //
@@ -295,6 +396,8 @@ func (img *Image) Resample(targetBitsPerComponent int64) {
 }
 
 // ToGoImage converts the unidoc Image to a golang Image structure.
+// Images with an alpha channel are converted to *image.NRGBA (or *image.NRGBA64 for
+// 16 bits per component), as PDF image samples are not premultiplied by alpha.
 func (img *Image) ToGoImage() (goimage.Image, error) {
 	common.Log.Trace("Converting to go image")
 	bounds := goimage.Rect(0, 0, int(img.Width), int(img.Height))
@@ -321,6 +424,14 @@ func (img *Image) ToGoImage() (goimage.Image, error) {
 		return nil, errors.New("unsupported colors")
 	}
 
+	if img.hasAlpha {
+		if img.BitsPerComponent == 16 {
+			imgout = goimage.NewNRGBA64(bounds)
+		} else {
+			imgout = goimage.NewNRGBA(bounds)
+		}
+	}
+
 	for y := 0; y < int(img.Height); y++ {
 		for x := 0; x < int(img.Width); x++ {
 			color, err := img.ColorAt(x, y)
@@ -329,6 +440,9 @@ func (img *Image) ToGoImage() (goimage.Image, error) {
 					err, img.ColorComponents, img.BitsPerComponent, img.Width, img.Height, len(img.Data))
 				continue
 			}
+			if img.hasAlpha {
+				color = nrgbaWithAlpha(color, img.alphaAt(x, y))
+			}
 
 			imgout.Set(x, y, color)
 		}
@@ -337,6 +451,24 @@ func (img *Image) ToGoImage() (goimage.Image, error) {
 	return imgout, nil
 }
 
+// nrgbaWithAlpha returns the non-premultiplied color with the components of `color` and
+// the specified `alpha`. The alpha of `color` itself is ignored.
+func nrgbaWithAlpha(color gocolor.Color, alpha uint8) gocolor.Color {
+	switch c := color.(type) {
+	case gocolor.Gray:
+		return gocolor.NRGBA{R: c.Y, G: c.Y, B: c.Y, A: alpha}
+	case gocolor.Gray16:
+		return gocolor.NRGBA64{R: c.Y, G: c.Y, B: c.Y, A: uint16(alpha) * 0x101}
+	case gocolor.RGBA:
+		return gocolor.NRGBA{R: c.R, G: c.G, B: c.B, A: alpha}
+	case gocolor.RGBA64:
+		return gocolor.NRGBA64{R: c.R, G: c.G, B: c.B, A: uint16(alpha) * 0x101}
+	}
+
+	r, g, b, _ := color.RGBA()
+	return gocolor.NRGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(alpha) * 0x101}
+}
+
 // ImageHandler interface implements common image loading and processing tasks.
 // Implementing as an interface allows for the possibility to use non-standard libraries for faster
 // loading and processing of images.
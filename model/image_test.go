@@ -12,6 +12,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/core"
 )
 
 func TestImageResampling(t *testing.T) {
@@ -99,6 +101,22 @@ func TestImageColorAt(t *testing.T) {
 		t.Errorf("Expected 0. Got %d.", y) // b'0' translated in 0-255 range.
 	}
 
+	// 1 bit grayscale with padded rows.
+	img.Width = 10
+	img.Height = 3
+
+	c, err = img.ColorAt(9, 0)
+	require.NoError(t, err)
+	if y := c.(color.Gray).Y; y != 0 {
+		t.Errorf("Expected 0. Got %d.", y) // 2nd bit of byte 144.
+	}
+
+	c, err = img.ColorAt(3, 2)
+	require.NoError(t, err)
+	if y := c.(color.Gray).Y; y != 255 {
+		t.Errorf("Expected 255. Got %d.", y) // 4th bit of byte 158 (row 2 starts at byte 4).
+	}
+
 	// 2 bit grayscale.
 	img.Width = 4
 	img.Height = 6
@@ -305,6 +323,103 @@ func TestImageColorAt(t *testing.T) {
 	}
 }
 
+func TestImageColorKeyMask(t *testing.T) {
+	// 3x1 RGB image: red, green, near-red.
+	img := &Image{
+		Width:            3,
+		Height:           1,
+		BitsPerComponent: 8,
+		ColorComponents:  3,
+		Data:             []byte{255, 0, 0, 0, 255, 0, 250, 5, 5},
+	}
+	ximg, err := NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	ximg.Mask = core.MakeArrayFromIntegers([]int{250, 255, 0, 10, 0, 10})
+	ximg.ToPdfObject()
+
+	maskedImg, err := ximg.ToImage()
+	require.NoError(t, err)
+	goimg, err := maskedImg.ToGoImage()
+	require.NoError(t, err)
+	require.IsType(t, &image.NRGBA{}, goimg)
+
+	require.Equal(t, color.NRGBA{R: 255, A: 0}, goimg.At(0, 0))
+	require.Equal(t, color.NRGBA{G: 255, A: 255}, goimg.At(1, 0))
+	require.Equal(t, color.NRGBA{R: 250, G: 5, B: 5, A: 0}, goimg.At(2, 0))
+
+	// 10x2 1 bit grayscale image with padded rows, masking the black pixels.
+	img = &Image{
+		Width:            10,
+		Height:           2,
+		BitsPerComponent: 1,
+		ColorComponents:  1,
+		Data:             []byte{0xf0, 0x00, 0x0f, 0xc0},
+	}
+	require.NoError(t, img.applyColorKeyMask([]float64{0, 0}))
+	goimg, err = img.ToGoImage()
+	require.NoError(t, err)
+	for x := 0; x < 10; x++ {
+		_, _, _, a := goimg.At(x, 0).RGBA()
+		require.Equal(t, x < 4, a == 0xffff, "x=%d y=0", x)
+		_, _, _, a = goimg.At(x, 1).RGBA()
+		require.Equal(t, x >= 4, a == 0xffff, "x=%d y=1", x)
+	}
+
+	// Images without masked pixels have no alpha channel.
+	img = &Image{Width: 1, Height: 1, BitsPerComponent: 8, ColorComponents: 1, Data: []byte{128}}
+	require.NoError(t, img.applyColorKeyMask([]float64{0, 10}))
+	require.False(t, img.hasAlpha)
+	require.Error(t, img.applyColorKeyMask([]float64{0, 10, 0, 10}))
+}
+
+func TestImageSoftMask(t *testing.T) {
+	// 4x4 black logo with a 2x2 soft mask scaled to the logo dimensions.
+	logo := &Image{
+		Width:            4,
+		Height:           4,
+		BitsPerComponent: 8,
+		ColorComponents:  3,
+		Data:             make([]byte, 4*4*3),
+	}
+	ximg, err := NewXObjectImageFromImage(logo, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+
+	mask := &Image{
+		Width:            2,
+		Height:           2,
+		BitsPerComponent: 8,
+		ColorComponents:  1,
+		Data:             []byte{255, 128, 0, 255},
+	}
+	smask, err := NewXObjectImageFromImage(mask, nil, core.NewFlateEncoder())
+	require.NoError(t, err)
+	ximg.SMask = smask.ToPdfObject()
+	ximg.Mask = core.MakeArrayFromIntegers([]int{0, 0, 0, 0, 0, 0}) // Overridden by SMask.
+	ximg.ToPdfObject()
+
+	img, err := ximg.ToImage()
+	require.NoError(t, err)
+	goimg, err := img.ToGoImage()
+	require.NoError(t, err)
+
+	// Composite over a blue background.
+	bg := image.NewRGBA(goimg.Bounds())
+	draw.Draw(bg, bg.Bounds(), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(bg, bg.Bounds(), goimg, image.Point{}, draw.Over)
+
+	require.Equal(t, color.RGBA{A: 255}, bg.At(0, 0))
+	require.Equal(t, color.RGBA{A: 255}, bg.At(1, 1))
+	require.Equal(t, color.RGBA{B: 127, A: 255}, bg.At(2, 0))
+	require.Equal(t, color.RGBA{B: 255, A: 255}, bg.At(1, 2))
+	require.Equal(t, color.RGBA{A: 255}, bg.At(3, 3))
+
+	// Decode array of the soft mask inverts the alpha.
+	mask.decode = []float64{1, 0}
+	require.NoError(t, img.applySoftMask(mask))
+	require.Equal(t, uint8(0), img.alphaAt(0, 0))
+	require.Equal(t, uint8(255), img.alphaAt(0, 3))
+}
+
 func makeTestImage(x, y int, val byte) *image.RGBA {
 	rect := image.Rect(0, 0, x, y)
 	m := image.NewRGBA(rect)
@@ -109,30 +109,19 @@ type TtfType struct {
 	GlyphNames []GlyphName
 }
 
-// MakeToUnicode returns a ToUnicode CMap based on the encoding of `ttf`.
-// TODO(peterwilliams97): This currently gives a bad text mapping for creator_test.go but leads to an
-// otherwise valid PDF file that Adobe Reader displays without error.
+// MakeToUnicode returns a ToUnicode CMap that maps the GIDs of `ttf` to runes. It is meant for
+// composite fonts with the Identity-H encoding and identity CID<->GID mapping, where the character
+// codes are the GIDs. If several runes share a glyph, the lowest one is used.
 func (ttf *TtfType) MakeToUnicode() *cmap.CMap {
 	codeToUnicode := make(map[cmap.CharCode]rune)
-	if len(ttf.GlyphNames) == 0 {
-		return cmap.NewToUnicodeCMap(codeToUnicode)
-	}
-
-	for code, gid := range ttf.Chars {
-		// TODO(dennwc): this function is used only in one place and relies on
-		//  			 the fact that the code uses identity CID<->GID mapping
-		charcode := cmap.CharCode(code)
-
-		glyph := ttf.GlyphNames[gid]
-
-		// TODO(dennwc): 'code' is already a rune; do we need this extra lookup?
-		// TODO(dennwc): this cannot be done here; glyphNames might be empty
-		//				 the parent font may specify a different encoding
-		//				 so we should remap on a higher level
-		r, ok := textencoding.GlyphToRune(glyph)
-		if !ok {
-			common.Log.Debug("No rune. code=0x%04x glyph=%q", code, glyph)
-			r = textencoding.MissingCodeRune
+	for r, gid := range ttf.Chars {
+		if gid == 0 {
+			// .notdef
+			continue
+		}
+		charcode := cmap.CharCode(gid)
+		if prev, ok := codeToUnicode[charcode]; ok && prev < r {
+			continue
 		}
 		codeToUnicode[charcode] = r
 	}
@@ -863,12 +863,15 @@ func (p *PdfPage) GetContentStreams() ([]string, error) {
 }
 
 // GetAllContentStreams gets all the content streams for a page as one string.
+// The streams are separated by newlines so that they are parsed as a single content stream: the
+// last token of a stream cannot merge with the first token of the next one, and a comment at the
+// end of a stream does not comment out the start of the next one.
 func (p *PdfPage) GetAllContentStreams() (string, error) {
 	cstreams, err := p.GetContentStreams()
 	if err != nil {
 		return "", err
 	}
-	return strings.Join(cstreams, " "), nil
+	return strings.Join(cstreams, "\n"), nil
 }
 
 // PdfPageResourcesColorspaces contains the colorspace in the PdfPageResources.
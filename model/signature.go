@@ -28,6 +28,9 @@ type pdfSignDictionary struct {
 	contentsOffsetEnd    int
 	byteRangeOffsetStart int
 	byteRangeOffsetEnd   int
+
+	// Set for signatures prepared with PdfWriter.PrepareSignature.
+	handle *SignatureHandle
 }
 
 // GetSubFilter returns SubFilter value or empty string.
@@ -0,0 +1,225 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// StampOptions describes a stamp (e.g. "DRAFT" or a logo) drawn over every page added to
+// a PdfWriter. A stamp consists of a text, an image or both, in which case the text is drawn
+// over the image.
+type StampOptions struct {
+	// Text to draw. Drawn with Font at FontSize in Color. If Font is nil, the default font
+	// (Helvetica) is used. If FontSize is 0, a size of 72 points is used. If Color is nil,
+	// the text is drawn in gray.
+	Text     string
+	Font     *PdfFont
+	FontSize float64
+	Color    *PdfColorDeviceRGB
+
+	// Image to draw, with the Width and Height specified in points. If Width or Height are 0,
+	// the dimensions of the image in pixels are used.
+	Image  *XObjectImage
+	Width  float64
+	Height float64
+
+	// Alpha is the opacity of the stamp in the range (0, 1].
+	Alpha float64
+
+	// Angle is the counterclockwise rotation of the stamp in degrees around its center.
+	Angle float64
+
+	// CenterX and CenterY specify the position of the stamp center relative to the lower
+	// left corner of the page MediaBox. If both are 0, the stamp is centered on the page.
+	CenterX float64
+	CenterY float64
+}
+
+// pdfStamp holds the resources of a stamp, shared by all the pages it is applied to.
+type pdfStamp struct {
+	opt      StampOptions
+	font     *PdfFont
+	fontObj  core.PdfObject
+	imageObj *core.PdfObjectStream
+	gsObj    *core.PdfIndirectObject
+	text     *core.PdfObjectString
+	width    float64 // Width of the text in points.
+}
+
+// SetStamp sets a stamp to be drawn over each page subsequently added with AddPage.
+// The font, image and graphics state resources of the stamp are shared by all the pages.
+// Passing nil removes the stamp.
+func (w *PdfWriter) SetStamp(opt *StampOptions) error {
+	if opt == nil {
+		w.stamp = nil
+		return nil
+	}
+	if opt.Text == "" && opt.Image == nil {
+		return errors.New("stamp has no text or image")
+	}
+	if opt.Alpha <= 0 || opt.Alpha > 1 {
+		return fmt.Errorf("stamp alpha out of range (%v)", opt.Alpha)
+	}
+
+	s := &pdfStamp{opt: *opt}
+	if s.opt.Text != "" {
+		s.font = s.opt.Font
+		if s.font == nil {
+			s.font = DefaultFont()
+		}
+		if s.opt.FontSize == 0 {
+			s.opt.FontSize = 72
+		}
+		if s.opt.Color == nil {
+			s.opt.Color = NewPdfColorDeviceRGB(0.5, 0.5, 0.5)
+		}
+
+		encoder := s.font.Encoder()
+		if encoder == nil {
+			return errors.New("stamp font has no encoder")
+		}
+		s.text = core.MakeStringFromBytes(encoder.Encode(s.opt.Text))
+		for _, r := range s.opt.Text {
+			if m, ok := s.font.GetRuneMetrics(r); ok {
+				s.width += m.Wx * s.opt.FontSize / 1000.0
+			}
+		}
+		s.fontObj = s.font.ToPdfObject()
+	}
+
+	if img := s.opt.Image; img != nil {
+		if s.opt.Width == 0 || s.opt.Height == 0 {
+			if img.Width == nil || img.Height == nil {
+				return errors.New("stamp image dimensions not set")
+			}
+			s.opt.Width = float64(*img.Width)
+			s.opt.Height = float64(*img.Height)
+		}
+		stream, ok := img.ToPdfObject().(*core.PdfObjectStream)
+		if !ok {
+			return core.ErrTypeError
+		}
+		s.imageObj = stream
+	}
+
+	gs := core.MakeDict()
+	gs.Set("Type", core.MakeName("ExtGState"))
+	gs.Set("CA", core.MakeFloat(s.opt.Alpha))
+	gs.Set("ca", core.MakeFloat(s.opt.Alpha))
+	s.gsObj = core.MakeIndirectObject(gs)
+
+	w.stamp = s
+	return nil
+}
+
+// apply adds the stamp resources to the resources of page `p` and draws the stamp over the
+// page contents.
+func (s *pdfStamp) apply(p *PdfPage) error {
+	bbox, err := p.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	cx, cy := s.opt.CenterX, s.opt.CenterY
+	if cx == 0 && cy == 0 {
+		cx, cy = bbox.Width()/2, bbox.Height()/2
+	}
+	cx += bbox.Llx
+	cy += bbox.Lly
+
+	if p.Resources == nil {
+		p.Resources = NewPdfPageResources()
+	}
+	if p.Resources.ExtGState == nil {
+		p.Resources.ExtGState = core.MakeDict()
+	}
+	if p.Resources.Font == nil && s.fontObj != nil {
+		p.Resources.Font = core.MakeDict()
+	}
+	if p.Resources.XObject == nil && s.imageObj != nil {
+		p.Resources.XObject = core.MakeDict()
+	}
+
+	gsName, err := stampResourceName(p.Resources.ExtGState, "StGS", s.gsObj)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	angle := s.opt.Angle * math.Pi / 180.0
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	fmt.Fprintf(&buf, "q\n/%s gs\n", gsName)
+	fmt.Fprintf(&buf, "%.4f %.4f %.4f %.4f %.4f %.4f cm\n", cos, sin, -sin, cos, cx, cy)
+
+	if s.imageObj != nil {
+		imgName, err := stampResourceName(p.Resources.XObject, "StIm", s.imageObj)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "q\n%.4f 0 0 %.4f %.4f %.4f cm\n/%s Do\nQ\n",
+			s.opt.Width, s.opt.Height, -s.opt.Width/2, -s.opt.Height/2, imgName)
+	}
+
+	if s.fontObj != nil {
+		fontName, err := stampResourceName(p.Resources.Font, "StF", s.fontObj)
+		if err != nil {
+			return err
+		}
+		// Approximate the vertical center of the text with a third of the font size.
+		color := s.opt.Color
+		fmt.Fprintf(&buf, "BT\n/%s %.4f Tf\n%.4f %.4f %.4f rg\n%.4f %.4f Td\n%s Tj\nET\n",
+			fontName, s.opt.FontSize, color.R(), color.G(), color.B(),
+			-s.width/2, -s.opt.FontSize/3, s.text.WriteString())
+	}
+	buf.WriteString("Q")
+	content := buf.String()
+
+	// Isolate the graphics state of the existing contents so that it does not affect the stamp.
+	if p.Contents != nil {
+		qStream, err := core.MakeStream([]byte("q\n"), nil)
+		if err != nil {
+			return err
+		}
+		contents := core.MakeArray(qStream)
+		if arr, ok := core.GetArray(p.Contents); ok {
+			contents.Append(arr.Elements()...)
+		} else {
+			contents.Append(p.Contents)
+		}
+		p.Contents = contents
+		content = "Q\n" + content
+	}
+	return p.AddContentStreamByString(content)
+}
+
+// stampResourceName returns the name under which `obj` is stored in the resource dictionary
+// `resDict`, adding it with a name based on `prefix` if not already present. Pages commonly
+// share their resource dictionaries, in which case the existing entry is reused.
+func stampResourceName(resDict core.PdfObject, prefix string, obj core.PdfObject) (core.PdfObjectName, error) {
+	dict, ok := core.GetDict(resDict)
+	if !ok {
+		return "", core.ErrTypeError
+	}
+	for _, key := range dict.Keys() {
+		if dict.Get(key) == obj {
+			return key, nil
+		}
+	}
+
+	i := 0
+	name := core.PdfObjectName(fmt.Sprintf("%s%d", prefix, i))
+	for dict.Get(name) != nil {
+		i++
+		name = core.PdfObjectName(fmt.Sprintf("%s%d", prefix, i))
+	}
+	dict.Set(name, obj)
+	return name, nil
+}
@@ -0,0 +1,178 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// NewPdfStreamWriter returns a new PdfWriter which writes the pages to `ws` as they are added
+// with AddPage, instead of keeping all the objects in memory until the document is written.
+// Close writes the remaining objects, e.g. the catalog and the page tree, followed by the cross
+// reference table, and must be called once all the pages have been added.
+//
+// The objects of each page are numbered and written when the page is added. The page and its
+// content streams are not kept afterwards, so written pages cannot be referenced later, e.g. by
+// outlines or links. The other objects used by the pages, such as the fonts and images of their
+// resources, are kept so that they are only written once when shared by several pages.
+// Stream writers do not support encryption, inserting pages or Write.
+//
+// The header is written with the PDF version set at creation. Versions set afterwards with
+// SetVersion are written in the catalog.
+func NewPdfStreamWriter(ws io.WriteSeeker) (*PdfWriter, error) {
+	offset, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewPdfWriter()
+	w.streamOutput = ws
+	w.writer = bufio.NewWriter(ws)
+	w.writeOffset = offset
+	w.writePos = offset
+	w.crossReferenceMap = map[int]crossReference{
+		0: {Type: 0, ObjectNumber: 0, Generation: 0xFFFF},
+	}
+	// Number the objects created with the writer, e.g. the page tree the pages refer to.
+	for _, obj := range w.objects {
+		w.numberStreamObject(obj)
+	}
+
+	w.writeString(fmt.Sprintf("%%PDF-%d.%d\n", w.majorVersion, w.minorVersion))
+	w.writeString("%âãÏÓ\n")
+	return &w, nil
+}
+
+// numberStreamObject numbers `obj` with the next object number of the stream writer.
+func (w *PdfWriter) numberStreamObject(obj core.PdfObject) {
+	ref := objectReference(obj)
+	if ref == nil {
+		common.Log.Debug("ERROR: Unknown type %T - skipping", obj)
+		return
+	}
+	w.lastObjNum++
+	ref.ObjectNumber = w.lastObjNum
+	ref.GenerationNumber = 0
+}
+
+// streamPage adds `page` to the stream writer and writes out the objects added with it.
+func (w *PdfWriter) streamPage(page *PdfPage) error {
+	if w.streamClosed {
+		return errors.New("stream writer is closed")
+	}
+
+	start := len(w.objects)
+	if err := w.addPage(page, -1); err != nil {
+		return err
+	}
+	pageObj, ok := core.GetIndirect(page.GetContainingPdfObject())
+	if !ok {
+		return ErrPageNotIndirect
+	}
+
+	// The objects have been numbered when added, so they can refer to each other.
+	for _, obj := range w.objects[start:] {
+		objNum := int(objectReference(obj).ObjectNumber)
+		if stream, ok := obj.(*core.PdfObjectStream); ok {
+			encoded, err := w.encodePageStream(stream)
+			if err != nil {
+				return err
+			}
+			obj = encoded
+		}
+		w.writeObject(objNum, obj)
+	}
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+
+	// Release the written objects. The ones that can be shared with later pages are still
+	// marked as added, so that they are not written again.
+	for i := start; i < len(w.objects); i++ {
+		w.objects[i] = nil
+	}
+	w.objects = w.objects[:start]
+	// The page tree is not traversed again when resolving references, as the references to the
+	// written pages cannot be resolved.
+	w.traversed = map[core.PdfObject]struct{}{w.pages: {}}
+	delete(w.objectsMap, pageObj)
+	if pDict, ok := core.GetDict(pageObj); ok {
+		switch t := pDict.Get("Contents").(type) {
+		case *core.PdfObjectStream:
+			delete(w.objectsMap, t)
+		case *core.PdfObjectArray:
+			for _, obj := range t.Elements() {
+				delete(w.objectsMap, obj)
+			}
+		}
+	}
+
+	// The page tree refers to the written page by reference.
+	pagesDict, ok := core.GetDict(w.pages)
+	if !ok {
+		return errors.New("invalid Pages obj (not a dict)")
+	}
+	kids, ok := core.GetArray(pagesDict.Get("Kids"))
+	if !ok {
+		return errors.New("invalid Pages Kids obj (not an array)")
+	}
+	ref := pageObj.PdfObjectReference
+	return kids.Set(kids.Len()-1, &ref)
+}
+
+// encodePageStream returns `stream` encoded with its encoder set with SetStreamEncoder and
+// compressed if SetCompressStreams is enabled, as done by write for the other writers. The
+// streams are encoded on copies, leaving the added objects unmodified.
+func (w *PdfWriter) encodePageStream(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	encoder, hasEncoder := w.streamEncoders[stream]
+	if !hasEncoder && !w.compressStreams {
+		return stream, nil
+	}
+
+	streamCopy := &core.PdfObjectStream{
+		PdfObjectReference:  stream.PdfObjectReference,
+		PdfObjectDictionary: core.MakeDict().Merge(stream.PdfObjectDictionary),
+		Stream:              stream.Stream,
+	}
+	if hasEncoder {
+		if err := encodeStream(streamCopy, encoder); err != nil {
+			return nil, err
+		}
+	}
+	if w.compressStreams {
+		if err := compressStream(streamCopy, core.NewFlateEncoder()); err != nil {
+			return nil, err
+		}
+	}
+	return streamCopy, nil
+}
+
+// Close writes out the remaining objects and the cross reference table of a writer created with
+// NewPdfStreamWriter, finishing the document.
+func (w *PdfWriter) Close() error {
+	if w.streamOutput == nil {
+		return errors.New("not a stream writer")
+	}
+	if w.streamClosed {
+		return errors.New("stream writer is already closed")
+	}
+	w.streamClosed = true
+
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	// Objects added from now on, e.g. object streams, are numbered after the written ones.
+	w.writeOffset = w.writePos
+	w.ObjNumOffset = int(w.lastObjNum)
+	return w.write(context.Background(), w.streamOutput)
+}
@@ -11,7 +11,9 @@ package model
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
+	"context"
+	"crypto/md5"
+	"encoding/xml"
 	"errors"
 	"flag"
 	"fmt"
@@ -25,6 +27,7 @@ import (
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/core/security"
 	"github.com/unidoc/unipdf/v3/core/security/crypt"
+	"github.com/unidoc/unipdf/v3/internal/strutils"
 )
 
 var pdfAuthor = ""
@@ -33,6 +36,7 @@ var pdfCreator = ""
 var pdfKeywords = ""
 var pdfModifiedDate time.Time
 var pdfProducer = ""
+var pdfProducerTemplate = ""
 var pdfSubject = ""
 var pdfTitle = ""
 
@@ -46,6 +50,18 @@ type crossReference struct {
 	Index        int
 }
 
+// streamFields returns the values of the three fields of the entry for `ref` in a cross
+// reference stream.
+func (ref crossReference) streamFields() [3]int64 {
+	switch ref.Type {
+	case 1:
+		return [3]int64{1, ref.Offset, ref.Generation}
+	case 2:
+		return [3]int64{2, int64(ref.ObjectNumber), int64(ref.Index)}
+	}
+	return [3]int64{0, int64(ref.ObjectNumber), ref.Generation}
+}
+
 func getPdfAuthor() string {
 	return pdfAuthor
 }
@@ -93,14 +109,18 @@ func getPdfModifiedDate() time.Time {
 
 // SetPdfModifiedDate sets the ModDate attribute of the output PDF.
 func SetPdfModifiedDate(modifiedDate time.Time) {
-	pdfCreationDate = modifiedDate
+	pdfModifiedDate = modifiedDate
 }
 
 func getPdfProducer() string {
 	licenseKey := license.GetLicenseKey()
-	if len(pdfProducer) > 0 && (licenseKey.IsLicensed() || flag.Lookup("test.v") != nil) {
+	canOverride := licenseKey.IsLicensed() || flag.Lookup("test.v") != nil
+	if len(pdfProducer) > 0 && canOverride {
 		return pdfProducer
 	}
+	if len(pdfProducerTemplate) > 0 && canOverride {
+		return strings.Replace(pdfProducerTemplate, "%s", getUniDocVersion(), 1)
+	}
 
 	// Return default.
 	return fmt.Sprintf("UniDoc v%s (%s) - http://unidoc.io", getUniDocVersion(), licenseKey.TypeToString())
@@ -111,6 +131,13 @@ func SetPdfProducer(producer string) {
 	pdfProducer = producer
 }
 
+// SetPdfProducerTemplate sets a template for the Producer attribute of the output PDF, where
+// the first %s is replaced by the UniDoc version, e.g. "MyApp (based on UniDoc v%s)".
+// A producer set via SetPdfProducer takes precedence over the template.
+func SetPdfProducerTemplate(template string) {
+	pdfProducerTemplate = template
+}
+
 func getPdfSubject() string {
 	return pdfSubject
 }
@@ -129,6 +156,22 @@ func SetPdfTitle(title string) {
 	pdfTitle = title
 }
 
+// Errors returned by PdfWriter, wrapped with details, which can be checked with errors.Is.
+var (
+	// ErrReferenceInWriter is returned when an unresolved reference object is added for writing.
+	ErrReferenceInWriter = errors.New("reference object cannot be in writer")
+	// ErrParentIsReference is returned when the Parent entry of an added dictionary is an
+	// unresolved reference.
+	ErrParentIsReference = errors.New("parent is a reference object")
+	// ErrPageNotIndirect is returned when an added page is not an indirect object.
+	ErrPageNotIndirect = errors.New("page should be an indirect object")
+	// ErrInvalidPage is returned when an added page is not a valid page dictionary.
+	ErrInvalidPage = errors.New("invalid page")
+	// ErrObjectReplacedWithNull is the warning recorded when an object referenced by the Parent
+	// entry of a written dictionary is never added for writing, and is replaced with null.
+	ErrObjectReplacedWithNull = errors.New("object never added for writing replaced with null")
+)
+
 // PdfWriter handles outputing PDF content.
 type PdfWriter struct {
 	root        *core.PdfIndirectObject
@@ -148,6 +191,9 @@ type PdfWriter struct {
 	encryptDict *core.PdfObjectDictionary
 	encryptObj  *core.PdfIndirectObject
 	ids         *core.PdfObjectArray
+	documentID  [2]string
+	// xmpMetadata is set when the XMP metadata set with SetXMPMetadata is to be left unencrypted.
+	xmpMetadata bool
 
 	// PDF version
 	majorVersion int
@@ -164,8 +210,15 @@ type PdfWriter struct {
 	// Only way so we can access the dictionary entry later.
 	pendingObjects map[core.PdfObject][]*core.PdfObjectDictionary
 
+	// Recoverable problems found while writing.
+	warnings []error
+
 	// Forms.
 	acroForm *PdfAcroForm
+	sigFlags *SigFlag
+
+	// Signature prepared with PrepareSignature, whose ByteRange is filled in when writing.
+	signature *SignatureHandle
 
 	optimizer              Optimizer
 	crossReferenceMap      map[int]crossReference
@@ -177,9 +230,37 @@ type PdfWriter struct {
 	appendPrevRevisionSize int64
 	// Map of object to object number for replacements.
 	appendReplaceMap map[core.PdfObject]int64
+	// Incremental update written by WriteIncremental.
+	incremental *incrementalUpdate
 
 	// Cache of objects traversed while resolving references.
 	traversed map[core.PdfObject]struct{}
+
+	// Stamp drawn over each added page.
+	stamp *pdfStamp
+
+	// Write a hybrid-reference file when using a cross reference stream.
+	hybridCrossReference bool
+
+	// Compress eligible objects into object streams, holding up to maxObjectsPerStream objects.
+	objectStreamMode    bool
+	maxObjectsPerStream int
+
+	// Flate encode streams without a filter when writing.
+	compressStreams bool
+
+	// Encoders of the streams to encode when writing.
+	streamEncoders map[*core.PdfObjectStream]core.StreamEncoder
+	// Streams set with SetStreamEncoder of the copies of the streams made when writing.
+	streamOrigins map[*core.PdfObjectStream]*core.PdfObjectStream
+
+	// Keep the object and generation numbers of objects that already have them.
+	preserveObjectNumbers bool
+
+	// Output of stream writers, to which the pages are written as they are added.
+	streamOutput io.Writer
+	streamClosed bool
+	lastObjNum   int64 // Last object number allocated when streaming.
 }
 
 // NewPdfWriter initializes a new PdfWriter.
@@ -227,6 +308,9 @@ func NewPdfWriter() PdfWriter {
 		}
 	}
 
+	// Some PDF/X profiles require the Trapped entry to be present.
+	infoDict.Set("Trapped", core.MakeName(string(TrappedUnknown)))
+
 	infoObj := core.PdfIndirectObject{}
 	infoObj.PdfObject = infoDict
 	w.infoObj = &infoObj
@@ -261,6 +345,68 @@ func NewPdfWriter() PdfWriter {
 	return w
 }
 
+// NewPdfWriterFromReader initializes a new PdfWriter with the document catalog and information
+// dictionary of `reader`. All catalog entries except for the page tree are carried over verbatim,
+// including the ones the writer does not model (e.g. /Perms, /Collection or /AF), as are the
+// document information entries. Entries set on the writer afterwards, e.g. with SetForms,
+// override them. The pages are not added, AddPage must be called for each page to write.
+func NewPdfWriterFromReader(reader *PdfReader) (PdfWriter, error) {
+	w := NewPdfWriter()
+
+	// Do not lower the version of the document, which is taken from the catalog if specified
+	// there and greater than the header version.
+	version := reader.PdfVersion()
+	if name, ok := core.GetName(reader.catalog.Get("Version")); ok {
+		var v core.Version
+		if _, err := fmt.Sscanf(name.String(), "%d.%d", &v.Major, &v.Minor); err == nil &&
+			(v.Major > version.Major || v.Major == version.Major && v.Minor > version.Minor) {
+			version = v
+		}
+	}
+	if version.Major > w.majorVersion || version.Major == w.majorVersion && version.Minor > w.minorVersion {
+		w.SetVersion(version.Major, version.Minor)
+	}
+
+	trailer, err := reader.GetTrailer()
+	if err != nil {
+		return w, err
+	}
+	if info, ok := core.GetDict(trailer.Get("Info")); ok {
+		infoDict, ok := core.GetDict(w.infoObj)
+		if !ok {
+			return w, errors.New("invalid info dictionary")
+		}
+		for _, key := range info.Keys() {
+			if err := w.copyEntry(infoDict, key, info.Get(key)); err != nil {
+				return w, err
+			}
+		}
+	}
+
+	for _, key := range reader.catalog.Keys() {
+		switch key {
+		case "Type", "Pages", "Version":
+			// Set by the writer.
+			continue
+		}
+		if err := w.copyEntry(w.catalog, key, reader.catalog.Get(key)); err != nil {
+			return w, err
+		}
+	}
+	return w, nil
+}
+
+// copyEntry sets the entry `key` of `dict` to `obj`, with all its references resolved, and adds
+// the objects it refers to for writing.
+func (w *PdfWriter) copyEntry(dict *core.PdfObjectDictionary, key core.PdfObjectName, obj core.PdfObject) error {
+	obj = core.ResolveReference(obj)
+	if err := core.ResolveReferencesDeep(obj, w.traversed); err != nil {
+		return err
+	}
+	dict.Set(key, obj)
+	return w.addObjects(obj)
+}
+
 // copyObject creates deep copy of the Pdf object and
 // fills objectToObjectCopyMap to replace the old object to the copy of object if needed.
 // Parameter objectToObjectCopyMap is needed to replace object references to its copies.
@@ -342,6 +488,7 @@ func copyObject(obj core.PdfObject, objectToObjectCopyMap map[core.PdfObject]cor
 			PdfObjectDictionary: core.MakeDict(),
 			handler:             t.handler,
 			signature:           t.signature,
+			handle:              t.handle,
 		}
 		objectToObjectCopyMap[obj] = newObj
 		for _, key := range t.Keys() {
@@ -387,38 +534,837 @@ func (w *PdfWriter) copyObjects() {
 		}
 		w.appendReplaceMap = appendReplaceMap
 	}
+
+	// Keep track of the streams that can be set with SetStreamEncoder, as the copied streams may
+	// be copies themselves when writing several times.
+	streamOrigins := make(map[*core.PdfObjectStream]*core.PdfObjectStream)
+	for obj, objCopy := range objectToObjectCopyMap {
+		stream, ok := obj.(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		streamCopy, ok := objCopy.(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		if origin, has := w.streamOrigins[stream]; has {
+			stream = origin
+		}
+		streamOrigins[streamCopy] = stream
+	}
+	w.streamOrigins = streamOrigins
+}
+
+// encodeObjectStreams encodes the streams to be written that have an encoder set with
+// SetStreamEncoder. The objects must have been copied (copyObjects) as the streams are modified.
+func (w *PdfWriter) encodeObjectStreams() error {
+	for _, obj := range w.objects {
+		stream, ok := obj.(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		encoder, ok := w.streamEncoders[w.streamOrigins[stream]]
+		if !ok {
+			continue
+		}
+		if err := encodeStream(stream, encoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeStream encodes the data of `stream` with `encoder`, unless it is already encoded with the
+// filter of `encoder`.
+func encodeStream(stream *core.PdfObjectStream, encoder core.StreamEncoder) error {
+	filterName := encoder.GetFilterName()
+	if filter := stream.Get("Filter"); filter != nil {
+		name, ok := core.GetName(filter)
+		if !ok || name.String() != filterName {
+			return fmt.Errorf("stream with filter %s cannot be encoded with %s", filter, filterName)
+		}
+		// The stream is already encoded with the filter of the encoder.
+		stream.Set("Length", core.MakeInteger(int64(len(stream.Stream))))
+		return nil
+	}
+
+	encoded, err := encoder.EncodeBytes(stream.Stream)
+	if err != nil {
+		return err
+	}
+	stream.Stream = encoded
+	stream.Set("Filter", core.MakeName(filterName))
+	if decodeParams := encoder.MakeDecodeParams(); decodeParams != nil {
+		stream.Set("DecodeParms", decodeParams)
+	} else {
+		stream.Remove("DecodeParms")
+	}
+	stream.Set("Length", core.MakeInteger(int64(len(encoded))))
+	return nil
+}
+
+// compressObjectStreams encodes the streams to be written that have no filter with FlateDecode.
+// The objects must have been copied (copyObjects) as the streams are modified.
+func (w *PdfWriter) compressObjectStreams() error {
+	encoder := core.NewFlateEncoder()
+	for _, obj := range w.objects {
+		stream, ok := obj.(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		if err := compressStream(stream, encoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressStream encodes `stream` with the FlateDecode `encoder` if it has no filter and gets
+// smaller. Metadata streams are left as they are.
+func compressStream(stream *core.PdfObjectStream, encoder *core.FlateEncoder) error {
+	if stream.Get("Filter") != nil {
+		return nil
+	}
+	// Leave metadata readable by non PDF tools.
+	if name, ok := core.GetName(stream.Get("Type")); ok && *name == "Metadata" {
+		return nil
+	}
+
+	encoded, err := encoder.EncodeBytes(stream.Stream)
+	if err != nil {
+		return err
+	}
+	if len(encoded) >= len(stream.Stream) {
+		return nil
+	}
+	stream.Stream = encoded
+	stream.Set("Filter", core.MakeName(encoder.GetFilterName()))
+	stream.Set("Length", core.MakeInteger(int64(len(encoded))))
+	return nil
+}
+
+// SetVersion sets the PDF version of the output file.
+func (w *PdfWriter) SetVersion(majorVersion, minorVersion int) {
+	w.majorVersion = majorVersion
+	w.minorVersion = minorVersion
+}
+
+// TrappedState represents the value of the Trapped entry of the document information
+// dictionary, which indicates whether the document has been modified to include trapping
+// information. See section 14.11.6 "Trapping Support" (p. 636 PDF32000_2008).
+type TrappedState string
+
+// Trapped states.
+const (
+	TrappedTrue    TrappedState = "True"
+	TrappedFalse   TrappedState = "False"
+	TrappedUnknown TrappedState = "Unknown"
+)
+
+// SetTrapped sets the Trapped entry of the document information dictionary.
+// If not set, the entry is written as Unknown.
+func (w *PdfWriter) SetTrapped(state TrappedState) error {
+	switch state {
+	case TrappedTrue, TrappedFalse, TrappedUnknown:
+	default:
+		return fmt.Errorf("invalid trapped state: %q", state)
+	}
+
+	infoDict, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return errors.New("invalid info dictionary")
+	}
+	infoDict.Set("Trapped", core.MakeName(string(state)))
+	return nil
+}
+
+// PdfInfo represents the entries of the document information dictionary of the output PDF.
+// See section 14.3.3 "Document Information Dictionary" (p. 549 PDF32000_2008).
+type PdfInfo struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string
+	Producer     string
+	CreationDate time.Time
+	ModifiedDate time.Time
+	Trapped      TrappedState
+}
+
+// GetInfo returns the current entries of the document information dictionary.
+func (w *PdfWriter) GetInfo() PdfInfo {
+	var info PdfInfo
+	infoDict, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return info
+	}
+	getString := func(key core.PdfObjectName) string {
+		if str, ok := core.GetString(infoDict.Get(key)); ok {
+			return str.Decoded()
+		}
+		return ""
+	}
+	getDate := func(key core.PdfObjectName) time.Time {
+		if str, ok := core.GetString(infoDict.Get(key)); ok {
+			if date, err := NewPdfDate(str.Str()); err == nil {
+				return date.ToGoTime()
+			}
+		}
+		return time.Time{}
+	}
+
+	info.Title = getString("Title")
+	info.Author = getString("Author")
+	info.Subject = getString("Subject")
+	info.Keywords = getString("Keywords")
+	info.Creator = getString("Creator")
+	info.Producer = getString("Producer")
+	info.CreationDate = getDate("CreationDate")
+	info.ModifiedDate = getDate("ModDate")
+	if name, ok := core.GetName(infoDict.Get("Trapped")); ok {
+		info.Trapped = TrappedState(*name)
+	}
+	return info
+}
+
+// SetTitle sets the Title entry of the document information dictionary.
+// An empty `title` removes the entry.
+func (w *PdfWriter) SetTitle(title string) error {
+	return w.setInfoString("Title", title)
+}
+
+// SetAuthor sets the Author entry of the document information dictionary.
+// An empty `author` removes the entry.
+func (w *PdfWriter) SetAuthor(author string) error {
+	return w.setInfoString("Author", author)
+}
+
+// SetSubject sets the Subject entry of the document information dictionary.
+// An empty `subject` removes the entry.
+func (w *PdfWriter) SetSubject(subject string) error {
+	return w.setInfoString("Subject", subject)
+}
+
+// SetKeywords sets the Keywords entry of the document information dictionary.
+// An empty `keywords` removes the entry.
+func (w *PdfWriter) SetKeywords(keywords string) error {
+	return w.setInfoString("Keywords", keywords)
+}
+
+// SetCreationDate sets the CreationDate entry of the document information dictionary.
+// A zero `date` removes the entry.
+func (w *PdfWriter) SetCreationDate(date time.Time) error {
+	return w.setInfoDate("CreationDate", date)
+}
+
+// SetModifiedDate sets the ModDate entry of the document information dictionary.
+// A zero `date` removes the entry.
+func (w *PdfWriter) SetModifiedDate(date time.Time) error {
+	return w.setInfoDate("ModDate", date)
+}
+
+// setInfoString sets the text string entry `key` of the document information dictionary to
+// `value`, or removes it if `value` is empty. The strings are encrypted with the rest of the
+// document when writing.
+func (w *PdfWriter) setInfoString(key core.PdfObjectName, value string) error {
+	infoDict, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return errors.New("invalid info dictionary")
+	}
+	if value == "" {
+		infoDict.Remove(key)
+		return nil
+	}
+	infoDict.Set(key, makeTextString(value))
+	return nil
+}
+
+// makeTextString returns the text string `value` encoded with PDFDocEncoding, unless the text
+// requires UTF-16BE.
+func makeTextString(value string) *core.PdfObjectString {
+	utf16 := strutils.PDFDocEncodingToString(strutils.StringToPDFDocEncoding(value)) != value
+	return core.MakeEncodedString(value, utf16)
+}
+
+// setInfoDate sets the date entry `key` of the document information dictionary to `date`, or
+// removes it if `date` is zero.
+func (w *PdfWriter) setInfoDate(key core.PdfObjectName, date time.Time) error {
+	infoDict, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return errors.New("invalid info dictionary")
+	}
+	if date.IsZero() {
+		infoDict.Remove(key)
+		return nil
+	}
+	pdfDate, err := NewPdfDateFromTime(date)
+	if err != nil {
+		return err
+	}
+	infoDict.Set(key, pdfDate.ToPdfObject())
+	return nil
+}
+
+// SetXMPMetadata sets the XMP metadata packet `xmp` of the document, written as an uncompressed
+// metadata stream referenced by the Metadata entry of the catalog. When the document is encrypted,
+// the metadata is left unencrypted (see EncryptOptions.UnencryptedMetadata) and the stream uses
+// the Identity crypt filter, so that it remains readable without the password. As this requires
+// an AES algorithm, Encrypt fails with the RC4 algorithm, and the metadata must be set before
+// encrypting unless EncryptOptions.UnencryptedMetadata is set. An empty `xmp` removes the entry.
+// See section 14.3.2 "Metadata Streams" (p. 549 PDF32000_2008).
+func (w *PdfWriter) SetXMPMetadata(xmp []byte) error {
+	if len(xmp) == 0 {
+		w.catalog.Remove("Metadata")
+		w.xmpMetadata = false
+		return nil
+	}
+	if w.encryptDict != nil {
+		if encryptMetadata, ok := core.GetBoolVal(w.encryptDict.Get("EncryptMetadata")); !ok || encryptMetadata {
+			return errors.New("XMP metadata must be set before encrypting")
+		}
+	}
+	w.xmpMetadata = true
+
+	// Replace the data of the current metadata stream rather than leaving it orphaned.
+	if stream, ok := core.GetStream(w.catalog.Get("Metadata")); ok && w.hasObject(stream) {
+		stream.Stream = xmp
+		stream.Remove("Filter")
+		stream.Remove("DecodeParms")
+		stream.Set("Length", core.MakeInteger(int64(len(xmp))))
+		return nil
+	}
+
+	stream, err := core.MakeStream(xmp, nil)
+	if err != nil {
+		return err
+	}
+	stream.Set("Type", core.MakeName("Metadata"))
+	stream.Set("Subtype", core.MakeName("XML"))
+	w.catalog.Set("Metadata", stream)
+	return w.addObjects(stream)
+}
+
+// SetXMPFromInfo sets the XMP metadata of the document (see SetXMPMetadata) to a packet generated
+// from the current entries of the document information dictionary, so that the title, author
+// and dates read from the XMP metadata match the ones of the information dictionary.
+func (w *PdfWriter) SetXMPFromInfo() error {
+	return w.SetXMPMetadata(makeXMPPacket(w.GetInfo()))
+}
+
+// makeXMPPacket returns an XMP packet with the Dublin Core, XMP basic and Adobe PDF properties
+// corresponding to the document information entries `info`.
+func makeXMPPacket(info PdfInfo) []byte {
+	var buf bytes.Buffer
+	escape := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	writeProp := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "   <%s>%s</%s>\n", name, escape(value), name)
+		}
+	}
+	writeDate := func(name string, date time.Time) {
+		if !date.IsZero() {
+			writeProp(name, date.Format(time.RFC3339))
+		}
+	}
+
+	buf.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buf.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buf.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	buf.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	buf.WriteString("    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	buf.WriteString("    xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	buf.WriteString("   <dc:format>application/pdf</dc:format>\n")
+	if info.Title != "" {
+		fmt.Fprintf(&buf, "   <dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n",
+			escape(info.Title))
+	}
+	if info.Author != "" {
+		fmt.Fprintf(&buf, "   <dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n",
+			escape(info.Author))
+	}
+	if info.Subject != "" {
+		fmt.Fprintf(&buf, "   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n",
+			escape(info.Subject))
+	}
+	writeProp("pdf:Keywords", info.Keywords)
+	writeProp("pdf:Producer", info.Producer)
+	writeProp("xmp:CreatorTool", info.Creator)
+	writeDate("xmp:CreateDate", info.CreationDate)
+	writeDate("xmp:ModifyDate", info.ModifiedDate)
+	buf.WriteString("  </rdf:Description>\n")
+	buf.WriteString(" </rdf:RDF>\n")
+	buf.WriteString("</x:xmpmeta>\n")
+	buf.WriteString("<?xpacket end=\"w\"?>")
+	return buf.Bytes()
+}
+
+// SetOCProperties sets the optional content properties.
+func (w *PdfWriter) SetOCProperties(ocProperties core.PdfObject) error {
+	dict := w.catalog
+
+	if ocProperties != nil {
+		common.Log.Trace("Setting OC Properties...")
+		dict.Set("OCProperties", ocProperties)
+		// Any risk of infinite loops?
+		return w.addObjects(ocProperties)
+	}
+
+	return nil
+}
+
+// AddOptionalContentGroup creates an optional content group (layer) named `name`, which is on by
+// default, and registers it in the OCGs and the default configuration of the catalog
+// OCProperties. The returned group can be referenced from the properties of marked content
+// (/OC /Name BDC) or from the OC entry of an XObject or annotation.
+// See section 8.11 "Optional Content" (p. 222 PDF32000_2008).
+func (w *PdfWriter) AddOptionalContentGroup(name string) (*core.PdfIndirectObject, error) {
+	if name == "" {
+		return nil, errors.New("optional content group name must not be empty")
+	}
+	ocProperties, err := w.optionalContentProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	ocg := core.MakeDict()
+	ocg.Set("Type", core.MakeName("OCG"))
+	ocg.Set("Name", makeTextString(name))
+	ocgObj := core.MakeIndirectObject(ocg)
+
+	ocgs, _ := core.GetArray(ocProperties.Get("OCGs"))
+	ocgs.Append(ocgObj)
+	if err := w.SetLayerVisibility(ocgObj, true); err != nil {
+		return nil, err
+	}
+	return ocgObj, w.addObjects(ocgObj)
+}
+
+// SetLayerVisibility sets whether the optional content group `ocg`, e.g. one created with
+// AddOptionalContentGroup, is visible when the document is opened, by moving it to the ON or
+// OFF array of the default configuration of the catalog OCProperties.
+func (w *PdfWriter) SetLayerVisibility(ocg *core.PdfIndirectObject, visible bool) error {
+	ocProperties, err := w.optionalContentProperties()
+	if err != nil {
+		return err
+	}
+	ocgs, _ := core.GetArray(ocProperties.Get("OCGs"))
+	if !containsObject(ocgs, ocg) {
+		return errors.New("optional content group not in the OCGs of the document")
+	}
+
+	config, ok := core.GetDict(ocProperties.Get("D"))
+	if !ok {
+		return errors.New("invalid default optional content configuration")
+	}
+	from, to := core.PdfObjectName("OFF"), core.PdfObjectName("ON")
+	if !visible {
+		from, to = to, from
+	}
+	if arr, ok := core.GetArray(config.Get(from)); ok {
+		var elements []core.PdfObject
+		for _, obj := range arr.Elements() {
+			if core.ResolveReference(obj) != ocg {
+				elements = append(elements, obj)
+			}
+		}
+		arr.Clear()
+		arr.Append(elements...)
+	}
+	arr, ok := core.GetArray(config.Get(to))
+	if !ok {
+		arr = core.MakeArray()
+		config.Set(to, arr)
+	}
+	if !containsObject(arr, ocg) {
+		arr.Append(ocg)
+	}
+	return nil
+}
+
+// optionalContentProperties returns the OCProperties dictionary of the catalog, creating it with
+// an empty OCGs array and default configuration if needed.
+func (w *PdfWriter) optionalContentProperties() (*core.PdfObjectDictionary, error) {
+	obj := w.catalog.Get("OCProperties")
+	if obj == nil {
+		ocProperties := core.MakeDict()
+		ocProperties.Set("OCGs", core.MakeArray())
+		ocProperties.Set("D", core.MakeDict())
+		w.catalog.Set("OCProperties", ocProperties)
+		return ocProperties, nil
+	}
+
+	ocProperties, ok := core.GetDict(obj)
+	if !ok {
+		return nil, errors.New("invalid OCProperties (not a dict)")
+	}
+	if ocProperties.Get("OCGs") == nil {
+		ocProperties.Set("OCGs", core.MakeArray())
+	} else if _, ok := core.GetArray(ocProperties.Get("OCGs")); !ok {
+		return nil, errors.New("invalid OCProperties OCGs (not an array)")
+	}
+	if ocProperties.Get("D") == nil {
+		ocProperties.Set("D", core.MakeDict())
+	} else if _, ok := core.GetDict(ocProperties.Get("D")); !ok {
+		return nil, errors.New("invalid OCProperties D (not a dict)")
+	}
+	return ocProperties, nil
+}
+
+// containsObject returns true if the array `arr` contains `obj`, directly or by reference.
+func containsObject(arr *core.PdfObjectArray, obj core.PdfObject) bool {
+	for _, elem := range arr.Elements() {
+		if core.ResolveReference(elem) == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNamedDestinations sets the Names entry in the PDF catalog.
+// See section 12.3.2.3 "Named Destinations" (p. 367 PDF32000_2008).
+func (w *PdfWriter) SetNamedDestinations(names core.PdfObject) error {
+	if names == nil {
+		return nil
+	}
+
+	common.Log.Trace("Setting catalog Names...")
+	w.catalog.Set("Names", names)
+	return w.addObjects(names)
+}
+
+// addNameTreeEntry adds the entry `key`, `value` to the name tree `tree` (e.g. Dests) of the
+// catalog Names dictionary, creating them if needed. The keys of the tree are kept sorted.
+func (w *PdfWriter) addNameTreeEntry(tree core.PdfObjectName, key string, value core.PdfObject) error {
+	names, ok := core.GetDict(w.catalog.Get("Names"))
+	if !ok {
+		names = core.MakeDict()
+		w.catalog.Set("Names", names)
+	}
+	treeDict, ok := core.GetDict(names.Get(tree))
+	if !ok {
+		treeDict = core.MakeDict()
+		names.Set(tree, treeDict)
+	}
+	if treeDict.Get("Kids") != nil {
+		return fmt.Errorf("%s name tree with kids not supported", tree)
+	}
+	treeNames, ok := core.GetArray(treeDict.Get("Names"))
+	if !ok {
+		treeNames = core.MakeArray()
+		treeDict.Set("Names", treeNames)
+	}
+
+	elements := treeNames.Elements()
+	pos := -1
+	for i := 0; i+1 < len(elements); i += 2 {
+		k, ok := core.GetString(elements[i])
+		if !ok {
+			return fmt.Errorf("invalid %s name tree key", tree)
+		}
+		if k.Str() == key {
+			return fmt.Errorf("%s name tree already has %q", tree, key)
+		}
+		if pos < 0 && k.Str() > key {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		pos = len(elements)
+	}
+
+	sorted := append([]core.PdfObject{}, elements[:pos]...)
+	sorted = append(sorted, core.MakeString(key), value)
+	sorted = append(sorted, elements[pos:]...)
+	treeNames.Clear()
+	treeNames.Append(sorted...)
+	return nil
+}
+
+// pageObject returns the indirect object of `page`, which must have been added to the writer.
+func (w *PdfWriter) pageObject(page *PdfPage) (*core.PdfIndirectObject, error) {
+	if page == nil {
+		return nil, errors.New("page is nil")
+	}
+	pageObj, ok := core.GetIndirect(page.GetContainingPdfObject())
+	if !ok || !w.hasObject(pageObj) {
+		return nil, errors.New("page not added to the writer")
+	}
+	return pageObj, nil
+}
+
+// makeXYZDestination returns an explicit destination displaying `pageObj` with the top edge at
+// `top`, keeping the current left position and zoom.
+func makeXYZDestination(pageObj *core.PdfIndirectObject, top float64) *core.PdfObjectArray {
+	return core.MakeArray(pageObj, core.MakeName("XYZ"), core.MakeNull(), core.MakeFloat(top), core.MakeNull())
+}
+
+// AddNamedDestination adds the destination `name` to the Dests name tree of the catalog Names
+// dictionary, displaying `page` with its top edge at `top` (in default user space). The page
+// must have been added with AddPage.
+func (w *PdfWriter) AddNamedDestination(name string, page *PdfPage, top float64) error {
+	if name == "" {
+		return errors.New("destination name must not be empty")
+	}
+	pageObj, err := w.pageObject(page)
+	if err != nil {
+		return err
+	}
+	return w.addNameTreeEntry("Dests", name, makeXYZDestination(pageObj, top))
+}
+
+// AddLinkToDestination adds a link annotation covering `rect` on `page`, which jumps to the named
+// destination `name` (see AddNamedDestination). The page must have been added with AddPage.
+func (w *PdfWriter) AddLinkToDestination(page *PdfPage, rect PdfRectangle, name string) error {
+	if name == "" {
+		return errors.New("destination name must not be empty")
+	}
+	link := NewPdfAnnotationLink()
+	link.Dest = core.MakeString(name)
+	return w.addLink(page, rect, link)
+}
+
+// AddLinkToPage adds a link annotation covering `rect` on `page` with a GoTo action, which
+// displays `target` with its top edge at `top`. Both pages must have been added with AddPage.
+func (w *PdfWriter) AddLinkToPage(page *PdfPage, rect PdfRectangle, target *PdfPage, top float64) error {
+	targetObj, err := w.pageObject(target)
+	if err != nil {
+		return err
+	}
+	action := NewPdfActionGoTo()
+	action.D = makeXYZDestination(targetObj, top)
+	link := NewPdfAnnotationLink()
+	link.A = action.ToPdfObject()
+	return w.addLink(page, rect, link)
+}
+
+// addLink adds the link annotation `link` covering `rect` to the annotations of `page`.
+func (w *PdfWriter) addLink(page *PdfPage, rect PdfRectangle, link *PdfAnnotationLink) error {
+	pageObj, err := w.pageObject(page)
+	if err != nil {
+		return err
+	}
+	pageDict, ok := core.GetDict(pageObj)
+	if !ok {
+		return errors.New("page object should be a dictionary")
+	}
+
+	link.Rect = rect.ToPdfObject()
+	link.Border = core.MakeArray(core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0))
+	link.P = pageObj
+	linkObj := link.ToPdfObject()
+
+	annots, ok := core.GetArray(pageDict.Get("Annots"))
+	if !ok {
+		annots = core.MakeArray()
+		pageDict.Set("Annots", annots)
+	}
+	annots.Append(linkObj)
+	return w.addObjects(linkObj)
+}
+
+// PageLabelStyle is the numbering style of page labels.
+// See section 12.4.2 "Page Labels" (p. 374 PDF32000_2008).
+type PageLabelStyle string
+
+// Page label numbering styles. With PageLabelNone the labels consist of the prefix only.
+const (
+	PageLabelNone             PageLabelStyle = ""
+	PageLabelDecimalArabic    PageLabelStyle = "D"
+	PageLabelUppercaseRoman   PageLabelStyle = "R"
+	PageLabelLowercaseRoman   PageLabelStyle = "r"
+	PageLabelUppercaseLetters PageLabelStyle = "A"
+	PageLabelLowercaseLetters PageLabelStyle = "a"
+)
+
+// PageLabelRange labels the pages from the zero-based page index PageIndex up to the start of
+// the next range.
+type PageLabelRange struct {
+	PageIndex int
+	Style     PageLabelStyle
+	Prefix    string
+
+	// Start is the number of the first page of the range. Defaults to 1 if not set.
+	Start int
+}
+
+// SetPageLabels sets the labels displayed for the pages, e.g. "i, ii, iii" for a preamble
+// followed by "1, 2, ...". One of the `ranges` must start at page index 0. Setting no ranges
+// removes the page labels.
+func (w *PdfWriter) SetPageLabels(ranges []PageLabelRange) error {
+	if len(ranges) == 0 {
+		w.catalog.Remove("PageLabels")
+		return nil
+	}
+
+	sorted := append([]PageLabelRange{}, ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].PageIndex < sorted[j].PageIndex
+	})
+	if sorted[0].PageIndex != 0 {
+		return errors.New("page labels must start at page index 0")
+	}
+
+	nums := core.MakeArray()
+	for i, r := range sorted {
+		if i > 0 && r.PageIndex == sorted[i-1].PageIndex {
+			return fmt.Errorf("multiple page label ranges start at page index %d", r.PageIndex)
+		}
+		if r.Start < 0 {
+			return fmt.Errorf("invalid page label start %d", r.Start)
+		}
+
+		label := core.MakeDict()
+		switch r.Style {
+		case PageLabelNone:
+		case PageLabelDecimalArabic, PageLabelUppercaseRoman, PageLabelLowercaseRoman,
+			PageLabelUppercaseLetters, PageLabelLowercaseLetters:
+			label.Set("S", core.MakeName(string(r.Style)))
+		default:
+			return fmt.Errorf("invalid page label style: %q", r.Style)
+		}
+		if r.Prefix != "" {
+			label.Set("P", makeTextString(r.Prefix))
+		}
+		if r.Start > 1 {
+			label.Set("St", core.MakeInteger(int64(r.Start)))
+		}
+		nums.Append(core.MakeInteger(int64(r.PageIndex)), label)
+	}
+
+	pageLabels := core.MakeDict()
+	pageLabels.Set("Nums", nums)
+	w.catalog.Set("PageLabels", pageLabels)
+	return nil
+}
+
+// AttachFile embeds the file `data` in the document under `name`, listing it in the EmbeddedFiles
+// name tree of the catalog Names dictionary and in the document's associated files (AF), as
+// used by PDF/A-3 e.g. for ZUGFeRD invoices. `mimeType` is optional. Each attached file must have
+// a different name.
+func (w *PdfWriter) AttachFile(name string, data []byte, mimeType string) error {
+	if name == "" {
+		return errors.New("attachment name must not be empty")
+	}
+
+	// Embedded file stream.
+	stream, err := core.MakeStream(data, core.NewFlateEncoder())
+	if err != nil {
+		return err
+	}
+	stream.Set("Type", core.MakeName("EmbeddedFile"))
+	if mimeType != "" {
+		stream.Set("Subtype", core.MakeName(mimeType))
+	}
+	now, err := NewPdfDateFromTime(common.Now())
+	if err != nil {
+		return err
+	}
+	checksum := md5.Sum(data)
+	params := core.MakeDict()
+	params.Set("Size", core.MakeInteger(int64(len(data))))
+	params.Set("CreationDate", now.ToPdfObject())
+	params.Set("ModDate", now.ToPdfObject())
+	params.Set("CheckSum", core.MakeHexString(string(checksum[:])))
+	stream.Set("Params", params)
+
+	// File specification.
+	ef := core.MakeDict()
+	ef.Set("F", stream)
+	ef.Set("UF", stream)
+	filespec := NewPdfFilespec()
+	filespec.F = core.MakeString(name)
+	filespec.UF = core.MakeEncodedString(name, true)
+	filespec.EF = ef
+	filespecObj := filespec.ToPdfObject()
+	if dict, ok := core.GetDict(filespecObj); ok {
+		dict.Set("AFRelationship", core.MakeName("Unspecified"))
+	}
+
+	if err := w.addNameTreeEntry("EmbeddedFiles", name, filespecObj); err != nil {
+		return err
+	}
+
+	// Associated files.
+	af, ok := core.GetArray(w.catalog.Get("AF"))
+	if !ok {
+		af = core.MakeArray()
+		w.catalog.Set("AF", af)
+	}
+	af.Append(filespecObj)
+
+	return w.addObjects(filespecObj)
 }
 
-// SetVersion sets the PDF version of the output file.
-func (w *PdfWriter) SetVersion(majorVersion, minorVersion int) {
-	w.majorVersion = majorVersion
-	w.minorVersion = minorVersion
+// SetUseCrossReferenceStream sets whether a cross reference stream is written instead of a
+// classic cross reference table and trailer. By default, a stream is used for PDF 1.5 and above.
+// Forcing a stream for lower versions raises the output version to 1.5. A stream is always
+// used when objects are compressed in object streams.
+func (w *PdfWriter) SetUseCrossReferenceStream(useStream bool) {
+	w.useCrossReferenceStream = &useStream
 }
 
-// SetOCProperties sets the optional content properties.
-func (w *PdfWriter) SetOCProperties(ocProperties core.PdfObject) error {
-	dict := w.catalog
+// SetObjectStreamMode sets whether the indirect objects are compressed into object streams
+// (/Type /ObjStm) when writing. Streams, the encryption dictionary, signature dictionaries and
+// objects with a non-zero generation number are not compressed. Object streams require a
+// cross reference stream, so the output version is raised to 1.5 if lower. Disabled by default.
+func (w *PdfWriter) SetObjectStreamMode(enabled bool) {
+	w.objectStreamMode = enabled
+}
 
-	if ocProperties != nil {
-		common.Log.Trace("Setting OC Properties...")
-		dict.Set("OCProperties", ocProperties)
-		// Any risk of infinite loops?
-		return w.addObjects(ocProperties)
-	}
+// SetMaxObjectsPerStream sets the maximum number of objects compressed into each object stream
+// when the object stream mode is enabled. Values <= 0 select the default of 100 objects.
+func (w *PdfWriter) SetMaxObjectsPerStream(max int) {
+	w.maxObjectsPerStream = max
+}
 
-	return nil
+// SetCompressStreams sets whether streams without a /Filter are compressed with FlateDecode when
+// writing. Streams that already specify a filter, metadata streams and streams that do not get
+// smaller are written as they are. Disabled by default.
+func (w *PdfWriter) SetCompressStreams(compress bool) {
+	w.compressStreams = compress
 }
 
-// SetNamedDestinations sets the Names entry in the PDF catalog.
-// See section 12.3.2.3 "Named Destinations" (p. 367 PDF32000_2008).
-func (w *PdfWriter) SetNamedDestinations(names core.PdfObject) error {
-	if names == nil {
-		return nil
+// SetStreamEncoder sets the encoder with which `stream` is encoded when writing, e.g. a
+// core.LZWEncoder or core.RunLengthEncoder, setting the Filter, DecodeParms and Length of the
+// written stream. The data of `stream` is expected to be unencoded, unless its Filter already is
+// the filter of `encoder`, in which case it is written as it is, so that it is not encoded twice.
+// Streams with other filters cannot be encoded. A nil `encoder` clears the
+// encoder of `stream`.
+func (w *PdfWriter) SetStreamEncoder(stream *core.PdfObjectStream, encoder core.StreamEncoder) {
+	if encoder == nil {
+		delete(w.streamEncoders, stream)
+		return
+	}
+	if w.streamEncoders == nil {
+		w.streamEncoders = map[*core.PdfObjectStream]core.StreamEncoder{}
 	}
+	w.streamEncoders[stream] = encoder
+}
 
-	common.Log.Trace("Setting catalog Names...")
-	w.catalog.Set("Names", names)
-	return w.addObjects(names)
+// SetHybridCrossReference sets whether a hybrid-reference file is written when the output uses a
+// cross reference stream, e.g. when objects are compressed into object streams. In addition to
+// the cross reference stream, a hybrid-reference file contains a classic cross reference table
+// for the objects that are not in object streams, so that PDF 1.4 readers can still open it.
+// Disabled by default.
+func (w *PdfWriter) SetHybridCrossReference(hybrid bool) {
+	w.hybridCrossReference = hybrid
+}
+
+// SetPreserveObjectNumbers sets whether the objects that already have an object number, e.g.
+// the objects loaded by a PdfReader, keep their object and generation numbers when writing, so
+// that references into the original document remain valid. The other objects are numbered with
+// the lowest free object numbers. If several objects have the same number, the one added first
+// keeps it. Disabled by default, in which case all objects are numbered sequentially with
+// generation number 0. Objects with a non-zero generation number are not compressed into object
+// streams.
+func (w *PdfWriter) SetPreserveObjectNumbers(preserve bool) {
+	w.preserveObjectNumbers = preserve
 }
 
 // SetOptimizer sets the optimizer to optimize PDF before writing.
@@ -448,6 +1394,9 @@ func (w *PdfWriter) addObject(obj core.PdfObject) bool {
 
 		w.objects = append(w.objects, obj)
 		w.objectsMap[obj] = struct{}{}
+		if w.streamOutput != nil {
+			w.numberStreamObject(obj)
+		}
 		return true
 	}
 
@@ -510,7 +1459,7 @@ func (w *PdfWriter) addObjects(obj core.PdfObject) error {
 					// Should be done by the reader already.
 					// -> ERROR.
 					common.Log.Debug("ERROR: Parent is a reference object - Cannot be in writer (needs to be resolved)")
-					return fmt.Errorf("parent is a reference object - Cannot be in writer (needs to be resolved) - %s", parentObj)
+					return fmt.Errorf("%w (needs to be resolved): %s", ErrParentIsReference, parentObj)
 				}
 			}
 		}
@@ -535,7 +1484,7 @@ func (w *PdfWriter) addObjects(obj core.PdfObject) error {
 	if _, isReference := obj.(*core.PdfObjectReference); isReference {
 		// Should never be a reference, should already be resolved.
 		common.Log.Debug("ERROR: Cannot be a reference - got %#v!", obj)
-		return errors.New("reference not allowed")
+		return fmt.Errorf("%w: %s", ErrReferenceInWriter, obj)
 	}
 
 	return nil
@@ -543,7 +1492,74 @@ func (w *PdfWriter) addObjects(obj core.PdfObject) error {
 
 // AddPage adds a page to the PDF file. The new page should be an indirect object.
 func (w *PdfWriter) AddPage(page *PdfPage) error {
+	if w.streamOutput != nil {
+		return w.streamPage(page)
+	}
+	return w.addPage(page, -1)
+}
+
+// InsertPage inserts a page into the PDF file at the zero-based position `index`, e.g. 0 makes
+// it the first page. Negative indices insert the page first and indices past the last page
+// append it, as AddPage does. The new page should be an indirect object.
+// Pages cannot be inserted with stream writers (see NewPdfStreamWriter).
+func (w *PdfWriter) InsertPage(index int, page *PdfPage) error {
+	if w.streamOutput != nil {
+		return errors.New("pages cannot be inserted when streaming")
+	}
+	if index < 0 {
+		index = 0
+	}
+	return w.addPage(page, index)
+}
+
+// AddPagesFrom adds the pages of `reader` with the page numbers in `pageRange` (starting at 1),
+// in that order, or all its pages if `pageRange` is nil. Indirect objects shared by the pages,
+// such as fonts and images, are written only once. When all the pages are added and no outline
+// tree has been set on the writer, the outline tree of `reader` is carried over.
+func (w *PdfWriter) AddPagesFrom(reader *PdfReader, pageRange []int) error {
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	copyOutlines := pageRange == nil
+	if copyOutlines {
+		pageRange = make([]int, numPages)
+		for i := range pageRange {
+			pageRange[i] = i + 1
+		}
+	}
+
+	for _, pageNum := range pageRange {
+		if pageNum < 1 || pageNum > numPages {
+			return fmt.Errorf("page number %d out of range (1-%d)", pageNum, numPages)
+		}
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+		if err := w.AddPage(page); err != nil {
+			return err
+		}
+	}
+
+	if copyOutlines && w.outlineTree == nil {
+		if outlineTree := reader.GetOutlineTree(); outlineTree != nil {
+			w.AddOutlineTree(outlineTree)
+		}
+	}
+	return nil
+}
+
+// addPage adds `page` to the page tree at position `index`, or appends it if `index` is negative
+// or past the last page.
+func (w *PdfWriter) addPage(page *PdfPage, index int) error {
 	procPage(page)
+	if w.stamp != nil {
+		if err := w.stamp.apply(page); err != nil {
+			return err
+		}
+	}
 	obj := page.ToPdfObject()
 
 	common.Log.Trace("==========")
@@ -551,23 +1567,23 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 
 	pageObj, ok := core.GetIndirect(obj)
 	if !ok {
-		return errors.New("page should be an indirect object")
+		return fmt.Errorf("%w: %T", ErrPageNotIndirect, obj)
 	}
 	common.Log.Trace("%s", pageObj)
 	common.Log.Trace("%s", pageObj.PdfObject)
 
 	pDict, ok := core.GetDict(pageObj.PdfObject)
 	if !ok {
-		return errors.New("page object should be a dictionary")
+		return fmt.Errorf("%w: page object should be a dictionary", ErrInvalidPage)
 	}
 
 	otype, ok := core.GetName(pDict.Get("Type"))
 	if !ok {
-		return fmt.Errorf("page should have a Type key with a value of type name (%T)", pDict.Get("Type"))
-
+		return fmt.Errorf("%w: page should have a Type key with a value of type name (%T)",
+			ErrInvalidPage, pDict.Get("Type"))
 	}
 	if otype.String() != "Page" {
-		return errors.New("field Type != Page (Required)")
+		return fmt.Errorf("%w: field Type != Page (Required)", ErrInvalidPage)
 	}
 
 	// Copy inherited fields if missing.
@@ -578,7 +1594,7 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 		common.Log.Trace("Page Parent: %T", parent)
 		parentDict, ok := core.GetDict(parent.PdfObject)
 		if !ok {
-			return errors.New("invalid Parent object")
+			return fmt.Errorf("%w: invalid Parent object", ErrInvalidPage)
 		}
 		for _, field := range inheritedFields {
 			common.Log.Trace("Field %s", field)
@@ -613,7 +1629,15 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 	if !ok {
 		return errors.New("invalid Pages Kids obj (not an array)")
 	}
-	kids.Append(pageObj)
+	if index < 0 || index >= kids.Len() {
+		kids.Append(pageObj)
+	} else {
+		elements := append([]core.PdfObject{}, kids.Elements()[:index]...)
+		elements = append(elements, pageObj)
+		elements = append(elements, kids.Elements()[index:]...)
+		kids.Clear()
+		kids.Append(elements...)
+	}
 	pageCount, ok := core.GetInt(pagesDict.Get("Count"))
 	if !ok {
 		return errors.New("invalid Pages Count object (not an integer)")
@@ -708,19 +1732,32 @@ func (w *PdfWriter) seekByName(obj core.PdfObject, followKeys []string, key stri
 	return list, nil
 }
 
+// Warnings returns the recoverable problems found while writing, such as objects that were
+// never added for writing and were replaced with null (ErrObjectReplacedWithNull).
+func (w *PdfWriter) Warnings() []error {
+	return w.warnings
+}
+
 // SetForms sets the Acroform for a PDF file.
 func (w *PdfWriter) SetForms(form *PdfAcroForm) error {
 	w.acroForm = form
 	return nil
 }
 
+// SetSigFlags sets the SigFlags entry of the AcroForm written to the output file, declaring
+// whether the document contains signatures and whether it should only be updated incrementally.
+// The flags are applied to the form set with SetForms when writing.
+func (w *PdfWriter) SetSigFlags(flags SigFlag) {
+	w.sigFlags = &flags
+}
+
 // writeObject writes out an indirect / stream object.
 func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 	common.Log.Trace("Write obj #%d\n", num)
 
 	if pobj, isIndirect := obj.(*core.PdfIndirectObject); isIndirect {
 		w.crossReferenceMap[num] = crossReference{Type: 1, Offset: w.writePos, Generation: pobj.GenerationNumber}
-		outStr := fmt.Sprintf("%d 0 obj\n", num)
+		outStr := fmt.Sprintf("%d %d obj\n", num, pobj.GenerationNumber)
 		if sDict, ok := pobj.PdfObject.(*pdfSignDictionary); ok {
 			sDict.fileOffset = w.writePos + int64(len(outStr))
 		}
@@ -730,15 +1767,16 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 		}
 		outStr += pobj.PdfObject.WriteString()
 		outStr += "\nendobj\n"
+		if sDict, ok := pobj.PdfObject.(*pdfSignDictionary); ok && sDict.handle != nil {
+			sDict.handle.setOffsets(sDict)
+		}
 		w.writeString(outStr)
 		return
 	}
 
-	// TODO: Add a default encoder if Filter not specified?
-	// Still need to make sure is encrypted.
 	if pobj, isStream := obj.(*core.PdfObjectStream); isStream {
 		w.crossReferenceMap[num] = crossReference{Type: 1, Offset: w.writePos, Generation: pobj.GenerationNumber}
-		outStr := fmt.Sprintf("%d 0 obj\n", num)
+		outStr := fmt.Sprintf("%d %d obj\n", num, pobj.GenerationNumber)
 		outStr += pobj.PdfObjectDictionary.WriteString()
 		outStr += "\nstream\n"
 		w.writeString(outStr)
@@ -778,6 +1816,15 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 		dict.Set(core.PdfObjectName("First"), core.MakeInteger(first))
 
 		data, _ := encoder.EncodeBytes([]byte(offsetsStr + objData))
+		// The objects in the object stream are encrypted together with the stream data.
+		if w.crypter != nil {
+			stream := &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: data}
+			stream.ObjectNumber, stream.GenerationNumber = int64(num), ostreams.GenerationNumber
+			if err := w.crypter.Encrypt(stream, stream.ObjectNumber, stream.GenerationNumber); err != nil {
+				common.Log.Debug("ERROR: Failed encrypting object stream (%s)", err)
+			}
+			data = stream.Stream
+		}
 		length := int64(len(data))
 
 		dict.Set(core.PdfObjectName("Length"), core.MakeInteger(length))
@@ -794,6 +1841,78 @@ func (w *PdfWriter) writeObject(num int, obj core.PdfObject) {
 
 // Update all the object numbers prior to writing.
 func (w *PdfWriter) updateObjectNumbers() {
+	if w.preserveObjectNumbers && !w.appendMode || w.streamOutput != nil {
+		w.numberNewObjects()
+	} else {
+		w.renumberObjects()
+	}
+
+	getObjNum := func(obj core.PdfObject) int64 {
+		switch o := obj.(type) {
+		case *core.PdfIndirectObject:
+			return o.ObjectNumber
+		case *core.PdfObjectStream:
+			return o.ObjectNumber
+		case *core.PdfObjectStreams:
+			return o.ObjectNumber
+		}
+		return 0
+	}
+	// Sort the output by object numbers so that they appear in descending order.
+	sort.SliceStable(w.objects, func(i, j int) bool {
+		return getObjNum(w.objects[i]) < getObjNum(w.objects[j])
+	})
+}
+
+// objectReference returns the object and generation numbers of the indirect object `obj`, or
+// nil if it is not an indirect object.
+func objectReference(obj core.PdfObject) *core.PdfObjectReference {
+	switch o := obj.(type) {
+	case *core.PdfIndirectObject:
+		return &o.PdfObjectReference
+	case *core.PdfObjectStream:
+		return &o.PdfObjectReference
+	case *core.PdfObjectStreams:
+		return &o.PdfObjectReference
+	}
+	return nil
+}
+
+// numberNewObjects numbers the objects that have no object number yet with the lowest free
+// object numbers, leaving the object and generation numbers of the other objects as they are.
+// If several objects have the same object number, only the first one keeps it.
+func (w *PdfWriter) numberNewObjects() {
+	used := make(map[int64]struct{}, len(w.objects))
+	var unnumbered []*core.PdfObjectReference
+	for _, obj := range w.objects {
+		ref := objectReference(obj)
+		if ref == nil {
+			common.Log.Debug("ERROR: Unknown type %T - skipping", obj)
+			continue
+		}
+		if _, has := used[ref.ObjectNumber]; ref.ObjectNumber > 0 && !has {
+			used[ref.ObjectNumber] = struct{}{}
+			continue
+		}
+		unnumbered = append(unnumbered, ref)
+	}
+
+	objNum := int64(w.ObjNumOffset + 1)
+	for _, ref := range unnumbered {
+		for {
+			if _, has := used[objNum]; !has {
+				break
+			}
+			objNum++
+		}
+		ref.ObjectNumber = objNum
+		ref.GenerationNumber = 0
+		objNum++
+	}
+}
+
+// renumberObjects numbers all the objects sequentially with generation number 0.
+func (w *PdfWriter) renumberObjects() {
 	offset := w.ObjNumOffset
 
 	// Update numbers
@@ -827,28 +1946,19 @@ func (w *PdfWriter) updateObjectNumbers() {
 			i++
 		}
 	}
-
-	getObjNum := func(obj core.PdfObject) int64 {
-		switch o := obj.(type) {
-		case *core.PdfIndirectObject:
-			return o.ObjectNumber
-		case *core.PdfObjectStream:
-			return o.ObjectNumber
-		case *core.PdfObjectStreams:
-			return o.ObjectNumber
-		}
-		return 0
-	}
-	// Sort the output by object numbers so that they appear in descending order.
-	sort.SliceStable(w.objects, func(i, j int) bool {
-		return getObjNum(w.objects[i]) < getObjNum(w.objects[j])
-	})
 }
 
 // EncryptOptions represents encryption options for an output PDF.
 type EncryptOptions struct {
+	// Permissions are the access permissions granted to users opening the document with the
+	// user password, e.g. security.AccessPermissions{AllowPrinting: true}.Permissions().
+	// The reserved bits of the /P value are set when encrypting.
 	Permissions security.Permissions
 	Algorithm   EncryptionAlgorithm
+
+	// UnencryptedMetadata leaves the document metadata stream unencrypted (EncryptMetadata
+	// false), e.g. so that it can be indexed without the password. Requires an AES algorithm.
+	UnencryptedMetadata bool
 }
 
 // EncryptionAlgorithm is used in EncryptOptions to change the default algorithm used to encrypt the document.
@@ -863,15 +1973,43 @@ const (
 	AES_256bit
 )
 
+// SetDocumentID sets the permanent (`id0`) and changing (`id1`) identifiers of the output
+// document, written as the /ID entry of the trailer. By default the identifiers are only
+// generated when encrypting, from the current time and random data. As the encryption key is
+// derived from the first identifier, SetDocumentID must be called before Encrypt.
+// Fixed identifiers make unencrypted and RC4 encrypted output reproducible, whereas AES
+// encryption still uses random initialization vectors.
+func (w *PdfWriter) SetDocumentID(id0, id1 []byte) error {
+	if len(id0) == 0 || len(id1) == 0 {
+		return errors.New("document ID must not be empty")
+	}
+	if w.crypter != nil {
+		return errors.New("document ID must be set before encrypting")
+	}
+	w.documentID = [2]string{string(id0), string(id1)}
+	w.ids = core.MakeArray(core.MakeHexString(w.documentID[0]), core.MakeHexString(w.documentID[1]))
+	return nil
+}
+
 // Encrypt encrypts the output file with a specified user/owner password.
+// Stream writers (see NewPdfStreamWriter) do not support encryption.
 func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions) error {
+	if w.streamOutput != nil {
+		return errors.New("stream writers do not support encryption")
+	}
 	algo := RC4_128bit
 	if options != nil {
 		algo = options.Algorithm
 	}
 	perm := security.PermOwner
+	encryptMetadata := true
 	if options != nil {
-		perm = options.Permissions
+		perm = options.Permissions.WithReservedBits()
+		encryptMetadata = !options.UnencryptedMetadata
+	}
+	// The XMP metadata set with SetXMPMetadata stays readable without the password.
+	if w.xmpMetadata {
+		encryptMetadata = false
 	}
 
 	var cf crypt.Filter
@@ -885,7 +2023,11 @@ func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions)
 	default:
 		return fmt.Errorf("unsupported algorithm: %v", options.Algorithm)
 	}
-	crypter, info, err := core.PdfCryptNewEncrypt(cf, userPass, ownerPass, perm)
+	crypter, info, err := core.PdfCryptNewEncryptWithOptions(cf, userPass, ownerPass, perm, &core.CryptOptions{
+		UnencryptedMetadata: !encryptMetadata,
+		ID0:                 w.documentID[0],
+		ID1:                 w.documentID[1],
+	})
 	if err != nil {
 		return err
 	}
@@ -927,7 +2069,28 @@ func (w *PdfWriter) writeBytes(bb []byte) error {
 
 // Write writes out the PDF.
 func (w *PdfWriter) Write(writer io.Writer) error {
+	return w.WriteContext(context.Background(), writer)
+}
+
+// WriteContext writes out the PDF like Write, but stops and returns the context error
+// if `ctx` is cancelled or expires while the objects are being written out.
+// Stream writers (see NewPdfStreamWriter) are finished with Close instead.
+func (w *PdfWriter) WriteContext(ctx context.Context, writer io.Writer) error {
+	if w.streamOutput != nil {
+		return errors.New("stream writers are written with Close")
+	}
+	if w.signature != nil {
+		return w.writeSignaturePlaceholder(ctx, writer)
+	}
+	return w.write(ctx, writer)
+}
+
+// write writes out the PDF to `writer`.
+func (w *PdfWriter) write(ctx context.Context, writer io.Writer) error {
 	common.Log.Trace("Write()")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	lk := license.GetLicenseKey()
 	if lk == nil || !lk.IsLicensed() {
@@ -948,9 +2111,21 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	}
 
 	// Form fields.
+	if w.sigFlags != nil {
+		if w.acroForm != nil {
+			w.acroForm.SigFlags = core.MakeInteger(int64(*w.sigFlags))
+		} else {
+			common.Log.Debug("WARN: SigFlags set without a form - ignoring")
+		}
+	}
 	if w.acroForm != nil {
 		common.Log.Trace("Writing acro forms")
 		indObj := w.acroForm.ToPdfObject()
+		if dict, ok := core.GetDict(indObj); ok {
+			if err := w.acroForm.setDefaultAppearanceResources(dict); err != nil {
+				return err
+			}
+		}
 		common.Log.Trace("AcroForm: %+v", indObj)
 		w.catalog.Set("AcroForm", indObj)
 		err := w.addObjects(indObj)
@@ -969,6 +2144,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 					if val == pendingObj {
 						common.Log.Debug("Pending object found! and replaced with null")
 						pendingObjDict.Set(key, core.MakeNull())
+						w.warnings = append(w.warnings,
+							fmt.Errorf("%w: %s entry (%T)", ErrObjectReplacedWithNull, key, pendingObj))
 						break
 					}
 				}
@@ -978,6 +2155,11 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	// Set version in the catalog.
 	w.catalog.Set("Version", core.MakeName(fmt.Sprintf("%d.%d", w.majorVersion, w.minorVersion)))
 
+	// Only write the new and changed objects of incremental updates.
+	if w.incremental != nil {
+		w.selectIncrementalObjects()
+	}
+
 	// Make a copy of objects prior to optimizing as this can alter the objects.
 	// TODO: Copying wastes memory. Might be worth making user responsible for handling properly.
 	//       Is copy needed for optimization?
@@ -996,6 +2178,20 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		w.objectsMap = objMap
 	}
 
+	// Encode and compress prior to encrypting the streams.
+	if err := w.encodeObjectStreams(); err != nil {
+		return err
+	}
+	if w.compressStreams {
+		if err := w.compressObjectStreams(); err != nil {
+			return err
+		}
+	}
+
+	if w.objectStreamMode && !w.appendMode {
+		w.objects = w.packObjectStreams(w.objects)
+	}
+
 	w.writePos = w.writeOffset
 	w.writer = bufio.NewWriter(writer)
 	useCrossReferenceStream := w.majorVersion > 1 || (w.majorVersion == 1 && w.minorVersion > 4)
@@ -1024,7 +2220,7 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 
 	if w.appendMode {
 		w.writeString("\n")
-	} else {
+	} else if w.streamOutput == nil {
 		w.writeString(fmt.Sprintf("%%PDF-%d.%d\n", w.majorVersion, w.minorVersion))
 		w.writeString("%âãÏÓ\n")
 	}
@@ -1033,8 +2229,10 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 
 	// Write objects
 	common.Log.Trace("Writing %d obj", len(w.objects))
-	w.crossReferenceMap = make(map[int]crossReference)
-	w.crossReferenceMap[0] = crossReference{Type: 0, ObjectNumber: 0, Generation: 0xFFFF}
+	if w.streamOutput == nil {
+		w.crossReferenceMap = make(map[int]crossReference)
+		w.crossReferenceMap[0] = crossReference{Type: 0, ObjectNumber: 0, Generation: 0xFFFF}
+	}
 	if w.appendToXrefs.ObjectMap != nil {
 		for idx, xref := range w.appendToXrefs.ObjectMap {
 			if idx == 0 {
@@ -1053,18 +2251,21 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 
 	// Write out indirect/stream objects that are not in object streams.
 	for _, obj := range w.objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if skip := objectsInObjectStreams[obj]; skip {
 			continue
 		}
 
-		objectNumber := int64(0)
+		var objectNumber, generationNumber int64
 		switch t := obj.(type) {
 		case *core.PdfIndirectObject:
-			objectNumber = t.ObjectNumber
+			objectNumber, generationNumber = t.ObjectNumber, t.GenerationNumber
 		case *core.PdfObjectStream:
-			objectNumber = t.ObjectNumber
+			objectNumber, generationNumber = t.ObjectNumber, t.GenerationNumber
 		case *core.PdfObjectStreams:
-			objectNumber = t.ObjectNumber
+			objectNumber, generationNumber = t.ObjectNumber, t.GenerationNumber
 		default:
 			common.Log.Debug("ERROR: Unsupported type in writer objects: %T", obj)
 			return ErrTypeCheck
@@ -1073,7 +2274,7 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// Encrypt prior to writing.
 		// Encrypt dictionary should not be encrypted.
 		if w.crypter != nil && obj != w.encryptObj {
-			err := w.crypter.Encrypt(obj, int64(objectNumber), 0)
+			err := w.crypter.Encrypt(obj, objectNumber, generationNumber)
 			if err != nil {
 				common.Log.Debug("ERROR: Failed encrypting (%s)", err)
 				return err
@@ -1089,12 +2290,16 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 			maxIndex = idx
 		}
 	}
+	if !w.appendMode {
+		w.linkFreeEntries(maxIndex)
+	}
 
 	// Write trailer / cross reference stream (depending on which used).
 	if useCrossReferenceStream {
 		crossObjNumber := maxIndex + 1
 		w.crossReferenceMap[crossObjNumber] = crossReference{Type: 1, ObjectNumber: crossObjNumber, Offset: xrefOffset}
 		crossReferenceData := bytes.NewBuffer(nil)
+		widths := w.xrefFieldWidths()
 
 		index := core.MakeArray()
 		for idx := 0; idx <= maxIndex; {
@@ -1118,19 +2323,9 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 
 			for k := idx; k < j; k++ {
 				ref := w.crossReferenceMap[k]
-				switch ref.Type {
-				case 0:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(0))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(0))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(0xFFFF))
-				case 1:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(1))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(ref.Offset))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(ref.Generation))
-				case 2:
-					binary.Write(crossReferenceData, binary.BigEndian, byte(2))
-					binary.Write(crossReferenceData, binary.BigEndian, uint32(ref.ObjectNumber))
-					binary.Write(crossReferenceData, binary.BigEndian, uint16(ref.Index))
+				fields := ref.streamFields()
+				for f, width := range widths {
+					putXrefField(crossReferenceData, fields[f], width)
 				}
 			}
 
@@ -1143,7 +2338,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		}
 		crossReferenceStream.ObjectNumber = int64(crossObjNumber)
 		crossReferenceStream.PdfObjectDictionary.Set("Type", core.MakeName("XRef"))
-		crossReferenceStream.PdfObjectDictionary.Set("W", core.MakeArray(core.MakeInteger(1), core.MakeInteger(4), core.MakeInteger(2)))
+		crossReferenceStream.PdfObjectDictionary.Set("W", core.MakeArray(core.MakeInteger(int64(widths[0])),
+			core.MakeInteger(int64(widths[1])), core.MakeInteger(int64(widths[2]))))
 		crossReferenceStream.PdfObjectDictionary.Set("Index", index)
 		crossReferenceStream.PdfObjectDictionary.Set("Size", core.MakeInteger(int64(crossObjNumber+1)))
 		crossReferenceStream.PdfObjectDictionary.Set("Info", w.infoObj)
@@ -1154,73 +2350,198 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// If encrypted!
 		if w.crypter != nil {
 			crossReferenceStream.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
 			crossReferenceStream.Set("ID", w.ids)
 			common.Log.Trace("Ids: %s", w.ids)
 		}
 
 		w.writeObject(int(crossReferenceStream.ObjectNumber), crossReferenceStream)
+
+		// In hybrid-reference files, the cross reference stream is followed by a classic table
+		// for the objects not in object streams, which refers to the stream via XRefStm.
+		if w.hybridCrossReference {
+			xrefStmOffset := xrefOffset
+			xrefOffset = w.writePos
+			w.writeCrossReferenceTable(crossObjNumber, xrefStmOffset)
+		}
 	} else {
-		w.writeString("xref\r\n")
-		for idx := 0; idx <= maxIndex; {
-			// Find next to write.
-			for ; idx <= maxIndex; idx++ {
-				ref, has := w.crossReferenceMap[idx]
-				if has && (!w.appendMode || w.appendMode && (ref.Type == 1 && ref.Offset >= w.appendPrevRevisionSize || ref.Type == 0)) {
-					break
-				}
-			}
+		w.writeCrossReferenceTable(maxIndex, 0)
+	}
 
-			var j int
-			for j = idx + 1; j <= maxIndex; j++ {
-				ref, has := w.crossReferenceMap[j]
-				if has && (!w.appendMode || w.appendMode && (ref.Type == 1 && ref.Offset > w.appendPrevRevisionSize)) {
-					continue
-				}
-				break
-			}
+	// Make offset reference.
+	outStr := fmt.Sprintf("startxref\n%d\n", xrefOffset)
+	w.writeString(outStr)
+	w.writeString("%%EOF\n")
 
-			outStr := fmt.Sprintf("%d %d\r\n", idx, j-idx)
-			w.writeString(outStr)
-			for k := idx; k < j; k++ {
-				ref := w.crossReferenceMap[k]
-				switch ref.Type {
-				case 0:
-					outStr = fmt.Sprintf("%.10d %.5d f\r\n", 0, 65535)
-					w.writeString(outStr)
-				case 1:
-					outStr = fmt.Sprintf("%.10d %.5d n\r\n", ref.Offset, 0)
-					w.writeString(outStr)
-				}
+	w.writer.Flush()
+
+	return nil
+}
+
+// defaultMaxObjectsPerStream is the default maximum number of objects in an object stream.
+const defaultMaxObjectsPerStream = 100
+
+// packObjectStreams returns `objects` with the indirect objects that can be compressed grouped into
+// object streams. Each object stream is followed by the objects it contains, so that they are
+// numbered after it.
+func (w *PdfWriter) packObjectStreams(objects []core.PdfObject) []core.PdfObject {
+	maxObjects := w.maxObjectsPerStream
+	if maxObjects <= 0 {
+		maxObjects = defaultMaxObjectsPerStream
+	}
+
+	// Objects already in object streams, e.g. by an optimizer, are left as they are.
+	compressed := make(map[core.PdfObject]struct{})
+	for _, obj := range objects {
+		if objStm, ok := obj.(*core.PdfObjectStreams); ok {
+			for _, o := range objStm.Elements() {
+				compressed[o] = struct{}{}
 			}
+		}
+	}
 
-			idx = j + 1
+	var packed, others []core.PdfObject
+	var objStm *core.PdfObjectStreams
+	for _, obj := range objects {
+		ind, ok := obj.(*core.PdfIndirectObject)
+		if _, isCompressed := compressed[obj]; !ok || isCompressed || ind.GenerationNumber != 0 || ind == w.encryptObj {
+			others = append(others, obj)
+			continue
+		}
+		// The signature dictionary is updated in place once written, so must not be compressed.
+		if _, isSig := ind.PdfObject.(*pdfSignDictionary); isSig {
+			others = append(others, obj)
+			continue
+		}
+		if objStm == nil || objStm.Len() >= maxObjects {
+			objStm = &core.PdfObjectStreams{}
+			packed = append(packed, objStm)
 		}
+		objStm.Append(obj)
+		packed = append(packed, obj)
+	}
 
-		// Generate & write trailer
-		trailer := core.MakeDict()
-		trailer.Set("Info", w.infoObj)
-		trailer.Set("Root", w.root)
-		trailer.Set("Size", core.MakeInteger(int64(maxIndex+1)))
-		if w.appendMode && w.appendXrefPrevOffset > 0 {
-			trailer.Set("Prev", core.MakeInteger(w.appendXrefPrevOffset))
+	packed = append(packed, others...)
+	objectsMap := make(map[core.PdfObject]struct{}, len(packed))
+	for _, obj := range packed {
+		objectsMap[obj] = struct{}{}
+	}
+	w.objectsMap = objectsMap
+	return packed
+}
+
+// xrefFieldWidths returns the number of bytes needed for each of the fields of the entries in
+// the cross reference stream, i.e. the /W array.
+func (w *PdfWriter) xrefFieldWidths() [3]int {
+	widths := [3]int{1, 1, 1}
+	for _, ref := range w.crossReferenceMap {
+		for f, v := range ref.streamFields() {
+			n := 1
+			for v >>= 8; v > 0; v >>= 8 {
+				n++
+			}
+			if n > widths[f] {
+				widths[f] = n
+			}
 		}
-		// If encrypted!
-		if w.crypter != nil {
-			trailer.Set("Encrypt", w.encryptObj)
-			trailer.Set("ID", w.ids)
-			common.Log.Trace("Ids: %s", w.ids)
+	}
+	return widths
+}
+
+// putXrefField writes `v` to `buf` as a big-endian integer of `width` bytes.
+func putXrefField(buf *bytes.Buffer, v int64, width int) {
+	for i := width - 1; i >= 0; i-- {
+		buf.WriteByte(byte(v >> (8 * uint(i))))
+	}
+}
+
+// linkFreeEntries adds free entries for the object numbers up to `maxIndex` that are not in use,
+// e.g. after objects have been removed, and links all the free entries into the free list that
+// starts at object 0. Each free entry refers to the object number of the next one, and the last
+// one refers back to object 0.
+func (w *PdfWriter) linkFreeEntries(maxIndex int) {
+	last := 0
+	for idx := 1; idx <= maxIndex; idx++ {
+		if ref, has := w.crossReferenceMap[idx]; has && ref.Type != 0 {
+			continue
 		}
-		w.writeString("trailer\n")
-		w.writeString(trailer.WriteString())
-		w.writeString("\n")
+		prev := w.crossReferenceMap[last]
+		prev.ObjectNumber = idx
+		w.crossReferenceMap[last] = prev
+		// The generation number to use if the object number is reused.
+		w.crossReferenceMap[idx] = crossReference{Type: 0, Generation: 1}
+		last = idx
 	}
+	ref := w.crossReferenceMap[last]
+	ref.ObjectNumber = 0
+	w.crossReferenceMap[last] = ref
+}
 
-	// Make offset reference.
-	outStr := fmt.Sprintf("startxref\n%d\n", xrefOffset)
-	w.writeString(outStr)
-	w.writeString("%%EOF\n")
+// writeCrossReferenceTable writes a classic cross reference table with the entries of objects
+// numbered up to `maxIndex`, followed by the trailer. Objects in object streams cannot be
+// represented in a table and are omitted. If `xrefStmOffset` is positive, the trailer refers to
+// the cross reference stream at that offset as in hybrid-reference files.
+func (w *PdfWriter) writeCrossReferenceTable(maxIndex int, xrefStmOffset int64) {
+	w.writeString("xref\r\n")
+	for idx := 0; idx <= maxIndex; {
+		// Find next to write.
+		for ; idx <= maxIndex; idx++ {
+			ref, has := w.crossReferenceMap[idx]
+			if has && ref.Type != 2 && (!w.appendMode || w.appendMode && (ref.Type == 1 && ref.Offset >= w.appendPrevRevisionSize || ref.Type == 0)) {
+				break
+			}
+		}
+		if idx > maxIndex {
+			break
+		}
 
-	w.writer.Flush()
+		var j int
+		for j = idx + 1; j <= maxIndex; j++ {
+			ref, has := w.crossReferenceMap[j]
+			if has && ref.Type != 2 && (!w.appendMode || w.appendMode && (ref.Type == 1 && ref.Offset > w.appendPrevRevisionSize)) {
+				continue
+			}
+			break
+		}
 
-	return nil
+		outStr := fmt.Sprintf("%d %d\r\n", idx, j-idx)
+		w.writeString(outStr)
+		for k := idx; k < j; k++ {
+			ref := w.crossReferenceMap[k]
+			switch ref.Type {
+			case 0:
+				outStr = fmt.Sprintf("%.10d %.5d f\r\n", ref.ObjectNumber, ref.Generation)
+				w.writeString(outStr)
+			case 1:
+				outStr = fmt.Sprintf("%.10d %.5d n\r\n", ref.Offset, ref.Generation)
+				w.writeString(outStr)
+			}
+		}
+
+		idx = j + 1
+	}
+
+	// Generate & write trailer
+	trailer := core.MakeDict()
+	trailer.Set("Info", w.infoObj)
+	trailer.Set("Root", w.root)
+	trailer.Set("Size", core.MakeInteger(int64(maxIndex+1)))
+	if w.appendMode && w.appendXrefPrevOffset > 0 {
+		trailer.Set("Prev", core.MakeInteger(w.appendXrefPrevOffset))
+	}
+	if xrefStmOffset > 0 {
+		trailer.Set("XRefStm", core.MakeInteger(xrefStmOffset))
+	}
+	// If encrypted!
+	if w.crypter != nil {
+		trailer.Set("Encrypt", w.encryptObj)
+	}
+	if w.ids != nil {
+		trailer.Set("ID", w.ids)
+		common.Log.Trace("Ids: %s", w.ids)
+	}
+	w.writeString("trailer\n")
+	w.writeString(trailer.WriteString())
+	w.writeString("\n")
 }
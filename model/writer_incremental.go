@@ -0,0 +1,219 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// incrementalUpdate holds the state of an incremental update written by WriteIncremental.
+type incrementalUpdate struct {
+	// Parser of the updated document, whose objects are added to the writer.
+	sourceParser *core.PdfParser
+	// Separate parser reading the unmodified objects of the updated document.
+	origParser *core.PdfParser
+	// Object numbers in the updated document of the catalog, page tree and document
+	// information dictionary created by the writer.
+	objectNums map[core.PdfObject]int64
+}
+
+// WriteIncremental writes out the PDF as an incremental update of the document read by `reader`,
+// from which the writer is usually created with NewPdfWriterFromReader. The original file is
+// copied unchanged, followed by the objects that are new or differ from the original ones, and
+// a cross reference section referring to the original one. Objects of the original document
+// keep their object numbers, so that unchanged ones are not written again and the existing
+// digital signatures remain valid.
+// Encrypted documents, encryption and signatures prepared with PrepareSignature are not
+// supported.
+func (w *PdfWriter) WriteIncremental(writer io.Writer, reader *PdfReader) error {
+	if w.streamOutput != nil {
+		return errors.New("stream writers are written with Close")
+	}
+	if w.crypter != nil || w.signature != nil {
+		return errors.New("incremental updates cannot be encrypted or signed")
+	}
+	encrypted, err := reader.IsEncrypted()
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return errors.New("incremental updates of encrypted documents are not supported")
+	}
+
+	size, err := reader.rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	origParser, err := core.NewParser(reader.rs)
+	if err != nil {
+		return err
+	}
+	trailer := reader.parser.GetTrailer()
+	if trailer == nil {
+		return errors.New("missing trailer")
+	}
+
+	// Restore the writer after the update, which changes the objects to write and the object
+	// numbers of the catalog, page tree and document information, so that it can be written again.
+	objects, objectsMap := w.objects, w.objectsMap
+	root, pages, infoObj, encryptObj := w.root, w.pages, w.infoObj, w.encryptObj
+	objectNums := map[*core.PdfIndirectObject]int64{}
+	for _, obj := range []*core.PdfIndirectObject{root, pages, infoObj} {
+		objectNums[obj] = obj.ObjectNumber
+	}
+	ids := w.ids
+	useCrossReferenceStream := w.useCrossReferenceStream
+	defer func() {
+		w.objects, w.objectsMap = objects, objectsMap
+		w.root, w.pages, w.infoObj, w.encryptObj = root, pages, infoObj, encryptObj
+		for obj, num := range objectNums {
+			obj.ObjectNumber = num
+		}
+		w.ids = ids
+		w.useCrossReferenceStream = useCrossReferenceStream
+
+		w.incremental = nil
+		w.writeOffset = 0
+		w.ObjNumOffset = 0
+		w.appendMode = false
+		w.appendToXrefs = core.XrefTable{}
+		w.appendXrefPrevOffset = 0
+		w.appendPrevRevisionSize = 0
+		w.appendReplaceMap = nil
+	}()
+
+	update := &incrementalUpdate{
+		sourceParser: reader.parser,
+		origParser:   origParser,
+		objectNums:   map[core.PdfObject]int64{},
+	}
+	// Take the places of the catalog, page tree and document information of the original.
+	if root, ok := core.GetIndirect(trailer.Get("Root")); ok {
+		update.objectNums[w.root] = root.ObjectNumber
+	}
+	if reader.pagesContainer != nil {
+		update.objectNums[w.pages] = reader.pagesContainer.ObjectNumber
+	}
+	if info, ok := core.GetIndirect(trailer.Get("Info")); ok {
+		update.objectNums[w.infoObj] = info.ObjectNumber
+	}
+	for obj, num := range update.objectNums {
+		obj.(*core.PdfIndirectObject).ObjectNumber = num
+	}
+
+	xrefs := reader.parser.GetXrefTable()
+	var greatestObjNum int
+	for num := range xrefs.ObjectMap {
+		if num > greatestObjNum {
+			greatestObjNum = num
+		}
+	}
+
+	if w.ids == nil {
+		revIDs, err := revisionIDs(trailer)
+		if err != nil {
+			return err
+		}
+		w.ids = revIDs
+	}
+
+	if useCrossReferenceStream == nil {
+		if xrefType := reader.parser.GetXrefType(); xrefType != nil {
+			useStream := *xrefType == core.XrefTypeObjectStream
+			w.useCrossReferenceStream = &useStream
+		}
+	}
+
+	// Copy the original document.
+	if _, err := reader.rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	offset, err := io.Copy(writer, reader.rs)
+	if err != nil {
+		return err
+	}
+	if offset != size {
+		return errors.New("original document size changed")
+	}
+
+	w.incremental = update
+	w.writeOffset = offset
+	w.ObjNumOffset = greatestObjNum
+	w.appendMode = true
+	w.appendToXrefs = xrefs
+	w.appendXrefPrevOffset = reader.parser.GetXrefOffset()
+	w.appendPrevRevisionSize = size
+	w.appendReplaceMap = map[core.PdfObject]int64{}
+
+	return w.write(context.Background(), writer)
+}
+
+// selectIncrementalObjects keeps the objects to write in the incremental update: the new objects
+// and the objects of the updated document that have changed, which replace the original ones.
+func (w *PdfWriter) selectIncrementalObjects() {
+	update := w.incremental
+	objects := make([]core.PdfObject, 0, len(w.objects))
+	objectsMap := make(map[core.PdfObject]struct{}, len(w.objects))
+	for _, obj := range w.objects {
+		if num, ok := update.objectNumber(obj); ok {
+			if !update.changed(obj, num) {
+				continue
+			}
+			w.appendReplaceMap[obj] = num
+		}
+		objects = append(objects, obj)
+		objectsMap[obj] = struct{}{}
+	}
+	common.Log.Trace("Incremental update: writing %d of %d objects", len(objects), len(w.objects))
+	w.objects = objects
+	w.objectsMap = objectsMap
+}
+
+// objectNumber returns the object number of `obj` in the updated document, and false if `obj`
+// is a new object.
+func (u *incrementalUpdate) objectNumber(obj core.PdfObject) (int64, bool) {
+	if num, ok := u.objectNums[obj]; ok {
+		return num, num > 0
+	}
+	var ref *core.PdfObjectReference
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		ref = &t.PdfObjectReference
+	case *core.PdfObjectStream:
+		ref = &t.PdfObjectReference
+	default:
+		return 0, false
+	}
+	if ref.GetParser() != u.sourceParser || ref.ObjectNumber <= 0 {
+		return 0, false
+	}
+	return ref.ObjectNumber, true
+}
+
+// changed returns true if `obj` differs from the object number `num` of the original document.
+func (u *incrementalUpdate) changed(obj core.PdfObject, num int64) bool {
+	orig, err := u.origParser.LookupByNumber(int(num))
+	if err != nil {
+		common.Log.Debug("ERROR: original object %d: %v", num, err)
+		return true
+	}
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		origObj, ok := orig.(*core.PdfIndirectObject)
+		return !ok || origObj.PdfObject.WriteString() != t.PdfObject.WriteString()
+	case *core.PdfObjectStream:
+		origStream, ok := orig.(*core.PdfObjectStream)
+		return !ok || !bytes.Equal(origStream.Stream, t.Stream) ||
+			origStream.PdfObjectDictionary.WriteString() != t.PdfObjectDictionary.WriteString()
+	}
+	return true
+}
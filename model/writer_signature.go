@@ -0,0 +1,261 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v3/core"
+)
+
+// defaultSignatureContentsSize is the default number of bytes reserved for the signature.
+const defaultSignatureContentsSize = 8192
+
+// byteRangePlaceholder is the largest value of the ByteRange placeholder. The ByteRange is
+// written with numbers of the same width, so that filling it in does not shift any offsets.
+const byteRangePlaceholder = 9999999999
+
+// SignatureFieldOpts represents the options of a signature field prepared with
+// PdfWriter.PrepareSignature.
+type SignatureFieldOpts struct {
+	// Name is the partial field name (T). Defaults to "Signature1".
+	Name string
+
+	// Page is the page showing the signature widget. It must have been added with AddPage.
+	// If nil, the field has no widget on any page.
+	Page *PdfPage
+
+	// Rect is the rectangle of the widget on the page. Defaults to an invisible, empty rectangle.
+	Rect PdfRectangle
+
+	// ContentsSize is the number of bytes reserved for the signature (Contents).
+	// Defaults to 8192.
+	ContentsSize int
+
+	// Optional entries of the signature dictionary.
+	SignerName  string
+	Reason      string
+	Location    string
+	ContactInfo string
+	Date        time.Time
+}
+
+// SignatureHandle represents a signature prepared with PdfWriter.PrepareSignature. Once the
+// document has been written, it provides the ByteRange of the signed data and the position of
+// the Contents reserved for the signature, so that an external signer can compute the digest
+// of the signed data and fill in the signature.
+type SignatureHandle struct {
+	contentsSize int
+	written      bool
+
+	// Offsets in the output file, set when writing.
+	fileOffset           int64
+	contentsOffsetStart  int64
+	contentsOffsetEnd    int64
+	byteRangeOffsetStart int64
+	byteRangeOffsetEnd   int64
+	fileSize             int64
+}
+
+// PrepareSignature adds a signature field to the form of the document, whose signature
+// dictionary has a ByteRange placeholder and a Contents hex string of `opts.ContentsSize` zero
+// bytes. The ByteRange is filled in when writing, after which the returned SignatureHandle
+// gives the offsets of the signed data and the Contents. Only one signature can be prepared.
+func (w *PdfWriter) PrepareSignature(opts SignatureFieldOpts) (*SignatureHandle, error) {
+	if w.signature != nil {
+		return nil, errors.New("signature already prepared")
+	}
+	if w.streamOutput != nil {
+		return nil, errors.New("signatures are not supported by stream writers")
+	}
+	if opts.ContentsSize < 0 {
+		return nil, errors.New("contents size must not be negative")
+	}
+	if opts.ContentsSize == 0 {
+		opts.ContentsSize = defaultSignatureContentsSize
+	}
+	if opts.Name == "" {
+		opts.Name = "Signature1"
+	}
+
+	var pageObj *core.PdfIndirectObject
+	if opts.Page != nil {
+		var err error
+		pageObj, err = w.pageObject(opts.Page)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	handle := &SignatureHandle{contentsSize: opts.ContentsSize}
+
+	signature := NewPdfSignature(nil)
+	signature.Filter = core.MakeName("Adobe.PPKLite")
+	signature.SubFilter = core.MakeName("adbe.pkcs7.detached")
+	signature.ByteRange = core.MakeArrayFromIntegers64([]int64{
+		byteRangePlaceholder, byteRangePlaceholder, byteRangePlaceholder, byteRangePlaceholder,
+	})
+	signature.Contents = core.MakeHexString(string(make([]byte, opts.ContentsSize)))
+	if opts.SignerName != "" {
+		signature.SetName(opts.SignerName)
+	}
+	if opts.Reason != "" {
+		signature.SetReason(opts.Reason)
+	}
+	if opts.Location != "" {
+		signature.SetLocation(opts.Location)
+	}
+	if opts.ContactInfo != "" {
+		signature.ContactInfo = core.MakeString(opts.ContactInfo)
+	}
+	if !opts.Date.IsZero() {
+		signature.SetDate(opts.Date, "")
+	}
+	signature.container.PdfObject.(*pdfSignDictionary).handle = handle
+
+	field := NewPdfFieldSignature(signature)
+	field.T = core.MakeString(opts.Name)
+	field.Rect = opts.Rect.ToPdfObject()
+	if pageObj != nil {
+		field.P = pageObj
+	}
+	fieldObj := field.ToPdfObject()
+
+	if pageObj != nil {
+		pageDict, ok := core.GetDict(pageObj)
+		if !ok {
+			return nil, errors.New("page object should be a dictionary")
+		}
+		annots, ok := core.GetArray(pageDict.Get("Annots"))
+		if !ok {
+			annots = core.MakeArray()
+			pageDict.Set("Annots", annots)
+		}
+		annots.Append(fieldObj)
+		if err := w.addObjects(fieldObj); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.acroForm == nil {
+		w.acroForm = NewPdfAcroForm()
+	}
+	var fields []*PdfField
+	if w.acroForm.Fields != nil {
+		fields = *w.acroForm.Fields
+	}
+	fields = append(fields, field.PdfField)
+	w.acroForm.Fields = &fields
+	w.SetSigFlags(SigFlagSignaturesExist | SigFlagAppendOnly)
+
+	w.signature = handle
+	return handle, nil
+}
+
+// setOffsets records the offsets of the signature dictionary `sigDict` which has just been
+// serialized to be written at its file offset.
+func (h *SignatureHandle) setOffsets(sigDict *pdfSignDictionary) {
+	h.fileOffset = sigDict.fileOffset
+	h.contentsOffsetStart = sigDict.fileOffset + int64(sigDict.contentsOffsetStart)
+	h.contentsOffsetEnd = sigDict.fileOffset + int64(sigDict.contentsOffsetEnd)
+	h.byteRangeOffsetStart = sigDict.fileOffset + int64(sigDict.byteRangeOffsetStart)
+	h.byteRangeOffsetEnd = sigDict.fileOffset + int64(sigDict.byteRangeOffsetEnd)
+}
+
+// writeSignaturePlaceholder writes out the PDF to `writer` like write, filling in the ByteRange
+// of the prepared signature, which depends on the size of the output.
+func (w *PdfWriter) writeSignaturePlaceholder(ctx context.Context, writer io.Writer) error {
+	handle := w.signature
+	handle.written = false
+	handle.fileOffset = 0
+
+	var buf bytes.Buffer
+	if err := w.write(ctx, &buf); err != nil {
+		return err
+	}
+	if handle.fileOffset == 0 {
+		return errors.New("signature dictionary not written")
+	}
+	handle.fileSize = w.writePos
+
+	byteRange := handle.byteRange()
+	byteRangeStr := fmt.Sprintf("[%010d %010d %010d %010d]",
+		byteRange[0], byteRange[1], byteRange[2], byteRange[3])
+	width := int(handle.byteRangeOffsetEnd - handle.byteRangeOffsetStart)
+	if len(byteRangeStr) > width {
+		return errors.New("byte range exceeds its placeholder")
+	}
+	byteRangeStr += strings.Repeat(" ", width-len(byteRangeStr))
+
+	data := buf.Bytes()
+	start := handle.byteRangeOffsetStart - w.writeOffset
+	copy(data[start:start+int64(width)], byteRangeStr)
+
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	handle.written = true
+	return nil
+}
+
+// byteRange returns the ByteRange of the signature: the offset and length of the data before
+// and after the Contents.
+func (h *SignatureHandle) byteRange() [4]int64 {
+	return [4]int64{
+		0, h.contentsOffsetStart,
+		h.contentsOffsetEnd, h.fileSize - h.contentsOffsetEnd,
+	}
+}
+
+// ByteRange returns the ByteRange of the signature, i.e. the offsets and lengths of the signed
+// data, which is all of the output file except for the Contents.
+// The document must have been written.
+func (h *SignatureHandle) ByteRange() ([4]int64, error) {
+	if !h.written {
+		return [4]int64{}, errors.New("signature not written")
+	}
+	return h.byteRange(), nil
+}
+
+// ContentsSize returns the number of bytes reserved for the signature.
+func (h *SignatureHandle) ContentsSize() int {
+	return h.contentsSize
+}
+
+// SignedData returns a reader of the data covered by the ByteRange of the signature, read from
+// the written document `r`. The digest of the signature is computed over this data.
+func (h *SignatureHandle) SignedData(r io.ReaderAt) (io.Reader, error) {
+	byteRange, err := h.ByteRange()
+	if err != nil {
+		return nil, err
+	}
+	return io.MultiReader(
+		io.NewSectionReader(r, byteRange[0], byteRange[1]),
+		io.NewSectionReader(r, byteRange[2], byteRange[3]),
+	), nil
+}
+
+// PatchContents writes `signature` hex encoded into the Contents reserved for it in the written
+// document `w`. The rest of the reserved space is left zero filled.
+func (h *SignatureHandle) PatchContents(w io.WriterAt, signature []byte) error {
+	byteRange, err := h.ByteRange()
+	if err != nil {
+		return err
+	}
+	if len(signature) > h.contentsSize {
+		return fmt.Errorf("signature size %d exceeds reserved size %d", len(signature), h.contentsSize)
+	}
+	// Skip the '<' delimiter of the hex string.
+	_, err = w.WriteAt([]byte(hex.EncodeToString(signature)), byteRange[1]+1)
+	return err
+}
@@ -6,11 +6,22 @@
 package model
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/unidoc/unipdf/v3/common"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/core/security"
 )
 
 // Tests loading annotations from file, writing back out and reloading.
@@ -104,3 +115,1614 @@ func TestReadWriteAnnotations(t *testing.T) {
 		checkAnnots(reader, false)
 	}
 }
+
+// Tests that WriteContext aborts when the context is cancelled.
+func TestWriteContextCancelled(t *testing.T) {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err := w.WriteContext(ctx, &buf)
+	require.Equal(t, context.Canceled, err)
+	require.Zero(t, buf.Len())
+
+	err = w.WriteContext(context.Background(), &buf)
+	require.NoError(t, err)
+	require.NotZero(t, buf.Len())
+}
+
+// Tests the Producer attribute generated from a template.
+func TestPdfProducerTemplate(t *testing.T) {
+	defer SetPdfProducerTemplate("")
+
+	SetPdfProducerTemplate("MyApp (based on UniDoc v%s)")
+	require.Equal(t, "MyApp (based on UniDoc v"+common.Version+")", getPdfProducer())
+
+	// An explicitly set producer takes precedence.
+	SetPdfProducer("MyApp")
+	defer SetPdfProducer("")
+	require.Equal(t, "MyApp", getPdfProducer())
+}
+
+func TestWriterStamp(t *testing.T) {
+	w := NewPdfWriter()
+	require.Error(t, w.SetStamp(&StampOptions{Alpha: 0.5}))
+	require.Error(t, w.SetStamp(&StampOptions{Text: "DRAFT"}))
+
+	img := &Image{Width: 2, Height: 2, BitsPerComponent: 8, ColorComponents: 1, Data: []byte{0, 255, 255, 0}}
+	ximg, err := NewXObjectImageFromImage(img, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.SetStamp(&StampOptions{
+		Text:  "DRAFT",
+		Image: ximg,
+		Alpha: 0.3,
+		Angle: 45,
+	}))
+
+	var pages []*PdfPage
+	for i := 0; i < 2; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.AddContentStreamByString("1 0 0 rg 0 0 10 10 re f"))
+		require.NoError(t, w.AddPage(page))
+		pages = append(pages, page)
+	}
+
+	// The stamp resources are shared by the pages.
+	resources := [2]*PdfPageResources{pages[0].Resources, pages[1].Resources}
+	for _, field := range []struct {
+		name core.PdfObjectName
+		get  func(r *PdfPageResources) core.PdfObject
+	}{
+		{"StGS0", func(r *PdfPageResources) core.PdfObject { return r.ExtGState }},
+		{"StF0", func(r *PdfPageResources) core.PdfObject { return r.Font }},
+		{"StIm0", func(r *PdfPageResources) core.PdfObject { return r.XObject }},
+	} {
+		var objs [2]core.PdfObject
+		for i, r := range resources {
+			d, ok := core.GetDict(field.get(r))
+			require.True(t, ok)
+			objs[i] = d.Get(field.name)
+		}
+		require.NotNil(t, objs[0], field.name)
+		require.True(t, objs[0] == objs[1], field.name)
+	}
+	gs, ok := resources[0].GetExtGState("StGS0")
+	require.True(t, ok)
+	alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(gs).(*core.PdfObjectDictionary).Get("ca"))
+	require.NoError(t, err)
+	require.Equal(t, 0.3, alpha)
+
+	// The existing contents are isolated in a q/Q pair, followed by the stamp.
+	cstreams, err := pages[0].GetContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, "q\n", cstreams[0])
+	stamp := cstreams[len(cstreams)-1]
+	require.Contains(t, stamp, "/StGS0 gs")
+	require.Contains(t, stamp, "0.7071 0.7071 -0.7071 0.7071 306.0000 396.0000 cm")
+	require.Contains(t, stamp, "/StIm0 Do")
+	require.Contains(t, stamp, "(DRAFT) Tj")
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+}
+
+func TestWriterTrapped(t *testing.T) {
+	w := NewPdfWriter()
+	infoDict, ok := core.GetDict(w.infoObj)
+	require.True(t, ok)
+	require.Equal(t, "Unknown", infoDict.Get("Trapped").String())
+
+	require.NoError(t, w.SetTrapped(TrappedTrue))
+	require.Equal(t, "True", infoDict.Get("Trapped").String())
+	require.Error(t, w.SetTrapped("Maybe"))
+	require.Equal(t, "True", infoDict.Get("Trapped").String())
+}
+
+func TestWriterSigFlags(t *testing.T) {
+	w := NewPdfWriter()
+	form := NewPdfAcroForm()
+	require.NoError(t, w.SetForms(form))
+	w.SetSigFlags(SigFlagSignaturesExist | SigFlagAppendOnly)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.NotNil(t, reader.AcroForm)
+	require.NotNil(t, reader.AcroForm.SigFlags)
+	require.Equal(t, int64(3), int64(*reader.AcroForm.SigFlags))
+}
+
+func TestWriterFormDefaultAppearance(t *testing.T) {
+	w := NewPdfWriter()
+	form := NewPdfAcroForm()
+	require.NoError(t, w.SetForms(form))
+
+	// Forms are written with a default appearance using Helvetica, leaving the form unchanged.
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.Nil(t, form.DA)
+	require.Nil(t, form.DR)
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.NotNil(t, reader.AcroForm)
+	require.NotNil(t, reader.AcroForm.DA)
+	require.Equal(t, "/Helv 0 Tf 0 g", reader.AcroForm.DA.Str())
+	require.NotNil(t, reader.AcroForm.DR)
+	require.True(t, reader.AcroForm.DR.HasFontByName("Helv"))
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := NewPdfWriter()
+
+	err := w.addObjects(&core.PdfObjectReference{ObjectNumber: 5})
+	require.True(t, errors.Is(err, ErrReferenceInWriter))
+
+	dict := core.MakeDict()
+	dict.Set("Parent", &core.PdfObjectReference{ObjectNumber: 5})
+	err = w.addObjects(dict)
+	require.True(t, errors.Is(err, ErrParentIsReference))
+
+	// A dictionary whose parent is never added is written with a null Parent, and reported.
+	w = NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	parent := core.MakeIndirectObject(core.MakeDict())
+	child := core.MakeDict()
+	child.Set("Parent", parent)
+	require.NoError(t, w.addObjects(core.MakeIndirectObject(child)))
+	require.Empty(t, w.Warnings())
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.Len(t, w.Warnings(), 1)
+	require.True(t, errors.Is(w.Warnings()[0], ErrObjectReplacedWithNull))
+	require.Equal(t, core.MakeNull(), child.Get("Parent"))
+}
+
+// objectStreamsOptimizer groups the indirect objects into an object stream.
+type objectStreamsOptimizer struct{}
+
+func (o objectStreamsOptimizer) Optimize(objects []core.PdfObject) ([]core.PdfObject, error) {
+	objStream := &core.PdfObjectStreams{}
+	var optimized []core.PdfObject
+	for _, obj := range objects {
+		if _, ok := obj.(*core.PdfIndirectObject); ok {
+			objStream.Append(obj)
+		} else {
+			optimized = append(optimized, obj)
+		}
+	}
+	optimized = append(optimized, objStream)
+	return append(optimized, objStream.Elements()...), nil
+}
+
+func TestWriterHybridCrossReference(t *testing.T) {
+	write := func(hybrid bool) []byte {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.AddContentStreamByString("0 0 100 100 re f"))
+
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		w.SetOptimizer(objectStreamsOptimizer{})
+		w.SetHybridCrossReference(hybrid)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+
+		reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+		return buf.Bytes()
+	}
+
+	// startxref returns the data at the offset referenced by startxref.
+	startxref := func(data []byte) []byte {
+		i := bytes.LastIndex(data, []byte("startxref\n"))
+		require.True(t, i >= 0)
+		var offset int
+		_, err := fmt.Sscanf(string(data[i+len("startxref\n"):]), "%d", &offset)
+		require.NoError(t, err)
+		return data[offset:]
+	}
+
+	data := write(false)
+	require.False(t, bytes.Contains(data, []byte("/XRefStm")))
+	require.False(t, bytes.HasPrefix(startxref(data), []byte("xref")))
+
+	data = write(true)
+	table := startxref(data)
+	require.True(t, bytes.HasPrefix(table, []byte("xref")))
+	require.True(t, bytes.Contains(table, []byte("/XRefStm")))
+	require.True(t, bytes.Contains(data, []byte("/Type /XRef")))
+
+	// Only the uncompressed objects are listed in the table, and their offsets must be valid.
+	var inUse int
+	for _, line := range strings.Split(string(table[:bytes.Index(table, []byte("trailer"))]), "\r\n") {
+		var offset, gen int
+		var typ string
+		if n, _ := fmt.Sscanf(line, "%d %d %s", &offset, &gen, &typ); n != 3 || typ != "n" {
+			continue
+		}
+		inUse++
+		require.Regexp(t, `^\d+ 0 obj`, string(data[offset:offset+20]))
+	}
+	require.True(t, inUse > 0)
+	var size int
+	i := bytes.Index(table, []byte("/Size "))
+	_, err := fmt.Sscanf(string(table[i+len("/Size "):]), "%d", &size)
+	require.NoError(t, err)
+	require.True(t, inUse < size-1)
+}
+
+// TestWriterDeterministicOutput tests that the object numbering, and thus the output, does not
+// vary between runs.
+func TestWriterDeterministicOutput(t *testing.T) {
+	write := func() []byte {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		page.Resources = NewPdfPageResources()
+		xobjects := core.MakeDict()
+		for i := 0; i < 20; i++ {
+			stream, err := core.MakeStream([]byte(fmt.Sprintf("%d 0 0 %d 0 0 cm", i, i)), nil)
+			require.NoError(t, err)
+			xobjects.Set(core.PdfObjectName(fmt.Sprintf("X%d", i)), stream)
+		}
+		page.Resources.XObject = xobjects
+
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	expected := write()
+	for i := 0; i < 5; i++ {
+		require.Equal(t, expected, write())
+	}
+}
+
+func TestWriterFreeEntries(t *testing.T) {
+	w := NewPdfWriter()
+	w.crossReferenceMap = map[int]crossReference{
+		0: {Type: 0, Generation: 0xFFFF},
+		1: {Type: 1, Offset: 15},
+		3: {Type: 1, Offset: 120},
+		4: {Type: 2, ObjectNumber: 6, Index: 0},
+		6: {Type: 1, Offset: 250},
+	}
+	w.linkFreeEntries(6)
+	require.Equal(t, crossReference{Type: 0, ObjectNumber: 2, Generation: 0xFFFF}, w.crossReferenceMap[0])
+	require.Equal(t, crossReference{Type: 0, ObjectNumber: 5, Generation: 1}, w.crossReferenceMap[2])
+	require.Equal(t, crossReference{Type: 0, ObjectNumber: 0, Generation: 1}, w.crossReferenceMap[5])
+
+	var buf bytes.Buffer
+	w.writer = bufio.NewWriter(&buf)
+	w.crossReferenceMap[4] = crossReference{Type: 1, Offset: 200}
+	w.writeCrossReferenceTable(6, 0)
+	require.NoError(t, w.writer.Flush())
+
+	expected := "xref\r\n0 7\r\n" +
+		"0000000002 65535 f\r\n" +
+		"0000000015 00000 n\r\n" +
+		"0000000005 00001 f\r\n" +
+		"0000000120 00000 n\r\n" +
+		"0000000200 00000 n\r\n" +
+		"0000000000 00001 f\r\n" +
+		"0000000250 00000 n\r\n" +
+		"trailer\n"
+	require.True(t, strings.HasPrefix(buf.String(), expected), buf.String())
+}
+
+func TestNewPdfWriterFromReader(t *testing.T) {
+	// Write a document with catalog entries that the writer does not model.
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	collection := core.MakeDict()
+	collection.Set("Type", core.MakeName("Collection"))
+	collection.Set("View", core.MakeName("T"))
+	collectionObj := core.MakeIndirectObject(collection)
+	w.catalog.Set("Collection", collectionObj)
+	require.NoError(t, w.addObjects(collectionObj))
+	w.catalog.Set("PageMode", core.MakeName("UseThumbs"))
+	infoDict, ok := core.GetDict(w.infoObj)
+	require.True(t, ok)
+	infoDict.Set("Title", core.MakeString("Round trip"))
+	infoDict.Set("Custom", core.MakeString("value"))
+	w.SetVersion(1, 6)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// Write the document back out.
+	w, err = NewPdfWriterFromReader(reader)
+	require.NoError(t, err)
+	for _, p := range reader.PageList {
+		require.NoError(t, w.AddPage(p))
+	}
+	require.NoError(t, w.SetTrapped(TrappedTrue))
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 1, numPages)
+	require.Equal(t, "1.6", reader.catalog.Get("Version").String())
+	require.Equal(t, "UseThumbs", reader.catalog.Get("PageMode").String())
+	collectionDict, ok := core.GetDict(reader.catalog.Get("Collection"))
+	require.True(t, ok)
+	require.Equal(t, "T", collectionDict.Get("View").String())
+
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	info, ok := core.GetDict(trailer.Get("Info"))
+	require.True(t, ok)
+	require.Equal(t, "Round trip", info.Get("Title").String())
+	require.Equal(t, "value", info.Get("Custom").String())
+	require.Equal(t, "True", info.Get("Trapped").String())
+}
+
+func TestWriterUseCrossReferenceStream(t *testing.T) {
+	write := func(major, minor int, useStream *bool) []byte {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		w.SetVersion(major, minor)
+		if useStream != nil {
+			w.SetUseCrossReferenceStream(*useStream)
+		}
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+
+		reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+		return buf.Bytes()
+	}
+	startxref := func(data []byte) []byte {
+		i := bytes.LastIndex(data, []byte("startxref\n"))
+		require.True(t, i >= 0)
+		var offset int
+		_, err := fmt.Sscanf(string(data[i+len("startxref\n"):]), "%d", &offset)
+		require.NoError(t, err)
+		return data[offset:]
+	}
+	isStream := func(data []byte) bool {
+		xref := startxref(data)
+		if bytes.HasPrefix(xref, []byte("xref")) {
+			return false
+		}
+		require.Regexp(t, `^\d+ 0 obj\n<<.*/Type /XRef`, string(xref[:bytes.Index(xref, []byte(">>"))]))
+		return true
+	}
+	useStream, useTable := true, false
+
+	require.False(t, isStream(write(1, 4, nil)))
+	require.True(t, isStream(write(1, 5, nil)))
+	require.False(t, isStream(write(1, 7, &useTable)))
+
+	data := write(1, 4, &useStream)
+	require.True(t, bytes.HasPrefix(data, []byte("%PDF-1.5\n")))
+	require.True(t, isStream(data))
+
+	// The free entry of object 0 is a type 0 entry.
+	stream, err := core.NewParserFromString(string(startxref(data))).ParseIndirectObject()
+	require.NoError(t, err)
+	xrefStream, ok := stream.(*core.PdfObjectStream)
+	require.True(t, ok)
+	decoded, err := core.DecodeStream(xrefStream)
+	require.NoError(t, err)
+	require.Equal(t, "[1 2 2]", xrefStream.Get("W").WriteString())
+	require.Equal(t, []byte{0, 0, 0, 0xff, 0xff}, decoded[:5])
+}
+
+func TestWriterObjectStreamMode(t *testing.T) {
+	write := func(encrypt bool) []byte {
+		w := NewPdfWriter()
+		for i := 0; i < 3; i++ {
+			page := NewPdfPage()
+			page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+			require.NoError(t, page.AddContentStreamByString(fmt.Sprintf("BT /F1 12 Tf (Page %d) Tj ET", i+1)))
+			require.NoError(t, w.AddPage(page))
+		}
+		infoDict, ok := core.GetDict(w.infoObj)
+		require.True(t, ok)
+		infoDict.Set("Title", core.MakeString("Object streams"))
+		w.SetObjectStreamMode(true)
+		w.SetMaxObjectsPerStream(2)
+		if encrypt {
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		data := write(encrypt)
+		require.True(t, bytes.HasPrefix(data, []byte("%PDF-1.5\n")))
+		require.True(t, bytes.Count(data, []byte("/Type /ObjStm")) > 1)
+
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		if encrypt {
+			isEncrypted, err := reader.IsEncrypted()
+			require.NoError(t, err)
+			require.True(t, isEncrypted)
+			auth, err := reader.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, auth)
+		}
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 3, numPages)
+		page, err := reader.GetPage(3)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, "(Page 3) Tj")
+
+		trailer, err := reader.GetTrailer()
+		require.NoError(t, err)
+		info, ok := core.GetDict(trailer.Get("Info"))
+		require.True(t, ok)
+		title, ok := core.GetString(info.Get("Title"))
+		require.True(t, ok)
+		require.Equal(t, "Object streams", title.Decoded())
+	}
+}
+
+func benchmarkWriterAddPages(b *testing.B, numPages int) {
+	for i := 0; i < b.N; i++ {
+		w := NewPdfWriter()
+		for j := 0; j < numPages; j++ {
+			page := NewPdfPage()
+			page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+			require.NoError(b, w.AddPage(page))
+		}
+	}
+}
+
+// Benchmark adding pages to a writer. The time per page should not grow with the number of
+// objects already added.
+func BenchmarkWriterAddPages10(b *testing.B)   { benchmarkWriterAddPages(b, 10) }
+func BenchmarkWriterAddPages100(b *testing.B)  { benchmarkWriterAddPages(b, 100) }
+func BenchmarkWriterAddPages1000(b *testing.B) { benchmarkWriterAddPages(b, 1000) }
+
+func TestWriterEncryptUnencryptedMetadata(t *testing.T) {
+	const xmp = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><dc:title>Plain</dc:title></x:xmpmeta>`
+
+	for _, algo := range []EncryptionAlgorithm{AES_128bit, AES_256bit} {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.AddContentStreamByString("BT /F1 12 Tf (Secret) Tj ET"))
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+
+		metadata, err := core.MakeStream([]byte(xmp), nil)
+		require.NoError(t, err)
+		metadata.Set("Type", core.MakeName("Metadata"))
+		metadata.Set("Subtype", core.MakeName("XML"))
+		w.catalog.Set("Metadata", metadata)
+		require.NoError(t, w.addObjects(metadata))
+
+		opts := &EncryptOptions{Permissions: security.PermOwner, Algorithm: algo, UnencryptedMetadata: true}
+		require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), opts))
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		data := buf.Bytes()
+		require.True(t, bytes.Contains(data, []byte(xmp)))
+		require.True(t, bytes.Contains(data, []byte("/EncryptMetadata false")))
+		require.True(t, bytes.Contains(data, []byte("/Filter [/Crypt]")))
+
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		auth, err := reader.Decrypt([]byte("user"))
+		require.NoError(t, err)
+		require.True(t, auth)
+
+		stream, ok := core.GetStream(reader.catalog.Get("Metadata"))
+		require.True(t, ok)
+		decoded, err := core.DecodeStream(stream)
+		require.NoError(t, err)
+		require.Equal(t, xmp, string(decoded))
+
+		p, err := reader.GetPage(1)
+		require.NoError(t, err)
+		contents, err := p.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, "(Secret) Tj")
+	}
+
+	// Metadata can only be left unencrypted with crypt filters.
+	w := NewPdfWriter()
+	err := w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: RC4_128bit, UnencryptedMetadata: true})
+	require.Error(t, err)
+}
+
+func TestWriterInfo(t *testing.T) {
+	w := NewPdfWriter()
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	modified := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	require.NoError(t, w.SetTitle("Title – ünïcode"))
+	require.NoError(t, w.SetAuthor("Author"))
+	require.NoError(t, w.SetSubject("Subject"))
+	require.NoError(t, w.SetKeywords("one, two"))
+	require.NoError(t, w.SetCreationDate(created))
+	require.NoError(t, w.SetModifiedDate(modified))
+
+	info := w.GetInfo()
+	require.Equal(t, "Title – ünïcode", info.Title)
+	require.Equal(t, "Author", info.Author)
+	require.Equal(t, "Subject", info.Subject)
+	require.Equal(t, "one, two", info.Keywords)
+	require.True(t, created.Equal(info.CreationDate))
+	require.True(t, modified.Equal(info.ModifiedDate))
+	require.Equal(t, TrappedUnknown, info.Trapped)
+
+	infoDict, ok := core.GetDict(w.infoObj)
+	require.True(t, ok)
+	require.Equal(t, "(D:20200102030405+01'00')", infoDict.Get("CreationDate").WriteString())
+	require.Equal(t, "(Author)", infoDict.Get("Author").WriteString())
+
+	require.NoError(t, w.SetSubject(""))
+	require.NoError(t, w.SetModifiedDate(time.Time{}))
+	require.Nil(t, infoDict.Get("Subject"))
+	require.Nil(t, infoDict.Get("ModDate"))
+
+	// The entries are encrypted like other strings.
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Permissions: security.PermOwner, Algorithm: AES_128bit}))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.False(t, bytes.Contains(buf.Bytes(), []byte("(Author)")))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	auth, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, auth)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	readInfo, ok := core.GetDict(trailer.Get("Info"))
+	require.True(t, ok)
+	title, ok := core.GetString(readInfo.Get("Title"))
+	require.True(t, ok)
+	require.Equal(t, "Title – ünïcode", title.Decoded())
+	author, ok := core.GetString(readInfo.Get("Author"))
+	require.True(t, ok)
+	require.Equal(t, "Author", author.Decoded())
+}
+
+func TestSetPdfModifiedDate(t *testing.T) {
+	modified := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	SetPdfModifiedDate(modified)
+	defer SetPdfModifiedDate(time.Time{})
+
+	w := NewPdfWriter()
+	info := w.GetInfo()
+	require.True(t, info.CreationDate.IsZero())
+	require.True(t, modified.Equal(info.ModifiedDate))
+}
+
+func TestWriterSetDocumentID(t *testing.T) {
+	id0, id1 := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+	write := func(encrypt bool) []byte {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.AddContentStreamByString("BT /F1 12 Tf (Secret) Tj ET"))
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		require.NoError(t, w.SetDocumentID(id0, id1))
+		if encrypt {
+			opts := &EncryptOptions{Permissions: security.PermOwner, Algorithm: RC4_128bit}
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), opts))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	// Unencrypted output.
+	data := write(false)
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	require.True(t, ok)
+	require.Equal(t, 2, ids.Len())
+	require.Equal(t, string(id0), ids.Get(0).(*core.PdfObjectString).Str())
+	require.Equal(t, string(id1), ids.Get(1).(*core.PdfObjectString).Str())
+
+	// Encrypted output is reproducible and can be decrypted.
+	data = write(true)
+	require.Equal(t, data, write(true))
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	auth, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, auth)
+	p, err := reader.GetPage(1)
+	require.NoError(t, err)
+	contents, err := p.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Secret) Tj")
+
+	w := NewPdfWriter()
+	require.Error(t, w.SetDocumentID(nil, id1))
+	require.Error(t, w.SetDocumentID(id0, []byte{}))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+	require.Error(t, w.SetDocumentID(id0, id1))
+}
+
+func TestWriterCompressStreams(t *testing.T) {
+	line := strings.Repeat("BT /F1 10 Tf 72 700 Td (The quick brown fox jumps over the lazy dog) Tj ET\n", 40)
+	write := func(compress bool, encrypt bool) []byte {
+		w := NewPdfWriter()
+		for i := 0; i < 50; i++ {
+			page := NewPdfPage()
+			page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+			contents, err := core.MakeStream([]byte(line), nil)
+			require.NoError(t, err)
+			page.Contents = contents
+			require.NoError(t, w.AddPage(page))
+		}
+		// Streams with a filter are left alone.
+		filtered, err := core.MakeStream([]byte("414243>"), nil)
+		require.NoError(t, err)
+		filtered.Set("Filter", core.MakeName(core.StreamEncodingFilterNameASCIIHex))
+		w.catalog.Set("Filtered", filtered)
+		require.NoError(t, w.addObjects(filtered))
+
+		w.SetCompressStreams(compress)
+		if encrypt {
+			opts := &EncryptOptions{Permissions: security.PermOwner, Algorithm: RC4_128bit}
+			require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), opts))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		return buf.Bytes()
+	}
+
+	plain := write(false, false)
+	compressed := write(true, false)
+	require.True(t, 2*len(compressed) < len(plain), "%d vs %d", len(compressed), len(plain))
+	require.True(t, bytes.Contains(compressed, []byte("414243>")))
+
+	for _, encrypt := range []bool{false, true} {
+		reader, err := NewPdfReader(bytes.NewReader(write(true, encrypt)))
+		require.NoError(t, err)
+		if encrypt {
+			auth, err := reader.Decrypt([]byte("user"))
+			require.NoError(t, err)
+			require.True(t, auth)
+		}
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 50, numPages)
+
+		page, err := reader.GetPage(50)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, "(The quick brown fox jumps over the lazy dog) Tj")
+
+		filtered, ok := core.GetStream(reader.catalog.Get("Filtered"))
+		require.True(t, ok)
+		decoded, err := core.DecodeStream(filtered)
+		require.NoError(t, err)
+		require.Equal(t, "ABC", string(decoded))
+	}
+}
+
+func TestWriterSetStreamEncoder(t *testing.T) {
+	data := []byte(strings.Repeat("BT /F1 10 Tf 72 700 Td (The quick brown fox) Tj ET\n", 40))
+	lzw := core.NewLZWEncoder()
+	lzw.EarlyChange = 0
+	runLength := core.NewRunLengthEncoder()
+	encoded, err := runLength.EncodeBytes(data)
+	require.NoError(t, err)
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	streams := map[core.PdfObjectName]*core.PdfObjectStream{}
+	for _, key := range []core.PdfObjectName{"LZW", "RunLength", "Encoded", "Later"} {
+		stream, err := core.MakeStream(data, nil)
+		require.NoError(t, err)
+		w.catalog.Set(key, stream)
+		require.NoError(t, w.addObjects(stream))
+		streams[key] = stream
+	}
+	w.SetStreamEncoder(streams["LZW"], lzw)
+	w.SetStreamEncoder(streams["RunLength"], runLength)
+	// Already encoded data with the filter of the encoder is not encoded again.
+	streams["Encoded"].Stream = encoded
+	streams["Encoded"].Set("Filter", core.MakeName(runLength.GetFilterName()))
+	w.SetStreamEncoder(streams["Encoded"], runLength)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	// The streams of the writer are left unencoded.
+	require.Equal(t, data, streams["LZW"].Stream)
+	require.Nil(t, streams["LZW"].Get("Filter"))
+
+	// Writing again does not encode the streams twice, and encodes the streams set in between.
+	w.SetStreamEncoder(streams["Later"], lzw)
+	var buf2 bytes.Buffer
+	require.NoError(t, w.Write(&buf2))
+	require.True(t, buf2.Len() < buf.Len())
+	var buf3 bytes.Buffer
+	require.NoError(t, w.Write(&buf3))
+	require.Equal(t, buf2.Len(), buf3.Len())
+
+	reader, err := NewPdfReader(bytes.NewReader(buf3.Bytes()))
+	require.NoError(t, err)
+	for key, filter := range map[core.PdfObjectName]string{
+		"LZW":       core.StreamEncodingFilterNameLZW,
+		"RunLength": core.StreamEncodingFilterNameRunLength,
+		"Encoded":   core.StreamEncodingFilterNameRunLength,
+		"Later":     core.StreamEncodingFilterNameLZW,
+	} {
+		stream, ok := core.GetStream(reader.catalog.Get(key))
+		require.True(t, ok, key)
+		require.Equal(t, filter, stream.Get("Filter").String(), key)
+		require.Equal(t, int64(len(stream.Stream)), int64(*stream.Get("Length").(*core.PdfObjectInteger)), key)
+		decoded, err := core.DecodeStream(stream)
+		require.NoError(t, err, key)
+		require.Equal(t, data, decoded, key)
+	}
+
+	// Streams with other filters cannot be encoded.
+	w = NewPdfWriter()
+	stream, err := core.MakeStream(data, core.NewFlateEncoder())
+	require.NoError(t, err)
+	require.NoError(t, w.addObjects(stream))
+	w.SetStreamEncoder(stream, lzw)
+	require.Error(t, w.Write(&bytes.Buffer{}))
+}
+
+func TestWriterInsertPage(t *testing.T) {
+	// Pages are identified by their width.
+	makePage := func(width float64) *PdfPage {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: width, Ury: 792}
+		return page
+	}
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(makePage(2)))
+	require.NoError(t, w.AddPage(makePage(4)))
+	require.NoError(t, w.InsertPage(0, makePage(1)))
+	require.NoError(t, w.InsertPage(2, makePage(3)))
+	require.NoError(t, w.InsertPage(-5, makePage(0)))
+	require.NoError(t, w.InsertPage(100, makePage(5)))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 6, numPages)
+
+	pagesDict, ok := core.GetDict(reader.catalog.Get("Pages"))
+	require.True(t, ok)
+	kids, ok := core.GetArray(pagesDict.Get("Kids"))
+	require.True(t, ok)
+	require.Equal(t, 6, kids.Len())
+
+	for i := 0; i < numPages; i++ {
+		page, err := reader.GetPage(i + 1)
+		require.NoError(t, err)
+		require.Equal(t, float64(i), page.MediaBox.Urx)
+	}
+}
+
+func TestWriterAttachFile(t *testing.T) {
+	const xml = `<?xml version="1.0"?><Invoice/>`
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.AttachFile("factur-x.xml", []byte(xml), "text/xml"))
+	require.NoError(t, w.AttachFile("b.txt", []byte("b"), ""))
+	require.NoError(t, w.AttachFile("z.txt", []byte("z"), "text/plain"))
+	require.Error(t, w.AttachFile("b.txt", []byte("again"), ""))
+	require.Error(t, w.AttachFile("", []byte("unnamed"), ""))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	names, ok := core.GetDict(reader.catalog.Get("Names"))
+	require.True(t, ok)
+	embeddedFiles, ok := core.GetDict(names.Get("EmbeddedFiles"))
+	require.True(t, ok)
+	tree, ok := core.GetArray(embeddedFiles.Get("Names"))
+	require.True(t, ok)
+	require.Equal(t, 6, tree.Len())
+
+	// Names are sorted.
+	var keys []string
+	for i := 0; i < tree.Len(); i += 2 {
+		key, ok := core.GetString(tree.Get(i))
+		require.True(t, ok)
+		keys = append(keys, key.Str())
+	}
+	require.Equal(t, []string{"b.txt", "factur-x.xml", "z.txt"}, keys)
+
+	filespec, ok := core.GetDict(tree.Get(3))
+	require.True(t, ok)
+	require.Equal(t, "/Filespec", filespec.Get("Type").WriteString())
+	require.Equal(t, "/Unspecified", filespec.Get("AFRelationship").WriteString())
+	ef, ok := core.GetDict(filespec.Get("EF"))
+	require.True(t, ok)
+	stream, ok := core.GetStream(ef.Get("F"))
+	require.True(t, ok)
+	require.Equal(t, "/EmbeddedFile", stream.Get("Type").WriteString())
+	require.Equal(t, "/text#2fxml", stream.Get("Subtype").WriteString())
+	params, ok := core.GetDict(stream.Get("Params"))
+	require.True(t, ok)
+	size, ok := core.GetIntVal(params.Get("Size"))
+	require.True(t, ok)
+	require.Equal(t, len(xml), size)
+	require.NotNil(t, params.Get("CheckSum"))
+	require.NotNil(t, params.Get("ModDate"))
+	decoded, err := core.DecodeStream(stream)
+	require.NoError(t, err)
+	require.Equal(t, xml, string(decoded))
+
+	af, ok := core.GetArray(reader.catalog.Get("AF"))
+	require.True(t, ok)
+	require.Equal(t, 3, af.Len())
+}
+
+func TestWriterSetPageLabels(t *testing.T) {
+	w := NewPdfWriter()
+	for i := 0; i < 5; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, w.AddPage(page))
+	}
+
+	require.Error(t, w.SetPageLabels([]PageLabelRange{{PageIndex: 1, Style: PageLabelDecimalArabic}}))
+	require.Error(t, w.SetPageLabels([]PageLabelRange{{PageIndex: 0}, {PageIndex: 0}}))
+	require.Error(t, w.SetPageLabels([]PageLabelRange{{PageIndex: 0, Style: "X"}}))
+	require.Error(t, w.SetPageLabels([]PageLabelRange{{PageIndex: 0, Start: -1}}))
+
+	require.NoError(t, w.SetPageLabels([]PageLabelRange{
+		{PageIndex: 5, Prefix: "Appendix ", Style: PageLabelUppercaseLetters, Start: 1},
+		{PageIndex: 3, Style: PageLabelDecimalArabic},
+		{PageIndex: 0, Style: PageLabelLowercaseRoman},
+		{PageIndex: 4, Prefix: "Index", Start: 7},
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	pageLabels, ok := core.GetDict(reader.catalog.Get("PageLabels"))
+	require.True(t, ok)
+	require.Equal(t,
+		"[0 <</S /r>> 3 <</S /D>> 4 <</P (Index)/St 7>> 5 <</S /A/P (Appendix )>>]",
+		pageLabels.Get("Nums").WriteString())
+
+	require.NoError(t, w.SetPageLabels(nil))
+	require.Nil(t, w.catalog.Get("PageLabels"))
+}
+
+func TestWriterOptionalContentGroups(t *testing.T) {
+	w := NewPdfWriter()
+	w.SetVersion(1, 5)
+
+	_, err := w.AddOptionalContentGroup("")
+	require.Error(t, err)
+	watermark, err := w.AddOptionalContentGroup("Watermark")
+	require.NoError(t, err)
+	notes, err := w.AddOptionalContentGroup("Notes")
+	require.NoError(t, err)
+	require.NoError(t, w.SetLayerVisibility(notes, false))
+	require.NoError(t, w.SetLayerVisibility(notes, false))
+	require.Error(t, w.SetLayerVisibility(core.MakeIndirectObject(core.MakeDict()), true))
+
+	// Tag the page content with the watermark group.
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	properties := core.MakeDict()
+	properties.Set("WM", watermark)
+	page.Resources.Properties = properties
+	require.NoError(t, page.AddContentStreamByString("/OC /WM BDC 0 0 m 100 100 l S EMC"))
+	require.NoError(t, w.AddPage(page))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	obj, err := reader.GetOCProperties()
+	require.NoError(t, err)
+	ocProperties, ok := core.GetDict(obj)
+	require.True(t, ok)
+
+	names := func(arr core.PdfObject) []string {
+		var names []string
+		a, ok := core.GetArray(arr)
+		require.True(t, ok)
+		for _, obj := range a.Elements() {
+			ocg, ok := core.GetDict(obj)
+			require.True(t, ok)
+			require.Equal(t, "OCG", ocg.Get("Type").String())
+			names = append(names, ocg.Get("Name").(*core.PdfObjectString).Decoded())
+		}
+		return names
+	}
+	require.Equal(t, []string{"Watermark", "Notes"}, names(ocProperties.Get("OCGs")))
+	config, ok := core.GetDict(ocProperties.Get("D"))
+	require.True(t, ok)
+	require.Equal(t, []string{"Watermark"}, names(config.Get("ON")))
+	require.Equal(t, []string{"Notes"}, names(config.Get("OFF")))
+
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+	properties, ok = core.GetDict(page.Resources.Properties)
+	require.True(t, ok)
+	ocg, ok := core.GetDict(properties.Get("WM"))
+	require.True(t, ok)
+	require.Equal(t, "Watermark", ocg.Get("Name").(*core.PdfObjectString).Decoded())
+}
+
+func TestWriterNamedDestinationsAndLinks(t *testing.T) {
+	w := NewPdfWriter()
+	var pages []*PdfPage
+	for i := 0; i < 3; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, w.AddPage(page))
+		pages = append(pages, page)
+	}
+	notAdded := NewPdfPage()
+
+	require.NoError(t, w.AddNamedDestination("chapter2", pages[1], 700))
+	require.NoError(t, w.AddNamedDestination("appendix", pages[2], 792))
+	require.Error(t, w.AddNamedDestination("chapter2", pages[2], 700))
+	require.Error(t, w.AddNamedDestination("missing", notAdded, 700))
+
+	rect := PdfRectangle{Llx: 72, Lly: 700, Urx: 300, Ury: 712}
+	require.NoError(t, w.AddLinkToDestination(pages[0], rect, "chapter2"))
+	require.NoError(t, w.AddLinkToPage(pages[0], rect, pages[2], 500))
+	require.Error(t, w.AddLinkToPage(pages[0], rect, notAdded, 500))
+	require.Error(t, w.AddLinkToDestination(notAdded, rect, "chapter2"))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	var readPages []core.PdfObject
+	for i := 1; i <= 3; i++ {
+		page, err := reader.GetPage(i)
+		require.NoError(t, err)
+		readPages = append(readPages, page.GetContainingPdfObject())
+	}
+
+	names, ok := core.GetDict(reader.catalog.Get("Names"))
+	require.True(t, ok)
+	dests, ok := core.GetDict(names.Get("Dests"))
+	require.True(t, ok)
+	tree, ok := core.GetArray(dests.Get("Names"))
+	require.True(t, ok)
+	require.Equal(t, 4, tree.Len())
+	require.Equal(t, "(appendix)", tree.Get(0).WriteString())
+	require.Equal(t, "(chapter2)", tree.Get(2).WriteString())
+	dest, ok := core.GetArray(tree.Get(3))
+	require.True(t, ok)
+	require.Equal(t, readPages[1], core.ResolveReference(dest.Get(0)))
+	require.Equal(t, 5, dest.Len())
+	require.Equal(t, "/XYZ", dest.Get(1).WriteString())
+	top, err := core.GetNumberAsFloat(dest.Get(3))
+	require.NoError(t, err)
+	require.Equal(t, 700.0, top)
+
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	annotations, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 2)
+
+	link, ok := annotations[0].GetContext().(*PdfAnnotationLink)
+	require.True(t, ok)
+	require.Equal(t, "(chapter2)", link.Dest.WriteString())
+
+	link, ok = annotations[1].GetContext().(*PdfAnnotationLink)
+	require.True(t, ok)
+	action, ok := core.GetDict(link.A)
+	require.True(t, ok)
+	require.Equal(t, "/GoTo", action.Get("S").WriteString())
+	d, ok := core.GetArray(action.Get("D"))
+	require.True(t, ok)
+	require.Equal(t, readPages[2], core.ResolveReference(d.Get(0)))
+}
+
+func TestWriterSetXMPMetadata(t *testing.T) {
+	newWriter := func() PdfWriter {
+		w := NewPdfWriter()
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, w.AddPage(page))
+		return w
+	}
+	readXMP := func(reader *PdfReader) string {
+		stream, ok := core.GetStream(reader.catalog.Get("Metadata"))
+		require.True(t, ok)
+		require.Equal(t, "/Metadata", stream.Get("Type").WriteString())
+		require.Equal(t, "/XML", stream.Get("Subtype").WriteString())
+		decoded, err := core.DecodeStream(stream)
+		require.NoError(t, err)
+		return string(decoded)
+	}
+
+	w := newWriter()
+	require.NoError(t, w.SetTitle("Report <2020> & more"))
+	require.NoError(t, w.SetAuthor("Author"))
+	require.NoError(t, w.SetCreationDate(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	require.NoError(t, w.SetXMPFromInfo())
+	// Setting the metadata again replaces the stream data.
+	require.NoError(t, w.SetXMPFromInfo())
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("/Type /Metadata")))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	xmp := readXMP(reader)
+	require.Contains(t, xmp, `<rdf:li xml:lang="x-default">Report &lt;2020&gt; &amp; more</rdf:li>`)
+	require.Contains(t, xmp, `<dc:creator><rdf:Seq><rdf:li>Author</rdf:li></rdf:Seq></dc:creator>`)
+	require.Contains(t, xmp, `<xmp:CreateDate>2020-01-02T03:04:05Z</xmp:CreateDate>`)
+
+	// The metadata stays readable without the password when encrypted with AES.
+	const packet = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><dc:title>Plain</dc:title></x:xmpmeta>`
+	w = newWriter()
+	require.NoError(t, w.SetXMPMetadata([]byte(packet)))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Permissions: security.PermOwner, Algorithm: AES_128bit}))
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+	require.True(t, bytes.Contains(buf.Bytes(), []byte(packet)))
+
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	auth, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, auth)
+	require.Equal(t, packet, readXMP(reader))
+	require.True(t, bytes.Contains(buf.Bytes(), []byte("/Name /Identity")))
+
+	// Unencrypted metadata requires an AES algorithm, and must be set before encrypting.
+	w = newWriter()
+	require.NoError(t, w.SetXMPMetadata([]byte(packet)))
+	require.Error(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: RC4_128bit}))
+	w = newWriter()
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: AES_128bit}))
+	require.Error(t, w.SetXMPMetadata([]byte(packet)))
+	w = newWriter()
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: AES_128bit, UnencryptedMetadata: true}))
+	require.NoError(t, w.SetXMPMetadata([]byte(packet)))
+
+	w = newWriter()
+	require.NoError(t, w.SetXMPMetadata([]byte(packet)))
+	require.NoError(t, w.SetXMPMetadata(nil))
+	require.Nil(t, w.catalog.Get("Metadata"))
+}
+
+func TestWriterPreserveObjectNumbers(t *testing.T) {
+	w := NewPdfWriter()
+	w.SetPreserveObjectNumbers(true)
+	w.SetUseCrossReferenceStream(false)
+	var pages []*core.PdfIndirectObject
+	for i := 0; i < 3; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, w.AddPage(page))
+		pageObj, ok := core.GetIndirect(page.GetContainingPdfObject())
+		require.True(t, ok)
+		pages = append(pages, pageObj)
+	}
+	// The last page has the same number as the first one, so is renumbered.
+	pages[0].ObjectNumber, pages[0].GenerationNumber = 10, 2
+	pages[1].ObjectNumber, pages[1].GenerationNumber = 2, 0
+	pages[2].ObjectNumber, pages[2].GenerationNumber = 10, 1
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	out := buf.String()
+	require.Contains(t, out, "\n10 2 obj\n")
+	require.Contains(t, out, " 00002 n\r\n")
+	kids := regexp.MustCompile(`/Kids \[10 2 R 2 0 R (\d+) 0 R\]`).FindStringSubmatch(out)
+	require.Len(t, kids, 2)
+	require.NotEqual(t, "10", kids[1])
+
+	// New objects are numbered from the lowest free number.
+	require.Contains(t, out, "/Info 1 0 R")
+	require.Contains(t, out, "/Root 3 0 R")
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 3, numPages)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	pageObj, ok := core.GetIndirect(page.GetContainingPdfObject())
+	require.True(t, ok)
+	require.Equal(t, int64(10), pageObj.ObjectNumber)
+	require.Equal(t, int64(2), pageObj.GenerationNumber)
+}
+
+func TestWriterPreserveObjectNumbersEncrypted(t *testing.T) {
+	for _, algorithm := range []EncryptionAlgorithm{RC4_128bit, AES_128bit} {
+		w := NewPdfWriter()
+		w.SetPreserveObjectNumbers(true)
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.AddContentStreamByString("BT /F1 12 Tf (Preserved) Tj ET"))
+		stream, ok := core.GetStream(page.Contents)
+		require.True(t, ok)
+		stream.ObjectNumber, stream.GenerationNumber = 20, 3
+		require.NoError(t, w.AddPage(page))
+		require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: algorithm}))
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		require.Contains(t, buf.String(), "\n20 3 obj\n")
+
+		reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		auth, err := reader.Decrypt([]byte("user"))
+		require.NoError(t, err)
+		require.True(t, auth)
+		readPage, err := reader.GetPage(1)
+		require.NoError(t, err)
+		contents, err := readPage.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, "(Preserved) Tj")
+	}
+}
+
+func TestPdfStreamWriter(t *testing.T) {
+	f, err := os.CreateTemp("", "stream-writer-*.pdf")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w, err := NewPdfStreamWriter(f)
+	require.NoError(t, err)
+	require.NoError(t, w.SetTitle("Streamed"))
+	w.SetCompressStreams(true)
+
+	// The font is shared by all the pages.
+	font, err := NewStandard14Font(CourierName)
+	require.NoError(t, err)
+	resources := NewPdfPageResources()
+	require.NoError(t, resources.SetFontByName("F1", font.ToPdfObject()))
+
+	const numPages = 5
+	for i := 1; i <= numPages; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		page.Resources = resources
+		content := fmt.Sprintf("BT /F1 12 Tf (Page %d) Tj ET\n", i)
+		require.NoError(t, page.SetContentStreams([]string{strings.Repeat(content, 10)}, nil))
+		contents, ok := core.GetStream(page.Contents)
+		require.True(t, ok)
+		if i == 1 {
+			w.SetStreamEncoder(contents, core.NewASCIIHexEncoder())
+		}
+		require.NoError(t, w.AddPage(page))
+		require.Len(t, w.objects, 3)
+		// The added stream is encoded on a copy.
+		require.Nil(t, contents.Get("Filter"))
+	}
+	require.Error(t, w.InsertPage(0, NewPdfPage()))
+	require.Error(t, w.Write(&bytes.Buffer{}))
+	require.NoError(t, w.Close())
+	require.Error(t, w.Close())
+
+	data, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, 1, bytes.Count(data, []byte("/BaseFont /Courier")))
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	n, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, numPages, n)
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, fmt.Sprintf("(Page %d) Tj", i))
+		_, ok := page.Resources.GetFontByName("F1")
+		require.True(t, ok)
+
+		// The first content stream is the added one, the unlicensed notice may follow.
+		contentsObj := page.Contents
+		if arr, ok := core.GetArray(contentsObj); ok {
+			contentsObj = arr.Get(0)
+		}
+		stream, ok := core.GetStream(contentsObj)
+		require.True(t, ok)
+		filter := "FlateDecode"
+		if i == 1 {
+			filter = "ASCIIHexDecode"
+		}
+		name, _ := core.GetNameVal(stream.Get("Filter"))
+		require.Equal(t, filter, name)
+	}
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	info, ok := core.GetDict(trailer.Get("Info"))
+	require.True(t, ok)
+	require.Equal(t, "(Streamed)", info.Get("Title").WriteString())
+}
+
+func TestWriterAddPagesFrom(t *testing.T) {
+	// Write a document whose pages share a font.
+	font, err := NewStandard14Font(CourierName)
+	require.NoError(t, err)
+	fontObj := font.ToPdfObject()
+	w := NewPdfWriter()
+	for i := 1; i <= 3; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		require.NoError(t, page.Resources.SetFontByName("F1", fontObj))
+		require.NoError(t, page.AddContentStreamByString(fmt.Sprintf("BT /F1 12 Tf (Page %d) Tj ET", i)))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	merge := func(pageRange []int) *PdfReader {
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPagesFrom(reader, pageRange))
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+		require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("/BaseFont /Courier")))
+		merged, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		return merged
+	}
+
+	merged := merge(nil)
+	numPages, err := merged.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 3, numPages)
+
+	merged = merge([]int{3, 1})
+	numPages, err = merged.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+	page, err := merged.GetPage(1)
+	require.NoError(t, err)
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, contents, "(Page 3) Tj")
+
+	w = NewPdfWriter()
+	require.Error(t, w.AddPagesFrom(reader, []int{4}))
+}
+
+// sliceWriterAt implements io.WriterAt, writing within the slice.
+type sliceWriterAt []byte
+
+func (s sliceWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(s[off:], p), nil
+}
+
+func TestWriterPrepareSignature(t *testing.T) {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, w.AddPage(page))
+	_, err := w.PrepareSignature(SignatureFieldOpts{Page: NewPdfPage()})
+	require.Error(t, err)
+
+	handle, err := w.PrepareSignature(SignatureFieldOpts{
+		Name:         "Approval",
+		Page:         page,
+		Rect:         PdfRectangle{Llx: 72, Lly: 72, Urx: 272, Ury: 122},
+		ContentsSize: 64,
+		Reason:       "Approved",
+		Date:         time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	_, err = w.PrepareSignature(SignatureFieldOpts{})
+	require.Error(t, err)
+	_, err = handle.ByteRange()
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	data := buf.Bytes()
+
+	byteRange, err := handle.ByteRange()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), byteRange[0])
+	require.Equal(t, int64(len(data)), byteRange[2]+byteRange[3])
+	contents := string(data[byteRange[1]:byteRange[2]])
+	require.Equal(t, "<"+strings.Repeat("0", 2*64)+">", contents)
+	require.Contains(t, string(data), fmt.Sprintf("/ByteRange [%010d %010d %010d %010d]",
+		byteRange[0], byteRange[1], byteRange[2], byteRange[3]))
+
+	signed, err := handle.SignedData(bytes.NewReader(data))
+	require.NoError(t, err)
+	var signedBuf bytes.Buffer
+	_, err = signedBuf.ReadFrom(signed)
+	require.NoError(t, err)
+	require.Equal(t, string(data[:byteRange[1]])+string(data[byteRange[2]:]), signedBuf.String())
+
+	require.Error(t, handle.PatchContents(sliceWriterAt(data), make([]byte, 65)))
+	require.NoError(t, handle.PatchContents(sliceWriterAt(data), []byte{0xde, 0xad, 0xbe, 0xef}))
+	require.Equal(t, "<deadbeef"+strings.Repeat("0", 2*60)+">", string(data[byteRange[1]:byteRange[2]]))
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NotNil(t, reader.AcroForm)
+	require.Equal(t, int64(3), int64(*reader.AcroForm.SigFlags))
+	fields := reader.AcroForm.AllFields()
+	require.Len(t, fields, 1)
+	require.Equal(t, "Approval", fields[0].T.Decoded())
+
+	sigField, ok := fields[0].GetContext().(*PdfFieldSignature)
+	require.True(t, ok)
+	require.NotNil(t, sigField.V)
+	require.Equal(t, "Sig", sigField.V.Type.String())
+	require.Equal(t, "Adobe.PPKLite", sigField.V.Filter.String())
+	require.Equal(t, "adbe.pkcs7.detached", sigField.V.SubFilter.String())
+	require.Equal(t, "Approved", sigField.V.Reason.Str())
+	require.Equal(t, "\xde\xad\xbe\xef"+string(make([]byte, 60)), sigField.V.Contents.Str())
+	readRange, err := sigField.V.ByteRange.ToInt64Slice()
+	require.NoError(t, err)
+	require.Equal(t, byteRange[:], readRange)
+
+	readPage, err := reader.GetPage(1)
+	require.NoError(t, err)
+	annotations, err := readPage.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+}
+
+func TestWriterFlattenForms(t *testing.T) {
+	makeWidget := func(rect []float64, matrix []float64, flags int64) *PdfAnnotationWidget {
+		ap, err := core.MakeStream([]byte("0 0 1 rg 0 0 100 20 re f"), nil)
+		require.NoError(t, err)
+		ap.Set("Type", core.MakeName("XObject"))
+		ap.Set("Subtype", core.MakeName("Form"))
+		ap.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, 100, 20}))
+		if matrix != nil {
+			ap.Set("Matrix", core.MakeArrayFromFloats(matrix))
+		}
+		apDict := core.MakeDict()
+		apDict.Set("N", ap)
+
+		widget := NewPdfAnnotationWidget()
+		widget.Rect = core.MakeArrayFromFloats(rect)
+		widget.AP = apDict
+		if flags != 0 {
+			widget.F = core.MakeInteger(flags)
+		}
+		return widget
+	}
+
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	require.NoError(t, page.AddContentStreamByString("1 0 0 1 5 5 cm"))
+
+	form := NewPdfAcroForm()
+	var fields []*PdfField
+	widgets := []*PdfAnnotationWidget{
+		makeWidget([]float64{50, 700, 150, 720}, nil, 0),
+		// Rotated by 90 degrees.
+		makeWidget([]float64{10, 10, 30, 110}, []float64{0, 1, -1, 0, 0, 0}, 0),
+		// Hidden.
+		makeWidget([]float64{50, 600, 150, 620}, nil, 2),
+	}
+	for i, widget := range widgets {
+		field := NewPdfField()
+		field.T = core.MakeString(fmt.Sprintf("field%d", i))
+		field.FT = core.MakeName("Tx")
+		field.Annotations = append(field.Annotations, widget)
+		widget.Parent = field.GetContainingPdfObject()
+		fields = append(fields, field)
+		page.AddAnnotation(widget.PdfAnnotation)
+	}
+	form.Fields = &fields
+	link := NewPdfAnnotationLink()
+	link.Rect = core.MakeArrayFromFloats([]float64{0, 0, 10, 10})
+	page.AddAnnotation(link.PdfAnnotation)
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.SetForms(form))
+	w.SetSigFlags(SigFlagSignaturesExist)
+	require.NoError(t, w.FlattenForms())
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Nil(t, reader.AcroForm)
+
+	readPage, err := reader.GetPage(1)
+	require.NoError(t, err)
+	annotations, err := readPage.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	_, isLink := annotations[0].GetContext().(*PdfAnnotationLink)
+	require.True(t, isLink)
+
+	content, err := readPage.GetAllContentStreams()
+	require.NoError(t, err)
+	// The existing contents are isolated in q ... Q.
+	require.True(t, strings.HasPrefix(content, "q\n"), content)
+	require.Contains(t, content, "1 0 0 1 5 5 cm")
+	require.True(t, strings.HasSuffix(content, "Q\n"+
+		"q\n1.000000 0.000000 0.000000 1.000000 50.000000 700.000000 cm\n/Fm0 Do\nQ\n"+
+		"q\n1.000000 0.000000 0.000000 1.000000 30.000000 10.000000 cm\n/Fm1 Do\nQ\n"), content)
+	require.True(t, readPage.Resources.HasXObjectByName("Fm0"))
+	require.True(t, readPage.Resources.HasXObjectByName("Fm1"))
+	require.False(t, readPage.Resources.HasXObjectByName("Fm2"))
+	require.NotContains(t, buf.String(), "/FT")
+
+	// The fields of a form copied from a reader, including non-terminal ones, are not written.
+	group := NewPdfField()
+	group.T = core.MakeString("group")
+	group.Kids = fields
+	for _, field := range fields {
+		field.Parent = group
+	}
+	form.Fields = &[]*PdfField{group}
+	page = NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+	for _, widget := range widgets {
+		page.AddAnnotation(widget.PdfAnnotation)
+	}
+	w = NewPdfWriter()
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.copyEntry(w.catalog, "AcroForm", form.ToPdfObject()))
+	require.NoError(t, w.FlattenForms())
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+	require.NotContains(t, buf.String(), "/T (group)")
+	require.NotContains(t, buf.String(), "/FT")
+	require.NotContains(t, buf.String(), "/Fields")
+}
+
+func TestWriterWriteIncremental(t *testing.T) {
+	// Write a document with two pages.
+	w := NewPdfWriter()
+	for i := 1; i <= 2; i++ {
+		page := NewPdfPage()
+		page.MediaBox = &PdfRectangle{Urx: 612, Ury: 792}
+		contents, err := core.MakeStream([]byte(fmt.Sprintf("BT /F1 12 Tf (Page %d) Tj ET", i)), nil)
+		require.NoError(t, err)
+		page.Contents = contents
+		require.NoError(t, w.AddPage(page))
+	}
+	require.NoError(t, w.SetDocumentID([]byte("id0"), []byte("id1")))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	original := buf.Bytes()
+
+	reader, err := NewPdfReader(bytes.NewReader(original))
+	require.NoError(t, err)
+	// The first contents stream, which is followed by a watermark in unlicensed mode.
+	firstContents := func(page *PdfPage) *core.PdfObjectStream {
+		obj := page.GetPageDict().Get("Contents")
+		if arr, ok := core.GetArray(obj); ok {
+			obj = arr.Get(0)
+		}
+		stream, ok := core.GetStream(obj)
+		require.True(t, ok)
+		return stream
+	}
+	contentsNum := func(page *PdfPage) int64 {
+		return firstContents(page).ObjectNumber
+	}
+	page1Contents := contentsNum(reader.PageList[0])
+	page2Contents := contentsNum(reader.PageList[1])
+
+	// Update the document, changing the contents of the second page only.
+	w, err = NewPdfWriterFromReader(reader)
+	require.NoError(t, err)
+	for _, page := range reader.PageList {
+		require.NoError(t, w.AddPage(page))
+	}
+	contents := firstContents(reader.PageList[1])
+	contents.Stream = []byte("BT /F1 12 Tf (Page 2 updated) Tj ET")
+	contents.Set("Length", core.MakeInteger(int64(len(contents.Stream))))
+	require.NoError(t, w.SetTitle("Updated"))
+
+	var out bytes.Buffer
+	require.NoError(t, w.WriteIncremental(&out, reader))
+	updated := reader
+	require.True(t, bytes.HasPrefix(out.Bytes(), original))
+	update := string(out.Bytes()[len(original):])
+	require.Contains(t, update, "/Prev")
+	require.Contains(t, update, fmt.Sprintf("\n%d 0 obj", page2Contents))
+	require.NotContains(t, update, fmt.Sprintf("\n%d 0 obj", page1Contents))
+
+	reader, err = NewPdfReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+	for i, expected := range []string{"(Page 1) Tj", "(Page 2 updated) Tj"} {
+		page, err := reader.GetPage(i + 1)
+		require.NoError(t, err)
+		content, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, content, expected)
+	}
+	require.Equal(t, page1Contents, contentsNum(reader.PageList[0]))
+	require.Equal(t, page2Contents, contentsNum(reader.PageList[1]))
+
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	info, ok := core.GetDict(trailer.Get("Info"))
+	require.True(t, ok)
+	require.Equal(t, "Updated", info.Get("Title").(*core.PdfObjectString).Decoded())
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	require.True(t, ok)
+	require.Equal(t, "id0", ids.Get(0).(*core.PdfObjectString).Str())
+	require.NotEqual(t, "id1", ids.Get(1).(*core.PdfObjectString).Str())
+
+	// The writer is left unchanged, so it can write the update again or the whole document.
+	var again bytes.Buffer
+	require.NoError(t, w.WriteIncremental(&again, updated))
+	require.Equal(t, len(out.Bytes()), len(again.Bytes()))
+	var full bytes.Buffer
+	require.NoError(t, w.Write(&full))
+	for _, data := range [][]byte{again.Bytes(), full.Bytes()} {
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 2, numPages)
+		page, err := reader.GetPage(2)
+		require.NoError(t, err)
+		content, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, content, "(Page 2 updated) Tj")
+	}
+}
@@ -280,9 +280,9 @@ func UpdateXObjectImageFromImage(xobjIn *XObjectImage, img *Image, cs PdfColorsp
 	}
 
 	if img.hasAlpha {
-		// Add the alpha channel information as a stencil mask (SMask).
-		// Has same width and height as original and stored in same
-		// bits per component (1 component, hence the DeviceGray channel).
+		// Add the alpha channel information as a soft mask (SMask).
+		// Has same width and height as original and stored as 8 bits
+		// per component (1 component, hence the DeviceGray channel).
 		smask := NewXObjectImage()
 		smask.Filter = encoder
 		encoded, err := encoder.EncodeBytes(img.alphaData)
@@ -291,7 +291,8 @@ func UpdateXObjectImageFromImage(xobjIn *XObjectImage, img *Image, cs PdfColorsp
 			return nil, err
 		}
 		smask.Stream = encoded
-		smask.BitsPerComponent = &img.BitsPerComponent
+		bpc := int64(8)
+		smask.BitsPerComponent = &bpc
 		smask.Width = &img.Width
 		smask.Height = &img.Height
 		smask.ColorSpace = NewPdfColorspaceDeviceGray()
@@ -543,9 +544,38 @@ func (ximg *XObjectImage) ToImage() (*Image, error) {
 		image.decode = decode
 	}
 
+	// A soft mask (SMask) specifies the alpha channel and overrides any Mask entry.
+	// A Mask array specifies a color key mask: ranges of colors to be masked out.
+	if sstream, ok := core.GetStream(ximg.SMask); ok {
+		if err := image.applySoftMaskStream(sstream); err != nil {
+			common.Log.Debug("ERROR: Unable to apply soft mask: %v", err)
+		}
+	} else if marr, ok := core.GetArray(ximg.Mask); ok {
+		ranges, err := marr.ToFloat64Array()
+		if err != nil {
+			return nil, err
+		}
+		if err := image.applyColorKeyMask(ranges); err != nil {
+			common.Log.Debug("ERROR: Unable to apply color key mask: %v", err)
+		}
+	}
+
 	return image, nil
 }
 
+// applySoftMaskStream sets the alpha channel of `img` from the soft mask image `stream`.
+func (img *Image) applySoftMaskStream(stream *core.PdfObjectStream) error {
+	smask, err := NewXObjectImageFromStream(stream)
+	if err != nil {
+		return err
+	}
+	smaskImg, err := smask.ToImage()
+	if err != nil {
+		return err
+	}
+	return img.applySoftMask(smaskImg)
+}
+
 // GetContainingPdfObject returns the container of the image object (indirect object).
 func (ximg *XObjectImage) GetContainingPdfObject() core.PdfObject {
 	return ximg.primitive